@@ -0,0 +1,138 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	flag "github.com/spf13/pflag"
+
+	remouseable "github.com/kevinconway/remouseable/pkg"
+)
+
+// calibrationTargetInset is how far, in pixels, from the edge of the screen
+// the pointer is placed when showing a target.
+const calibrationTargetInset = 5
+
+var calibrateCommand = command{
+	name:    "calibrate",
+	summary: "Measure the corners of the tablet and save a calibration for later runs.",
+	setup: func(fs *flag.FlagSet) func(context.Context) error {
+		conn := addConnectionFlags(fs)
+		tablet := addTabletFlags(fs)
+		mouse := addMouseFlags(fs)
+		showTargets := fs.Bool("show-targets", false, "Move the mouse pointer to the corner of the screen that matches each corner of the tablet while calibrating.")
+		return func(ctx context.Context) error {
+			if *mouse.calibration == "" {
+				return errors.New("a path is required for --calibration")
+			}
+			var driver remouseable.Driver
+			if *showTargets {
+				d, err := mouse.openDriver()
+				if err != nil {
+					return err
+				}
+				defer remouseable.CloseDriver(d)
+				driver = d
+			}
+			stream, err := openTabletStream(ctx, conn, tablet)
+			if err != nil {
+				return err
+			}
+			sm := &remouseable.EvdevStateMachine{
+				Iterator:          stream.iterator(ctx),
+				PressureThreshold: *mouse.pressureThreshold,
+			}
+			defer sm.Close()
+
+			fmt.Printf("Hold the tablet in the %s orientation.\n", *mouse.orientation)
+			var corners [4][2]int
+			for i, name := range remouseable.CalibrationCorners {
+				if *showTargets {
+					if err = showCalibrationTarget(driver, mouse, i); err != nil {
+						return err
+					}
+				}
+				fmt.Printf("Touch the pen to the %s corner of the tablet screen and then lift it.\n", name)
+				corners[i], err = measureCorner(sm)
+				if err != nil {
+					return err
+				}
+			}
+
+			c, err := remouseable.Calibrate(*mouse.orientation, corners)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("active bounds: x %d to %d, y %d to %d\n", c.MinX, c.MaxX, c.MinY, c.MaxY)
+			fmt.Printf("offsets:       x %d, y %d\n", c.MinX, c.MinY)
+			fmt.Printf("correction:    %.4f %.4f %.4f / %.4f %.4f %.4f\n",
+				c.Correction[0], c.Correction[1], c.Correction[2],
+				c.Correction[3], c.Correction[4], c.Correction[5],
+			)
+			if err = remouseable.SaveCalibration(*mouse.calibration, c); err != nil {
+				return err
+			}
+			fmt.Printf("calibration saved to %s\n", *mouse.calibration)
+			return nil
+		}
+	},
+}
+
+// measureCorner waits for the pen to touch the tablet and be lifted again. It
+// returns the last position of the pen while it was touching.
+func measureCorner(sm remouseable.StateMachine) ([2]int, error) {
+	var position [2]int
+	touching := false
+	touched := false
+	for sm.Next() {
+		switch change := sm.Current().(type) {
+		case *remouseable.StateChangeMove:
+			if touching {
+				position = [2]int{int(change.X), int(change.Y)}
+				touched = true
+			}
+		case *remouseable.StateChangeClick:
+			touching = true
+		case *remouseable.StateChangeUnclick:
+			if touched {
+				return position, nil
+			}
+			touching = false
+		}
+	}
+	if err := sm.Close(); err != nil {
+		return position, err
+	}
+	return position, errors.New("the tablet stopped sending events before calibration finished")
+}
+
+// showCalibrationTarget moves the pointer to the screen corner that matches
+// one of the remouseable.CalibrationCorners.
+func showCalibrationTarget(driver remouseable.Driver, mouse *mouseFlags, corner int) error {
+	x, y := calibrationTargetInset, calibrationTargetInset
+	right, bottom := *mouse.screenWidth-calibrationTargetInset, *mouse.screenHeight-calibrationTargetInset
+	switch corner {
+	case 1:
+		x = right
+	case 2:
+		x, y = right, bottom
+	case 3:
+		y = bottom
+	}
+	return driver.MoveMouse(float64(x), float64(y))
+}
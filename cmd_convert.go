@@ -0,0 +1,92 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+
+	remouseable "github.com/kevinconway/remouseable/pkg"
+)
+
+var convertCommand = command{
+	name:    "convert",
+	summary: "Convert a capture to the libinput record or evemu format to replay it on a Linux machine.",
+	setup: func(fs *flag.FlagSet) func(context.Context) error {
+		input := fs.StringP("input", "i", "remouseable.events", "Path to the file to read events from. Use - to read from standard input.")
+		inputFormat := fs.String("input-format", remouseable.CaptureFormatRaw, fmt.Sprintf("The format of the input file. Choices are %s.", strings.Join(remouseable.CaptureFormats, ", ")))
+		layout := fs.String("event-layout", remouseable.EventLayoutTimeval32, "The layout of the recorded events. Choices are timeval32 and timeval64.")
+		output := fs.StringP("output", "o", "-", "Path to the file to write to. Use - to write to standard output.")
+		outputFormat := fs.String("output-format", remouseable.CaptureFormatLibinput, fmt.Sprintf("The format to write. Choices are %s. %s can be played with libinput replay and %s with evemu-device and evemu-play.", strings.Join(remouseable.ExportFormats, ", "), remouseable.CaptureFormatLibinput, remouseable.CaptureFormatEvemu))
+		model := fs.String("tablet-model", "reMarkable 2", "The name of the tablet profile that describes the device the events came from.")
+		tabletProfiles := fs.String("tablet-profiles", defaultTabletProfilesFile(), "Path to a JSON file of additional tablet model profiles that are checked before the built in ones.")
+		return func(ctx context.Context) error {
+			if err := checkCaptureFlags(*inputFormat, *layout); err != nil {
+				return err
+			}
+			switch *outputFormat {
+			case remouseable.CaptureFormatLibinput, remouseable.CaptureFormatEvemu:
+			default:
+				return fmt.Errorf("unknown output format selection %s", *outputFormat)
+			}
+			profile, err := findTabletProfile(*tabletProfiles, *model)
+			if err != nil {
+				return err
+			}
+			it, err := openCapture(*input, *inputFormat, *layout)
+			if err != nil {
+				return err
+			}
+			events, err := remouseable.ReadCapture(&remouseable.ContextEvdevIterator{Wrapped: it, Context: ctx})
+			if err != nil {
+				return err
+			}
+			var out io.WriteCloser = os.Stdout
+			if *output != "-" {
+				f, err := os.Create(*output)
+				if err != nil {
+					return err
+				}
+				out = f
+			}
+			err = remouseable.WriteCapture(*outputFormat, out, remouseable.NewCaptureDevice(profile, events), events)
+			if closeErr := out.Close(); err == nil {
+				err = closeErr
+			}
+			return err
+		}
+	},
+}
+
+// findTabletProfile returns the profile with the given name.
+func findTabletProfile(file string, name string) (remouseable.TabletProfile, error) {
+	profiles, err := remouseable.LoadTabletProfiles(file)
+	if err != nil {
+		return remouseable.TabletProfile{}, err
+	}
+	names := make([]string, 0, len(profiles))
+	for _, p := range profiles {
+		if p.Name == name {
+			return p, nil
+		}
+		names = append(names, p.Name)
+	}
+	return remouseable.TabletProfile{}, fmt.Errorf("%w: no profile named %s. Available profiles are: %s", remouseable.ErrUnknownTablet, name, strings.Join(names, ", "))
+}
@@ -0,0 +1,109 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+
+	remouseable "github.com/kevinconway/remouseable/pkg"
+)
+
+var debugCommand = command{
+	name:    "debug",
+	summary: "Print the pen and button events from the tablet as they happen.",
+	setup: func(fs *flag.FlagSet) func(context.Context) error {
+		conn := addConnectionFlags(fs)
+		tablet := addTabletFlags(fs)
+		filters := fs.StringArray("filter", nil, "Only print events that match an expression such as EV_ABS:ABS_PRESSURE>500, BTN_TOOL_*, or ABS_TILT_X=-20..20. All event types are read when this is given. May be given more than once to print events that match any of them.")
+		format := fs.String("format", remouseable.DefaultEventFormat, fmt.Sprintf("How to print events. Choices are %s. %s prints one JSON object per line, %s matches the output of the evtest tool, and %s groups events into SYN_REPORT frames and shows how much each value changed.", strings.Join(remouseable.EventFormats, ", "), remouseable.EventFormatJSON, remouseable.EventFormatEvtest, remouseable.EventFormatTable))
+		all := fs.Bool("all-events", false, "Print every type of event rather than only the pen and button events.")
+		return func(ctx context.Context) error {
+			parsed, err := remouseable.ParseEventFilters(*filters)
+			if err != nil {
+				return err
+			}
+			return streamDebugEvents(ctx, conn, tablet, debugOptions{
+				format:  *format,
+				all:     *all,
+				filters: parsed,
+			})
+		}
+	},
+}
+
+// debugOptions control which events streamDebugEvents prints and how.
+type debugOptions struct {
+	format  string
+	all     bool
+	filters []remouseable.EventFilter
+}
+
+// streamDebugEvents prints each pen and button event from the tablet in the
+// chosen format. Every type of event is read when all is set or filters are
+// given, and only those that match one of the filters are printed.
+func streamDebugEvents(ctx context.Context, conn *connectionFlags, tablet *tabletFlags, opts debugOptions) error {
+	sink, err := remouseable.NewEventSink(opts.format, os.Stdout)
+	if err != nil {
+		return err
+	}
+	stream, err := openTabletStream(ctx, conn, tablet)
+	if err != nil {
+		return err
+	}
+	types := []remouseable.EventType{remouseable.EV_ABS, remouseable.EV_KEY}
+	if opts.format != remouseable.EventFormatJSON {
+		// The frame markers are part of the evtest output and are what the
+		// table uses to group events.
+		types = append(types, remouseable.EV_SYN)
+	}
+	if opts.all || len(opts.filters) > 0 {
+		types = types[:0]
+		for etype := range remouseable.EVMap {
+			types = append(types, etype)
+		}
+	}
+	it := stream.iterator(ctx, types...)
+	if len(opts.filters) > 0 {
+		match := remouseable.AnyFilter(opts.filters...)
+		if opts.format == remouseable.EventFormatTable {
+			// Keep the frame markers so that matching events are still
+			// grouped.
+			match = remouseable.AnyFilter(append(opts.filters, remouseable.EventFilter{
+				TypePattern: "EV_SYN",
+				CodePattern: "SYN_REPORT",
+			})...)
+		}
+		it = &remouseable.MatchingEvdevIterator{
+			Wrapped: it,
+			Match:   match,
+		}
+	}
+	fmt.Fprintln(os.Stderr, "remouseable connected and running.")
+	for evt, err := range remouseable.Events(it) {
+		if err != nil {
+			_ = sink.Flush()
+			return err
+		}
+		if err := sink.WriteEvent(evt); err != nil {
+			return err
+		}
+	}
+	return sink.Flush()
+}
@@ -0,0 +1,44 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	flag "github.com/spf13/pflag"
+)
+
+var discoverCommand = command{
+	name:    "discover",
+	summary: "Search the USB network, mDNS, and the local subnets for tablets.",
+	setup: func(fs *flag.FlagSet) func(context.Context) error {
+		discovery := addDiscoveryFlags(fs)
+		return func(ctx context.Context) error {
+			candidates, err := discovery.discoverer().Discover(ctx)
+			if err != nil {
+				return err
+			}
+			if len(candidates) < 1 {
+				return errors.New("no tablets found")
+			}
+			for _, c := range candidates {
+				fmt.Printf("%s\t%s\t%s\n", c.Address, c.Source, c.Banner)
+			}
+			return nil
+		}
+	},
+}
@@ -0,0 +1,63 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+
+	remouseable "github.com/kevinconway/remouseable/pkg"
+)
+
+var infoCommand = command{
+	name:    "info",
+	summary: "Show the tablet model, its input devices, and the settings that would be used.",
+	setup: func(fs *flag.FlagSet) func(context.Context) error {
+		conn := addConnectionFlags(fs)
+		tabletProfiles := fs.String("tablet-profiles", defaultTabletProfilesFile(), "Path to a JSON file of additional tablet model profiles that are checked before the built in ones.")
+		return func(ctx context.Context) error {
+			client, err := conn.dial(ctx)
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			profiles, err := remouseable.LoadTabletProfiles(*tabletProfiles)
+			if err != nil {
+				return err
+			}
+			info, detectErr := remouseable.DetectTablet(tabletCommandRunner(client.SSHConnection), profiles)
+			fmt.Printf("address:       %s\n", client.RemoteAddr())
+			fmt.Printf("ssh server:    %s\n", client.ServerVersion())
+			fmt.Printf("model:         %s\n", info.Model)
+			if detectErr != nil {
+				fmt.Printf("profile:       none (%s)\n", detectErr)
+			} else {
+				fmt.Printf("profile:       %s\n", info.Profile.Name)
+				fmt.Printf("event file:    %s\n", info.EventFile)
+				fmt.Printf("tablet width:  %d\n", info.Profile.TabletWidth)
+				fmt.Printf("tablet height: %d\n", info.Profile.TabletHeight)
+				fmt.Printf("event layout:  %s\n", info.Profile.EventLayout)
+			}
+			fmt.Println("input devices:")
+			for _, d := range info.Devices {
+				fmt.Printf("  %-20s %s\n", d.EventFile(), strings.TrimSpace(d.Name))
+			}
+			return nil
+		}
+	},
+}
@@ -0,0 +1,75 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+var recordCommand = command{
+	name:    "record",
+	summary: "Save the raw events from the tablet to a file so they can be replayed later.",
+	setup: func(fs *flag.FlagSet) func(context.Context) error {
+		conn := addConnectionFlags(fs)
+		tablet := addTabletFlags(fs)
+		output := fs.StringP("output", "o", "remouseable.events", "Path to the file to write events to. Use - to write to standard output.")
+		duration := fs.Duration("duration", 0, "How long to record for. Set to 0 to record until interrupted.")
+		return func(ctx context.Context) error {
+			var out io.WriteCloser = os.Stdout
+			if *output != "-" {
+				f, err := os.Create(*output)
+				if err != nil {
+					return err
+				}
+				out = f
+			}
+			defer out.Close()
+
+			stream, err := openTabletStream(ctx, conn, tablet)
+			if err != nil {
+				return err
+			}
+			var expired atomic.Bool
+			if *duration > 0 {
+				timer := time.AfterFunc(*duration, func() {
+					expired.Store(true)
+					_ = stream.Close()
+				})
+				defer timer.Stop()
+			}
+			stop := context.AfterFunc(ctx, func() {
+				_ = stream.Close()
+			})
+			defer stop()
+			fmt.Fprintf(os.Stderr, "recording %s events from %s. Replay them with --event-layout=%s\n", stream.Layout, *tablet.evtFile, stream.Layout)
+			_, err = io.Copy(out, stream)
+			closeErr := stream.Close()
+			if expired.Load() || ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			return closeErr
+		}
+	},
+}
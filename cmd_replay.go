@@ -0,0 +1,117 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	remouseable "github.com/kevinconway/remouseable/pkg"
+)
+
+var replayCommand = command{
+	name:    "replay",
+	summary: "Use events saved by the record command, evtest, or libinput record to move the mouse.",
+	setup: func(fs *flag.FlagSet) func(context.Context) error {
+		mouse := addMouseFlags(fs)
+		input := fs.StringP("input", "i", "remouseable.events", "Path to the file to read events from. Use - to read from standard input.")
+		layout := fs.String("event-layout", remouseable.EventLayoutTimeval32, "The layout of the recorded events. Choices are timeval32 and timeval64.")
+		format := fs.String("input-format", remouseable.CaptureFormatRaw, fmt.Sprintf("The format of the input file. Choices are %s. %s is the output of the record command, %s is the text printed by the evtest tool, and %s is the YAML written by libinput record.", strings.Join(remouseable.CaptureFormats, ", "), remouseable.CaptureFormatRaw, remouseable.CaptureFormatEvtest, remouseable.CaptureFormatLibinput))
+		fast := fs.Bool("fast", false, "Replay the events as quickly as possible rather than at the speed they were recorded.")
+		return func(ctx context.Context) error {
+			if err := checkCaptureFlags(*format, *layout); err != nil {
+				return err
+			}
+			driver, err := mouse.openDriver()
+			if err != nil {
+				return err
+			}
+			defer remouseable.CloseDriver(driver)
+			capture, err := openCapture(*input, *format, *layout)
+			if err != nil {
+				return err
+			}
+			var it remouseable.EvdevIterator = &remouseable.SelectingEvdevIterator{
+				Wrapped: &remouseable.ContextEvdevIterator{
+					Wrapped: capture,
+					Context: ctx,
+				},
+				Selection: []remouseable.EventType{remouseable.EV_ABS},
+			}
+			if !*fast {
+				it = &remouseable.PacedEvdevIterator{Wrapped: it, Sleep: contextSleep(ctx)}
+			}
+			err = mouse.run(ctx, driver, it)
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	},
+}
+
+// checkCaptureFlags returns an error for an unknown input format or layout.
+func checkCaptureFlags(format string, layout string) error {
+	switch layout {
+	case remouseable.EventLayoutTimeval32, remouseable.EventLayoutTimeval64:
+	default:
+		return fmt.Errorf("unknown event layout selection %s", layout)
+	}
+	switch format {
+	case remouseable.CaptureFormatRaw, remouseable.CaptureFormatEvtest, remouseable.CaptureFormatLibinput:
+	default:
+		return fmt.Errorf("unknown input format selection %s", format)
+	}
+	return nil
+}
+
+// openCapture returns an iterator over the events in a capture file. A path
+// of - reads from standard input.
+func openCapture(input string, format string, layout string) (remouseable.EvdevIterator, error) {
+	var in io.ReadCloser = os.Stdin
+	if input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			return nil, err
+		}
+		in = f
+	}
+	it, err := remouseable.NewCaptureEvdevIterator(format, in, layout)
+	if err != nil {
+		_ = in.Close()
+		return nil, err
+	}
+	return it, nil
+}
+
+// contextSleep returns a sleep function that wakes early when ctx is done so
+// that a long pause in a recording does not delay stopping.
+func contextSleep(ctx context.Context) func(time.Duration) {
+	return func(d time.Duration) {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+
+	remouseable "github.com/kevinconway/remouseable/pkg"
+)
+
+var runCommand = command{
+	name:    "run",
+	summary: "Use the tablet as a mouse.",
+	setup: func(fs *flag.FlagSet) func(context.Context) error {
+		conn := addConnectionFlags(fs)
+		tablet := addTabletFlags(fs)
+		mouse := addMouseFlags(fs)
+		debugEvents := fs.Bool("debug-events", false, "Stream hardware events from the tablet instead of acting as a mouse. This is for debugging.")
+		_ = fs.MarkDeprecated("debug-events", "use remouseable debug instead")
+		return func(ctx context.Context) error {
+			if *debugEvents {
+				return streamDebugEvents(ctx, conn, tablet, debugOptions{format: remouseable.DefaultEventFormat})
+			}
+			driver, err := mouse.openDriver()
+			if err != nil {
+				return err
+			}
+			defer remouseable.CloseDriver(driver)
+			stream, err := openTabletStream(ctx, conn, tablet)
+			if err != nil {
+				return err
+			}
+			mouse.useDetected(stream.Info)
+			fmt.Println("remouseable connected and running.")
+			return mouse.run(ctx, driver, stream.iterator(ctx))
+		}
+	},
+}
+
+// mouseFlags are the options that control how pen events become mouse
+// events.
+type mouseFlags struct {
+	fs                *flag.FlagSet
+	driver            *string
+	orientation       *string
+	tabletHeight      *int
+	tabletWidth       *int
+	screenHeight      *int
+	screenWidth       *int
+	disableDrag       *bool
+	pressureThreshold *int
+	calibration       *string
+	matrix            *[]float64
+	queueSize         *int
+	overflow          *string
+}
+
+func addMouseFlags(fs *flag.FlagSet) *mouseFlags {
+	return &mouseFlags{
+		fs:                fs,
+		driver:            fs.String("driver", remouseable.DefaultDriver, fmt.Sprintf("How to control the mouse of the host. Choices are %s, %s. The auto driver uses uinput in a Linux Wayland session and robotgo, or xtest in builds without cgo, otherwise. The log driver prints each action as a line of JSON and the null driver does nothing.", remouseable.DriverAuto, strings.Join(remouseable.DriverNames(), ", "))),
+		orientation:       fs.String("orientation", "right", "Orientation of the tablet. Choices are vertical, right, and left"),
+		tabletHeight:      fs.Int("tablet-height", remouseable.DefaultTabletHeight, "The max units per millimeter for the hight of the tablet. Probably don't change this."),
+		tabletWidth:       fs.Int("tablet-width", remouseable.DefaultTabletWidth, "The max units per millimeter for the width of the tablet. Probably don't change this."),
+		screenHeight:      fs.Int("screen-height", 0, "The max units per millimeter of the host screen height. The size reported by the driver is used if this is 0. Probably don't change this."),
+		screenWidth:       fs.Int("screen-width", 0, "The max units per millimeter of the host screen width. The size reported by the driver is used if this is 0. Probably don't change this."),
+		disableDrag:       fs.Bool("disable-drag-event", false, "Disable use of the custom OSX drag event. Only use this drawing on an Apple device is not working as expected."),
+		pressureThreshold: fs.Int("pressure-threshold", 1000, "Change the click detection sensitivity. 1000 is when the pen makes contact with the tablet. Set higher to require more pen pressure for a click."),
+		matrix:            fs.Float64Slice("matrix", nil, "Nine comma separated values, in row major order, of a 3x3 affine or projective matrix that maps raw tablet coordinates to the screen. It replaces the orientation, dimensions, and calibration."),
+		calibration:       fs.String("calibration", defaultCalibrationFile(), "Path to a calibration file written by the calibrate command. It is used instead of the tablet dimensions if it exists. Set to an empty value to ignore it."),
+		queueSize:         fs.Int("queue-size", remouseable.DefaultQueueSize, "The number of pen events, and of mouse actions, that may wait while the mouse catches up. Set to 0 to read the pen and move the mouse one event at a time."),
+		overflow:          fs.String("overflow", remouseable.DefaultOverflow, fmt.Sprintf("What to do with hover moves when the mouse can not keep up. Choices are %s, %s, and %s. %s keeps only the latest hover position, %s skips new hover moves, and %s waits. Clicks and drawing always wait.", remouseable.OverflowCoalesce, remouseable.OverflowDrop, remouseable.OverflowBlock, remouseable.OverflowCoalesce, remouseable.OverflowDrop, remouseable.OverflowBlock)),
+	}
+}
+
+// openDriver creates the selected driver and uses it to fill in the screen
+// size unless it was given as an option. The driver must be closed with
+// remouseable.CloseDriver.
+func (m *mouseFlags) openDriver() (remouseable.Driver, error) {
+	name := *m.driver
+	wayland := remouseable.IsWaylandSession(os.Getenv)
+	switch {
+	case name == remouseable.DriverAuto:
+		detected, err := remouseable.DetectDriver(os.Getenv)
+		if err != nil {
+			return nil, err
+		}
+		name = detected
+		if wayland {
+			fmt.Fprintf(os.Stderr, "detected a Wayland session so the %s driver is used\n", name)
+		}
+	case (name == remouseable.DriverRobotgo || name == remouseable.DriverXTest) && wayland:
+		fmt.Fprintf(os.Stderr, "the %s driver does not work in a Wayland session. Use --driver=%s instead.\n", name, remouseable.DriverUinput)
+	}
+	driver, err := remouseable.NewDriver(name, remouseable.DriverOptions{
+		ScreenWidth:  *m.screenWidth,
+		ScreenHeight: *m.screenHeight,
+		Output:       os.Stdout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if *m.screenWidth > 0 && *m.screenHeight > 0 {
+		return driver, nil
+	}
+	width, height, err := driver.GetSize()
+	if err != nil {
+		_ = remouseable.CloseDriver(driver)
+		return nil, err
+	}
+	if *m.screenWidth <= 0 {
+		*m.screenWidth = width
+	}
+	if *m.screenHeight <= 0 {
+		*m.screenHeight = height
+	}
+	return driver, nil
+}
+
+// useDetected replaces the tablet dimensions with those of a detected tablet
+// unless they were given as options.
+func (m *mouseFlags) useDetected(info *remouseable.TabletInfo) {
+	if info == nil {
+		return
+	}
+	if !m.fs.Changed("tablet-width") && info.Profile.TabletWidth > 0 {
+		*m.tabletWidth = info.Profile.TabletWidth
+	}
+	if !m.fs.Changed("tablet-height") && info.Profile.TabletHeight > 0 {
+		*m.tabletHeight = info.Profile.TabletHeight
+	}
+}
+
+func (m *mouseFlags) scaler() (remouseable.PositionScaler, error) {
+	if len(*m.matrix) > 0 {
+		if len(*m.matrix) != 9 {
+			return nil, fmt.Errorf("--matrix needs 9 values but %d were given", len(*m.matrix))
+		}
+		var matrix remouseable.Matrix
+		for i, v := range *m.matrix {
+			matrix[i/3][i%3] = v
+		}
+		return &remouseable.MatrixPositionScaler{Matrix: matrix}, nil
+	}
+	if *m.calibration != "" {
+		c, err := remouseable.LoadCalibration(*m.calibration)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case c == nil:
+		case m.fs.Changed("orientation") && *m.orientation != c.Orientation:
+			fmt.Fprintf(os.Stderr, "ignoring the calibration in %s because it was made for the %s orientation\n", *m.calibration, c.Orientation)
+		default:
+			return &remouseable.CalibratedPositionScaler{
+				Calibration:  *c,
+				ScreenWidth:  *m.screenWidth,
+				ScreenHeight: *m.screenHeight,
+			}, nil
+		}
+	}
+	switch *m.orientation {
+	case "right":
+		return &remouseable.RightPositionScaler{
+			TabletWidth:  *m.tabletWidth,
+			TabletHeight: *m.tabletHeight,
+			ScreenWidth:  *m.screenWidth,
+			ScreenHeight: *m.screenHeight,
+		}, nil
+	case "left":
+		return &remouseable.LeftPositionScaler{
+			TabletWidth:  *m.tabletWidth,
+			TabletHeight: *m.tabletHeight,
+			ScreenWidth:  *m.screenWidth,
+			ScreenHeight: *m.screenHeight,
+		}, nil
+	case "vertical":
+		return &remouseable.VerticalPositionScaler{
+			TabletWidth:  *m.tabletWidth,
+			TabletHeight: *m.tabletHeight,
+			ScreenWidth:  *m.screenWidth,
+			ScreenHeight: *m.screenHeight,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown orienation selection %s", *m.orientation)
+	}
+}
+
+func (m *mouseFlags) stateMachine(it remouseable.EvdevIterator) remouseable.StateMachine {
+	if *m.disableDrag {
+		return &remouseable.EvdevStateMachine{
+			Iterator:          it,
+			PressureThreshold: *m.pressureThreshold,
+		}
+	}
+	return &remouseable.DraggingEvdevStateMachine{
+		EvdevStateMachine: &remouseable.EvdevStateMachine{
+			Iterator:          it,
+			PressureThreshold: *m.pressureThreshold,
+		},
+	}
+}
+
+// run moves the mouse until the iterator is exhausted. The iterator is
+// closed before returning.
+func (m *mouseFlags) run(ctx context.Context, driver remouseable.Driver, it remouseable.EvdevIterator) error {
+	if *m.queueSize > 0 {
+		// Read the events on their own goroutine so that a slow driver does
+		// not hold up the stream from the tablet.
+		it = &remouseable.QueuedEvdevIterator{Wrapped: it, Size: *m.queueSize}
+	}
+	sm := m.stateMachine(it)
+	sc, err := m.scaler()
+	if err != nil {
+		_ = sm.Close()
+		return err
+	}
+	rt := &remouseable.Runtime{
+		PositionScaler: sc,
+		StateMachine:   sm,
+		Driver:         driver,
+		QueueSize:      *m.queueSize,
+		Overflow:       *m.overflow,
+	}
+	return rt.Run(ctx)
+}
@@ -0,0 +1,309 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+
+	remouseable "github.com/kevinconway/remouseable/pkg"
+)
+
+// discoveryFlags are the options for searching for tablets.
+type discoveryFlags struct {
+	mdns    *bool
+	scan    *bool
+	timeout *time.Duration
+}
+
+func addDiscoveryFlags(fs *flag.FlagSet) *discoveryFlags {
+	return &discoveryFlags{
+		mdns:    fs.Bool("discover-mdns", false, "Also ask for tablets using mDNS when searching for tablets."),
+		scan:    fs.Bool("discover-scan", false, "Also scan the local networks when searching for tablets. Only tablets whose SSH server is dropbear, as on older tablet software, are found this way."),
+		timeout: fs.Duration("discover-timeout", time.Second, "How long to wait for each host to respond when searching for tablets."),
+	}
+}
+
+func (d *discoveryFlags) discoverer() *remouseable.Discoverer {
+	return &remouseable.Discoverer{
+		Timeout:     *d.timeout,
+		Concurrency: discoveryConcurrency,
+		Scan:        *d.scan,
+		MDNS:        *d.mdns,
+	}
+}
+
+// connectionFlags are the options shared by every command that connects to a
+// tablet.
+type connectionFlags struct {
+	fs               *flag.FlagSet
+	sshIP            *string
+	sshHost          *string
+	sshConfigFile    *string
+	sshUser          *string
+	sshPassword      *string
+	sshSocket        *string
+	sshKeys          *[]string
+	sshKeepalive     *time.Duration
+	sshTimeout       *time.Duration
+	sshKnownHosts    *string
+	sshHostKeyPolicy *string
+	discovery        *discoveryFlags
+}
+
+func addConnectionFlags(fs *flag.FlagSet) *connectionFlags {
+	return &connectionFlags{
+		fs:               fs,
+		sshIP:            fs.String("ssh-ip", remouseable.DefaultUSBAddress, "The host and port of a tablet. Use auto to search for the tablet and connect to it if exactly one is found."),
+		sshHost:          fs.String("ssh-host", "", "A host name or alias to resolve using the SSH config file instead of using --ssh-ip. The HostName, Port, User, IdentityFile, and ProxyJump settings are honored."),
+		sshConfigFile:    fs.String("ssh-config", defaultSSHConfigFile(), "Path to the SSH client configuration file that is used to resolve --ssh-host."),
+		sshUser:          fs.String("ssh-user", "root", "The ssh username to use when logging into the tablet."),
+		sshPassword:      fs.String("ssh-password", "", "An optional password to use when ssh-ing into the tablet. Use - for a prompt rather than entering a value or set REMOUSEABLE_SSH_PASSWORD to keep it out of your shell history. It is tried after any agent or key file authentication."),
+		sshSocket:        fs.String("ssh-socket", os.Getenv("SSH_AUTH_SOCK"), "Path to the SSH auth socket. Agent authentication is skipped if this is empty."),
+		sshKeys:          fs.StringArray("ssh-key", nil, "Path to a private key file to use when ssh-ing into the tablet. May be given more than once. Encrypted keys prompt for a passphrase."),
+		sshKeepalive:     fs.Duration("ssh-keepalive", 5*time.Second, "How often to check that the tablet is still responding. Set to 0 to disable keepalive checks."),
+		sshTimeout:       fs.Duration("ssh-timeout", 20*time.Second, "How long the tablet may go without responding before the connection is considered dead. Set to 0 to wait forever."),
+		sshKnownHosts:    fs.String("ssh-known-hosts", defaultKnownHostsFile(), "Path to a known_hosts file that remouseable uses to record tablet host keys. It is checked before ~/.ssh/known_hosts."),
+		sshHostKeyPolicy: fs.String("ssh-host-key-policy", remouseable.HostKeyPolicyPrompt, "How to handle unknown tablet host keys. Choices are prompt, accept-new, and strict. Changed keys are only ever replaced when prompted."),
+		discovery:        addDiscoveryFlags(fs),
+	}
+}
+
+// tabletConnection is an SSH connection to a tablet along with the
+// authentication state that must be released when it is closed.
+type tabletConnection struct {
+	*remouseable.SSHConnection
+	auths []*remouseable.SSHAuth
+}
+
+// Close the connection and any agent connections used to authenticate.
+func (c *tabletConnection) Close() error {
+	err := c.SSHConnection.Close()
+	for _, auth := range c.auths {
+		_ = auth.Close()
+	}
+	return err
+}
+
+// dial connects to the tablet. Cancelling ctx stops a search for the tablet.
+func (c *connectionFlags) dial(ctx context.Context) (*tabletConnection, error) {
+	if *c.sshPassword == "-" {
+		fmt.Print("Enter Password: ")
+		pwd, err := term.ReadPassword(int(syscall.Stdin))
+		fmt.Println()
+		if err != nil {
+			return nil, err
+		}
+		*c.sshPassword = string(pwd)
+	}
+	switch *c.sshHostKeyPolicy {
+	case remouseable.HostKeyPolicyPrompt, remouseable.HostKeyPolicyAcceptNew, remouseable.HostKeyPolicyStrict:
+	default:
+		return nil, fmt.Errorf("unknown host key policy selection %s", *c.sshHostKeyPolicy)
+	}
+	hostKeys := &remouseable.HostKeyVerifier{
+		Files:     userKnownHostsFiles(),
+		TrustFile: *c.sshKnownHosts,
+		Policy:    *c.sshHostKeyPolicy,
+		Prompt:    promptYesNo,
+	}
+	if *c.sshHost == "" && *c.sshIP == "auto" {
+		address, err := discoverTablet(ctx, c.discovery.discoverer())
+		if err != nil {
+			return nil, err
+		}
+		*c.sshIP = address
+	}
+	target, err := resolveTarget(*c.sshHost, *c.sshConfigFile, *c.sshIP)
+	if err != nil {
+		return nil, err
+	}
+	if target.User == "" || c.fs.Changed("ssh-user") {
+		target.User = *c.sshUser
+	}
+	auths := make([]*remouseable.SSHAuth, 0, len(target.ProxyJump)+1)
+	client, err := remouseable.DialSSH(target, func(hop remouseable.SSHTarget) (*ssh.ClientConfig, error) {
+		auth := &remouseable.SSHAuth{
+			AgentSocket: *c.sshSocket,
+			KeyFiles:    append(append([]string{}, *c.sshKeys...), hop.IdentityFiles...),
+			Passphrase:  promptPassphrase,
+			Warn: func(err error) {
+				fmt.Fprintln(os.Stderr, err)
+			},
+		}
+		if hop.Address() == target.Address() {
+			// The tablet password is never offered to a jump host.
+			auth.Password = *c.sshPassword
+		}
+		auths = append(auths, auth)
+		authMethods, authErr := auth.Methods()
+		if authErr != nil {
+			return nil, authErr
+		}
+		if hop.User == "" {
+			hop.User = localUsername()
+		}
+		return &ssh.ClientConfig{
+			User:              hop.User,
+			Auth:              authMethods,
+			HostKeyAlgorithms: hostKeys.HostKeyAlgorithms(hop.Address()),
+			HostKeyCallback:   hostKeys.Callback(),
+			Timeout:           *c.sshTimeout,
+		}, nil
+	})
+	if err != nil {
+		for _, auth := range auths {
+			_ = auth.Close()
+		}
+		return nil, err
+	}
+	return &tabletConnection{SSHConnection: client, auths: auths}, nil
+}
+
+// tabletFlags are the options that select which events to read from a
+// tablet.
+type tabletFlags struct {
+	fs             *flag.FlagSet
+	evtFile        *string
+	detectTablet   *bool
+	tabletProfiles *string
+}
+
+func addTabletFlags(fs *flag.FlagSet) *tabletFlags {
+	return &tabletFlags{
+		fs:             fs,
+		evtFile:        fs.String("event-file", "/dev/input/event0", "The path on the tablet from which to read evdev events. This is detected automatically unless --detect-tablet is disabled. Probably don't change this."),
+		detectTablet:   fs.Bool("detect-tablet", true, "Detect the tablet model and use it to choose the event file and tablet dimensions. Values given with --event-file, --tablet-width, and --tablet-height take precedence."),
+		tabletProfiles: fs.String("tablet-profiles", defaultTabletProfilesFile(), "Path to a JSON file of additional tablet model profiles that are checked before the built in ones."),
+	}
+}
+
+// detect identifies the tablet. It returns nil if detection is disabled or
+// the tablet is not recognized.
+func (t *tabletFlags) detect(client *tabletConnection) (*remouseable.TabletInfo, error) {
+	if !*t.detectTablet {
+		return nil, nil
+	}
+	profiles, err := remouseable.LoadTabletProfiles(*t.tabletProfiles)
+	if err != nil {
+		return nil, err
+	}
+	info, err := remouseable.DetectTablet(tabletCommandRunner(client.SSHConnection), profiles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not detect the tablet model so the default settings are used: %s\n", err)
+		return nil, nil
+	}
+	fmt.Fprintf(os.Stderr, "detected %s using %s\n", info.Profile.Name, info.EventFile)
+	return &info, nil
+}
+
+// tabletStream is the stream of raw events from the event file of a tablet.
+type tabletStream struct {
+	io.ReadCloser
+	// Layout is the event layout of the tablet.
+	Layout string
+	// Info is the detected tablet or nil if it was not detected.
+	Info    *remouseable.TabletInfo
+	client  *tabletConnection
+	session *ssh.Session
+}
+
+// open connects to the tablet and starts reading from the event file.
+func openTabletStream(ctx context.Context, conn *connectionFlags, tablet *tabletFlags) (*tabletStream, error) {
+	client, err := conn.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info, err := tablet.detect(client)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	layout := remouseable.EventLayoutTimeval32
+	if info != nil {
+		if !tablet.fs.Changed("event-file") {
+			*tablet.evtFile = info.EventFile
+		}
+		if info.Profile.EventLayout != "" {
+			layout = info.Profile.EventLayout
+		}
+	}
+
+	sesh, err := client.NewSession()
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	pipe, err := sesh.StdoutPipe()
+	if err != nil {
+		_ = sesh.Close()
+		_ = client.Close()
+		return nil, err
+	}
+	if err = sesh.Start(fmt.Sprintf("cat %s", *tablet.evtFile)); err != nil {
+		_ = sesh.Close()
+		_ = client.Close()
+		return nil, err
+	}
+	return &tabletStream{
+		ReadCloser: &remouseable.KeepaliveReadCloser{
+			Source:   io.NopCloser(pipe),
+			Conn:     client,
+			Interval: *conn.sshKeepalive,
+			Timeout:  *conn.sshTimeout,
+		},
+		Layout:  layout,
+		Info:    info,
+		client:  client,
+		session: sesh,
+	}, nil
+}
+
+// Close the event stream and the connection to the tablet.
+func (s *tabletStream) Close() error {
+	err := s.ReadCloser.Close()
+	_ = s.session.Close()
+	if closeErr := s.client.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// iterator returns an iterator over the given event types. The pen position
+// and pressure events are selected when no types are given. The stream is
+// closed when ctx is done.
+func (s *tabletStream) iterator(ctx context.Context, types ...remouseable.EventType) remouseable.EvdevIterator {
+	if len(types) == 0 {
+		types = []remouseable.EventType{remouseable.EV_ABS}
+	}
+	return &remouseable.SelectingEvdevIterator{
+		Wrapped: &remouseable.ContextEvdevIterator{
+			Wrapped: &remouseable.FileEvdevIterator{
+				Source: s,
+				Layout: s.Layout,
+			},
+			Context: ctx,
+		},
+		Selection: types,
+	}
+}
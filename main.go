@@ -15,181 +15,362 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
 	"syscall"
 
 	flag "github.com/spf13/pflag"
-	"golang.org/x/crypto/ssh"
-	"golang.org/x/crypto/ssh/agent"
 	"golang.org/x/term"
 
 	remouseable "github.com/kevinconway/remouseable/pkg"
 )
 
-func main() {
+// discoveryConcurrency is how many hosts are probed at once when searching
+// for tablets.
+const discoveryConcurrency = 64
 
-	driver := &remouseable.RobotgoDriver{}
-
-	fs := flag.NewFlagSet("remouseable", flag.ExitOnError)
-	orientation := fs.String("orientation", "right", "Orientation of the tablet. Choices are vertical, right, and left")
-	tabletHeight := fs.Int("tablet-height", remouseable.DefaultTabletHeight, "The max units per millimeter for the hight of the tablet. Probably don't change this.")
-	tabletWidth := fs.Int("tablet-width", remouseable.DefaultTabletWidth, "The max units per millimeter for the width of the tablet. Probably don't change this.")
-	tmpScreenWidth, tmpScreenHeight, _ := driver.GetSize()
-	screenHeight := fs.Int("screen-height", tmpScreenHeight, "The max units per millimeter of the host screen height. Probably don't change this.")
-	screenWidth := fs.Int("screen-width", tmpScreenWidth, "The max units per millimeter of the host screen width. Probably don't change this.")
-	sshIP := fs.String("ssh-ip", "10.11.99.1:22", "The host and port of a tablet.")
-	sshUser := fs.String("ssh-user", "root", "The ssh username to use when logging into the tablet.")
-	sshPassword := fs.String("ssh-password", "", "An optional password to use when ssh-ing into the tablet. Use - for a prompt rather than entering a value. If not given then public/private keypair authentication is used.")
-	sshSocket := fs.String("ssh-socket", os.Getenv("SSH_AUTH_SOCK"), "Path to the SSH auth socket. This must not be empty if using public/private keypair authentication.")
-	evtFile := fs.String("event-file", "/dev/input/event0", "The path on the tablet from which to read evdev events. Probably don't change this.")
-	debugEvents := fs.Bool("debug-events", false, "Stream hardware events from the tablet instead of acting as a mouse. This is for debugging.")
-	disableDrag := fs.Bool("disable-drag-event", false, "Disable use of the custom OSX drag event. Only use this drawing on an Apple device is not working as expected.")
-	pressureThreshold := fs.Int("pressure-threshold", 1000, "Change the click detection sensitivity. 1000 is when the pen makes contact with the tablet. Set higher to require more pen pressure for a click.")
-	_ = fs.Parse(os.Args[1:])
-
-	if *sshPassword == "-" {
-		fmt.Print("Enter Password: ")
-		pwd, err := term.ReadPassword(int(syscall.Stdin))
-		if err != nil {
-			panic(err)
+// command is a remouseable subcommand.
+type command struct {
+	name    string
+	summary string
+	// setup registers the options of the command and returns the function
+	// that runs it once the options are parsed. The context given to that
+	// function is cancelled when the process is interrupted.
+	setup func(fs *flag.FlagSet) func(ctx context.Context) error
+}
+
+// commands are all of the subcommands. The first is the default when no
+// command is given.
+var commands = []command{
+	runCommand,
+	debugCommand,
+	recordCommand,
+	replayCommand,
+	convertCommand,
+	discoverCommand,
+	infoCommand,
+	calibrateCommand,
+}
+
+func main() {
+	args := os.Args[1:]
+	cmd := commands[0]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if args[0] == "help" {
+			printCommands(os.Stdout)
+			return
 		}
-		*sshPassword = string(pwd)
-	}
-	sshConfig := &ssh.ClientConfig{
-		User: *sshUser,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(*sshPassword),
-		},
-		HostKeyAlgorithms: []string{
-			"ecdsa-sha2-nistp256",
-			"ecdsa-sha2-nistp384",
-			"ecdsa-sha2-nistp521",
-			"ssh-ed25519",
-			"rsa-sha2-256",
-			"rsa-sha2-512",
-			"ssh-rsa",
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), //nolint:gosec
-	}
-	if *sshPassword == "" {
-		agentFd, err := net.Dial("unix", *sshSocket)
-		if err != nil {
-			panic(err)
+		found, ok := lookupCommand(args[0])
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown command %s\n\n", args[0])
+			printCommands(os.Stderr)
+			os.Exit(2)
 		}
-		defer agentFd.Close()
-
-		agentSigner := agent.NewClient(agentFd)
+		cmd = found
+		args = args[1:]
+	}
 
-		sshConfig.Auth = []ssh.AuthMethod{
-			ssh.PublicKeysCallback(agentSigner.Signers),
+	fs := flag.NewFlagSet("remouseable "+cmd.name, flag.ExitOnError)
+	opts := addOptionFlags(fs)
+	run := cmd.setup(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: remouseable %s [options]\n\n%s\n\nOptions:\n%s\nEvery option may also be set with an environment variable such as %s for --ssh-password.\n", cmd.name, cmd.summary, fs.FlagUsages(), remouseable.EnvVarName("ssh-password"))
+		if cmd.name == commands[0].name {
+			fmt.Fprintln(os.Stderr)
+			printCommands(os.Stderr)
 		}
 	}
+	_ = fs.Parse(args)
+	if err := opts.load(fs); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-	client, err := ssh.Dial("tcp", *sshIP, sshConfig)
+	// The first interrupt cancels the context so that the command can stop
+	// cleanly. Default handling is then restored so that a second interrupt
+	// ends the process right away.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	err := run(ctx)
+	if ctx.Err() != nil {
+		// Errors from cutting off the tablet stream are expected here.
+		return
+	}
+	stop()
 	if err != nil {
-		panic(err)
+		exitOnUnreachable(err)
+		fmt.Fprintf(os.Stderr, "remouseable %s: %s\n", cmd.name, err)
+		os.Exit(1)
 	}
+}
 
-	sesh, err := client.NewSession()
-	if err != nil {
-		panic(err)
+func lookupCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
 	}
-	defer sesh.Close()
+	return command{}, false
+}
 
-	pipe, err := sesh.StdoutPipe()
+func printCommands(w io.Writer) {
+	fmt.Fprintln(w, "Usage: remouseable [command] [options]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for i, c := range commands {
+		suffix := ""
+		if i == 0 {
+			suffix = " (default)"
+		}
+		fmt.Fprintf(w, "  %-10s %s%s\n", c.name, c.summary, suffix)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Use remouseable [command] --help to see the options of a command.")
+}
+
+// optionFlags select where option values are loaded from.
+type optionFlags struct {
+	configFile *string
+	profile    *string
+}
+
+func addOptionFlags(fs *flag.FlagSet) *optionFlags {
+	return &optionFlags{
+		configFile: fs.String("config", defaultConfigFile(), "Path to the configuration file that contains option profiles."),
+		profile:    fs.String("profile", "", "Name of the profile in the configuration file to load option values from. Options given on the command line take precedence over the profile."),
+	}
+}
+
+// pathOptions are the options of any command that take file paths.
+var pathOptions = []string{
+	"calibration",
+	"input",
+	"output",
+	"ssh-config",
+	"ssh-key",
+	"ssh-known-hosts",
+	"tablet-profiles",
+}
+
+// load layers the environment and the selected profile underneath the
+// command line and reports where each value came from.
+func (o *optionFlags) load(fs *flag.FlagSet) error {
+	// Options are layered in the order command line, environment, and then
+	// profile. Each layer only fills in what the ones before it did not set.
+	remouseable.MarkPathFlags(fs, pathOptions...)
+	sources := remouseable.NewFlagSources(fs)
+	if err := sources.ApplyEnv(fs, os.LookupEnv); err != nil {
+		return err
+	}
+	cfg, err := remouseable.LoadConfig(*o.configFile)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	if err = sesh.Start(fmt.Sprintf("cat %s", *evtFile)); err != nil {
-		panic(err)
+	values, err := cfg.Values(*o.profile)
+	if err != nil {
+		return err
 	}
-	if *debugEvents {
-		it := &remouseable.SelectingEvdevIterator{
-			Wrapped: &remouseable.FileEvdevIterator{
-				Source: io.NopCloser(pipe),
-			},
-			Selection: []uint16{remouseable.EV_ABS},
-		}
-		defer it.Close()
-		fmt.Println("remouseable connected and running.")
-		for it.Next() {
-			evt := it.Current()
-			evtype := remouseable.EVMap[evt.Type]
-			evcode := remouseable.CodeString(evt.Type, evt.Code)
-			fmt.Printf(
-				`{"eventType": %d, "eventTypeName": "%s", "eventCode": %d, "eventCodeName": "%s", "eventValue": %d}`,
-				evt.Type, evtype, evt.Code, evcode, evt.Value,
-			)
-			fmt.Print("\n")
+	profileName := *o.profile
+	if profileName == "" {
+		profileName = cfg.Profile
+	}
+	// A profile is shared by every command so options that only apply to
+	// other commands are skipped. Names that no command knows are mistakes.
+	unknown := make([]string, 0)
+	for name := range values {
+		if fs.Lookup(name) == nil {
+			delete(values, name)
+			if !isCommandOption(name) {
+				unknown = append(unknown, name)
+			}
 		}
-		if err = it.Close(); err != nil {
-			panic(err.Error())
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%s: unknown options in profile %s: %s", *o.configFile, profileName, strings.Join(unknown, ", "))
+	}
+	if err = sources.Apply(fs, values, "profile "+profileName); err != nil {
+		return fmt.Errorf("%s: %w", *o.configFile, err)
+	}
+	for _, line := range sources.Describe(fs, "ssh-password") {
+		fmt.Fprintf(os.Stderr, "using %s\n", line)
+	}
+	return nil
+}
+
+// isCommandOption reports whether any command has an option with the name.
+func isCommandOption(name string) bool {
+	for _, c := range commands {
+		fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		c.setup(fs)
+		if fs.Lookup(name) != nil {
+			return true
 		}
-		return
 	}
+	return false
+}
 
-	it := &remouseable.SelectingEvdevIterator{
-		Wrapped: &remouseable.FileEvdevIterator{
-			Source: io.NopCloser(pipe),
-		},
-		Selection: []uint16{remouseable.EV_ABS},
+// resolveTarget determines where to connect. A host from the SSH config takes
+// precedence over the plain address.
+func resolveTarget(host string, configFile string, address string) (remouseable.SSHTarget, error) {
+	if host != "" {
+		cfg, err := remouseable.LoadSSHConfig(configFile)
+		if err != nil {
+			return remouseable.SSHTarget{}, err
+		}
+		return cfg.Resolve(host)
 	}
-	defer it.Close()
+	hostname, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return remouseable.SSHTarget{}, err
+	}
+	return remouseable.SSHTarget{Alias: hostname, HostName: hostname, Port: port}, nil
+}
 
-	var sm remouseable.StateMachine = &remouseable.DraggingEvdevStateMachine{
-		EvdevStateMachine: &remouseable.EvdevStateMachine{
-			Iterator:          it,
-			PressureThreshold: *pressureThreshold,
-		},
+// discoverTablet searches for a tablet and returns its address. It fails
+// unless exactly one tablet is found because guessing between several could
+// connect to someone else's tablet. The search stops early if ctx is
+// cancelled.
+func discoverTablet(ctx context.Context, d *remouseable.Discoverer) (string, error) {
+	candidates, err := d.Discover(ctx)
+	if err != nil {
+		return "", err
 	}
-	if *disableDrag {
-		sm = &remouseable.EvdevStateMachine{
-			Iterator:          it,
-			PressureThreshold: *pressureThreshold,
+	switch len(candidates) {
+	case 0:
+		return "", fmt.Errorf("no tablets found. Check that the tablet is awake and connected or set --ssh-ip")
+	case 1:
+		fmt.Printf("found tablet at %s\n", candidates[0].Address)
+		return candidates[0].Address, nil
+	default:
+		addresses := make([]string, 0, len(candidates))
+		for _, c := range candidates {
+			addresses = append(addresses, c.Address)
 		}
+		return "", fmt.Errorf("found more than one tablet. Choose one with --ssh-ip: %s", strings.Join(addresses, ", "))
 	}
-	defer sm.Close()
+}
 
-	var sc remouseable.PositionScaler
-	switch *orientation {
-	case "right":
-		sc = &remouseable.RightPositionScaler{
-			TabletWidth:  *tabletWidth,
-			TabletHeight: *tabletHeight,
-			ScreenWidth:  *screenWidth,
-			ScreenHeight: *screenHeight,
-		}
-	case "left":
-		sc = &remouseable.LeftPositionScaler{
-			TabletWidth:  *tabletWidth,
-			TabletHeight: *tabletHeight,
-			ScreenWidth:  *screenWidth,
-			ScreenHeight: *screenHeight,
-		}
-	case "vertical":
-		sc = &remouseable.VerticalPositionScaler{
-			TabletWidth:  *tabletWidth,
-			TabletHeight: *tabletHeight,
-			ScreenWidth:  *screenWidth,
-			ScreenHeight: *screenHeight,
+// defaultSSHConfigFile returns the location of the OpenSSH client config.
+func defaultSSHConfigFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ssh", "config")
+}
+
+// localUsername is the default login for jump hosts that have no User set,
+// matching the behavior of OpenSSH.
+func localUsername() string {
+	u, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	return u.Username
+}
+
+// defaultKnownHostsFile returns the location of the known_hosts file that is
+// managed by remouseable.
+func defaultKnownHostsFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "remouseable", "known_hosts")
+}
+
+// defaultConfigFile returns the location of the remouseable configuration
+// file.
+func defaultConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "remouseable", "config.json")
+}
+
+// defaultCalibrationFile returns the location of the calibration that is
+// written by the calibrate command.
+func defaultCalibrationFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "remouseable", "calibration.json")
+}
+
+// defaultTabletProfilesFile returns the location of the user's additional
+// tablet model profiles.
+func defaultTabletProfilesFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "remouseable", "tablets.json")
+}
+
+// tabletCommandRunner runs each command in a new session on the tablet.
+func tabletCommandRunner(client *remouseable.SSHConnection) remouseable.TabletCommandRunner {
+	return func(cmd string) ([]byte, error) {
+		sesh, err := client.NewSession()
+		if err != nil {
+			return nil, err
 		}
-	default:
-		panic(fmt.Sprintf("unknown orienation selection %s", *orientation))
+		defer sesh.Close()
+		return sesh.Output(cmd)
 	}
+}
 
-	rt := &remouseable.Runtime{
-		PositionScaler: sc,
-		StateMachine:   sm,
-		Driver:         driver,
+// userKnownHostsFiles returns the known_hosts files that OpenSSH would use.
+func userKnownHostsFiles() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
 	}
+	return []string{filepath.Join(home, ".ssh", "known_hosts")}
+}
+
+// promptPassphrase asks for the passphrase of an encrypted key file.
+func promptPassphrase(file string) ([]byte, error) {
+	fmt.Printf("Enter passphrase for %s: ", file)
+	defer fmt.Println()
+	return term.ReadPassword(int(syscall.Stdin))
+}
 
-	fmt.Println("remouseable connected and running.")
-	for rt.Next() {
+// promptYesNo asks a question on the terminal and waits for an answer.
+func promptYesNo(question string) (bool, error) {
+	fmt.Printf("%s (yes/no): ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
 	}
-	if err = rt.Close(); err != nil {
-		panic(err)
+}
+
+// exitOnUnreachable reports a lost tablet connection without a stack trace
+// because it is an expected condition rather than a bug.
+func exitOnUnreachable(err error) {
+	if errors.Is(err, remouseable.ErrTabletUnreachable) {
+		fmt.Fprintf(os.Stderr, "lost connection to the tablet: %s\n", err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,168 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnknownOverflow is returned when a runtime is given an overflow policy
+// that is not one of the Overflow* values.
+var ErrUnknownOverflow = errors.New("unknown overflow policy")
+
+const (
+	// DefaultQueueSize is the number of events, or driver actions, that may
+	// wait between the stages of the runtime.
+	DefaultQueueSize = 64
+	// OverflowCoalesce replaces the newest hover move in a full queue with the
+	// new one. Only the latest position of a hovering pen matters so nothing
+	// visible is lost.
+	OverflowCoalesce = "coalesce"
+	// OverflowDrop discards a hover move when the queue is full.
+	OverflowDrop = "drop"
+	// OverflowBlock waits for room in the queue for every action.
+	OverflowBlock = "block"
+	// DefaultOverflow is the overflow policy used when none is given.
+	DefaultOverflow = OverflowCoalesce
+)
+
+// queuedAction is a scaled state change that is waiting for the driver.
+type queuedAction struct {
+	kind string
+	x    float64
+	y    float64
+}
+
+// apply calls the driver method that matches the action.
+func (a queuedAction) apply(d Driver) error {
+	switch a.kind {
+	case ChangeTypeMove:
+		return d.MoveMouse(a.x, a.y)
+	case ChangeTypeDrag:
+		return d.DragMouse(a.x, a.y)
+	case ChangeTypeClick:
+		return d.Click()
+	case ChangeTypeUnclick:
+		return d.Unclick()
+	default:
+		return fmt.Errorf("encountered unhandled driver action %s", a.kind)
+	}
+}
+
+// actionQueue is a bounded queue of driver actions. Hover moves are handled
+// by the overflow policy when the queue is full. Every other action waits for
+// room because skipping a click, or part of a stroke, would change what is
+// drawn.
+type actionQueue struct {
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	items    []queuedAction
+	size     int
+	overflow string
+	closed   bool
+	// dropped counts the hover moves that were coalesced or dropped.
+	dropped int
+}
+
+func newActionQueue(size int, overflow string) (*actionQueue, error) {
+	switch overflow {
+	case "":
+		overflow = DefaultOverflow
+	case OverflowCoalesce, OverflowDrop, OverflowBlock:
+	default:
+		return nil, fmt.Errorf("%w %s", ErrUnknownOverflow, overflow)
+	}
+	if size < 1 {
+		size = 1
+	}
+	q := &actionQueue{
+		items:    make([]queuedAction, 0, size),
+		size:     size,
+		overflow: overflow,
+	}
+	q.notEmpty = sync.NewCond(&q.mu)
+	q.notFull = sync.NewCond(&q.mu)
+	return q, nil
+}
+
+// push adds an action to the queue. It returns false if the queue was closed.
+func (q *actionQueue) push(a queuedAction) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) >= q.size && a.kind == ChangeTypeMove {
+		switch q.overflow {
+		case OverflowCoalesce:
+			if last := len(q.items) - 1; q.items[last].kind == ChangeTypeMove {
+				q.items[last] = a
+				q.dropped++
+				return !q.closed
+			}
+		case OverflowDrop:
+			q.dropped++
+			return !q.closed
+		}
+	}
+	for len(q.items) >= q.size && !q.closed {
+		q.notFull.Wait()
+	}
+	if q.closed {
+		return false
+	}
+	q.items = append(q.items, a)
+	q.notEmpty.Signal()
+	return true
+}
+
+// pop removes the oldest action. It waits for an action and returns false
+// once the queue is closed and empty.
+func (q *actionQueue) pop() (queuedAction, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.items) == 0 && !q.closed {
+		q.notEmpty.Wait()
+	}
+	if len(q.items) == 0 {
+		return queuedAction{}, false
+	}
+	a := q.items[0]
+	copy(q.items, q.items[1:])
+	q.items = q.items[:len(q.items)-1]
+	q.notFull.Signal()
+	return a, true
+}
+
+// close stops new actions from being added. Actions that are already queued
+// are still returned by pop.
+func (q *actionQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+}
+
+// discard closes the queue and removes every queued action. It is used when
+// the driver fails and can no longer take actions.
+func (q *actionQueue) discard() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.items = q.items[:0]
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+}
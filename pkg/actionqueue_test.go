@@ -0,0 +1,84 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestActionQueueOverflow(t *testing.T) {
+	move := func(x float64) queuedAction {
+		return queuedAction{kind: ChangeTypeMove, x: x}
+	}
+	drain := func(q *actionQueue) []queuedAction {
+		q.close()
+		var out []queuedAction
+		for a, ok := q.pop(); ok; a, ok = q.pop() {
+			out = append(out, a)
+		}
+		return out
+	}
+
+	q, err := newActionQueue(2, OverflowCoalesce)
+	require.Nil(t, err)
+	require.True(t, q.push(move(1)))
+	require.True(t, q.push(move(2)))
+	require.True(t, q.push(move(3)))
+	require.Equal(t, 1, q.dropped)
+	require.Equal(t, []queuedAction{move(1), move(3)}, drain(q))
+	require.False(t, q.push(move(4)))
+
+	q, err = newActionQueue(1, OverflowDrop)
+	require.Nil(t, err)
+	require.True(t, q.push(move(1)))
+	require.True(t, q.push(move(2)))
+	require.Equal(t, []queuedAction{move(1)}, drain(q))
+
+	_, err = newActionQueue(1, "skip")
+	require.True(t, errors.Is(err, ErrUnknownOverflow))
+}
+
+func TestActionQueueBlocks(t *testing.T) {
+	// Clicks are never coalesced so the second push must wait for a pop.
+	q, err := newActionQueue(1, OverflowCoalesce)
+	require.Nil(t, err)
+	require.True(t, q.push(queuedAction{kind: ChangeTypeClick}))
+	pushed := make(chan bool)
+	go func() {
+		pushed <- q.push(queuedAction{kind: ChangeTypeUnclick})
+	}()
+	select {
+	case <-pushed:
+		t.Fatal("push did not wait for room in the queue")
+	case <-time.After(10 * time.Millisecond):
+	}
+	a, ok := q.pop()
+	require.True(t, ok)
+	require.Equal(t, ChangeTypeClick, a.kind)
+	require.True(t, <-pushed)
+
+	// A discarded queue releases any waiting push.
+	go func() {
+		pushed <- q.push(queuedAction{kind: ChangeTypeClick})
+	}()
+	q.discard()
+	require.False(t, <-pushed)
+	_, ok = q.pop()
+	require.False(t, ok)
+}
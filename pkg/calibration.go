@@ -0,0 +1,209 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// OrientationRight is the tablet held with the power button on the right.
+	OrientationRight = "right"
+	// OrientationLeft is the tablet held with the power button on the left.
+	OrientationLeft = "left"
+	// OrientationVertical is the tablet held with the power button on top.
+	OrientationVertical = "vertical"
+)
+
+// CalibrationCorners are the corners of the tablet, as it is held, in the
+// order that they are measured.
+var CalibrationCorners = [4]string{"top left", "top right", "bottom right", "bottom left"}
+
+// calibrationTargets are the screen positions, as a fraction of the screen
+// size, that match each of the CalibrationCorners.
+var calibrationTargets = [4][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+
+// Calibration describes how raw tablet coordinates map to the screen. It is
+// measured by touching each of the CalibrationCorners.
+type Calibration struct {
+	// Orientation is one of the Orientation* values.
+	Orientation string `json:"orientation"`
+	// MinX, MinY, MaxX, and MaxY are the active bounds of the tablet in raw
+	// coordinates. MinX and MinY are the offsets of the active area.
+	MinX int `json:"minX"`
+	MinY int `json:"minY"`
+	MaxX int `json:"maxX"`
+	MaxY int `json:"maxY"`
+	// Correction is the affine transform [a, b, c, d, e, f] that is applied
+	// to a position after it is made relative to the active bounds and
+	// rotated to match the orientation. A position (u, v), where both values
+	// are fractions of the active area, becomes (a*u + b*v + c, d*u + e*v + f).
+	// It is close to the identity [1, 0, 0, 0, 1, 0] unless the corners were
+	// measured at an angle.
+	Correction [6]float64 `json:"correction"`
+}
+
+// Calibrate computes a calibration from raw positions that were measured at
+// each of the CalibrationCorners, in order.
+func Calibrate(orientation string, corners [4][2]int) (Calibration, error) {
+	c := Calibration{
+		Orientation: orientation,
+		MinX:        math.MaxInt,
+		MinY:        math.MaxInt,
+		MaxX:        math.MinInt,
+		MaxY:        math.MinInt,
+	}
+	if _, _, err := orient(orientation, 0, 0); err != nil {
+		return c, err
+	}
+	for _, p := range corners {
+		c.MinX, c.MaxX = min(c.MinX, p[0]), max(c.MaxX, p[0])
+		c.MinY, c.MaxY = min(c.MinY, p[1]), max(c.MaxY, p[1])
+	}
+	if c.MaxX == c.MinX || c.MaxY == c.MinY {
+		return c, fmt.Errorf("the measured corners do not cover an area of the tablet")
+	}
+
+	// Fit the correction with least squares. Each output is an independent
+	// linear combination of (u, v, 1) so the normal equations are the same
+	// 3x3 system with a different right hand side.
+	var ata [3][3]float64
+	var atbU, atbV [3]float64
+	for i, p := range corners {
+		u, v := c.normalize(float64(p[0]), float64(p[1]))
+		row := [3]float64{u, v, 1}
+		for r := 0; r < 3; r++ {
+			for k := 0; k < 3; k++ {
+				ata[r][k] += row[r] * row[k]
+			}
+			atbU[r] += row[r] * calibrationTargets[i][0]
+			atbV[r] += row[r] * calibrationTargets[i][1]
+		}
+	}
+	abc, err := solve3(ata, atbU)
+	if err != nil {
+		return c, err
+	}
+	def, err := solve3(ata, atbV)
+	if err != nil {
+		return c, err
+	}
+	c.Correction = [6]float64{abc[0], abc[1], abc[2], def[0], def[1], def[2]}
+	return c, nil
+}
+
+// normalize converts a raw position into fractions of the active area that
+// are rotated to match the orientation.
+func (c Calibration) normalize(x float64, y float64) (float64, float64) {
+	px := (x - float64(c.MinX)) / float64(c.MaxX-c.MinX)
+	py := (y - float64(c.MinY)) / float64(c.MaxY-c.MinY)
+	u, v, _ := orient(c.Orientation, px, py)
+	return u, v
+}
+
+// orient rotates a position, given as fractions of the tablet size, into the
+// orientation. It matches the rules of the orientation scalers.
+func orient(orientation string, px float64, py float64) (float64, float64, error) {
+	switch orientation {
+	case OrientationRight:
+		return px, py, nil
+	case OrientationLeft:
+		return 1 - px, 1 - py, nil
+	case OrientationVertical:
+		return py, 1 - px, nil
+	default:
+		return 0, 0, fmt.Errorf("unknown orienation selection %s", orientation)
+	}
+}
+
+// solve3 solves a 3x3 linear system using Cramer's rule.
+func solve3(a [3][3]float64, b [3]float64) ([3]float64, error) {
+	det := det3(a)
+	if math.Abs(det) < 1e-12 {
+		return [3]float64{}, fmt.Errorf("the measured corners are too close together")
+	}
+	var x [3]float64
+	for col := 0; col < 3; col++ {
+		m := a
+		for row := 0; row < 3; row++ {
+			m[row][col] = b[row]
+		}
+		x[col] = det3(m) / det
+	}
+	return x, nil
+}
+
+func det3(m [3][3]float64) float64 {
+	return m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+}
+
+// LoadCalibration reads a calibration that was written by SaveCalibration. It
+// returns nil if the file does not exist.
+func LoadCalibration(file string) (*Calibration, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	c := &Calibration{}
+	if err = json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if _, _, err = orient(c.Orientation, 0, 0); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if c.MaxX <= c.MinX || c.MaxY <= c.MinY {
+		return nil, fmt.Errorf("%s: the active bounds are empty", file)
+	}
+	return c, nil
+}
+
+// SaveCalibration writes a calibration to a file, creating the directory if
+// needed.
+func SaveCalibration(file string, c Calibration) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(b, '\n'), 0o600)
+}
+
+// CalibratedPositionScaler converts points using a measured calibration
+// rather than the fixed tablet dimensions.
+type CalibratedPositionScaler struct {
+	Calibration  Calibration
+	ScreenWidth  int
+	ScreenHeight int
+}
+
+// ScalePosition resolves based on the calibrated active area and orientation.
+func (s *CalibratedPositionScaler) ScalePosition(x float64, y float64) (float64, float64) {
+	u, v := s.Calibration.normalize(x, y)
+	m := s.Calibration.Correction
+	u, v = m[0]*u+m[1]*v+m[2], m[3]*u+m[4]*v+m[5]
+	return u * float64(s.ScreenWidth), v * float64(s.ScreenHeight)
+}
@@ -0,0 +1,101 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalibrate(t *testing.T) {
+	const w, h = DefaultTabletWidth, DefaultTabletHeight
+	tests := []struct {
+		name        string
+		orientation string
+		corners     [4][2]int
+	}{
+		{
+			name:        "right",
+			orientation: OrientationRight,
+			corners:     [4][2]int{{100, 200}, {w - 50, 200}, {w - 50, h - 100}, {100, h - 100}},
+		},
+		{
+			name:        "left",
+			orientation: OrientationLeft,
+			corners:     [4][2]int{{w - 50, h - 100}, {100, h - 100}, {100, 200}, {w - 50, 200}},
+		},
+		{
+			name:        "vertical",
+			orientation: OrientationVertical,
+			corners:     [4][2]int{{w - 50, 200}, {w - 50, h - 100}, {100, h - 100}, {100, 200}},
+		},
+		{
+			name:        "skewed",
+			orientation: OrientationRight,
+			corners:     [4][2]int{{300, 200}, {w, 0}, {w - 300, h}, {0, h - 200}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Calibrate(tt.orientation, tt.corners)
+			require.Nil(t, err)
+			s := &CalibratedPositionScaler{Calibration: c, ScreenWidth: 1920, ScreenHeight: 1080}
+			want := [4][2]int{{0, 0}, {1920, 0}, {1920, 1080}, {0, 1080}}
+			for i, p := range tt.corners {
+				x, y := s.ScalePosition(float64(p[0]), float64(p[1]))
+				// Least squares may not hit every corner exactly when they
+				// are skewed so allow a small error.
+				require.InDelta(t, want[i][0], x, 100, "corner %d x", i)
+				require.InDelta(t, want[i][1], y, 100, "corner %d y", i)
+			}
+		})
+	}
+}
+
+func TestCalibrateIdentity(t *testing.T) {
+	c, err := Calibrate(OrientationRight, [4][2]int{{0, 0}, {1000, 0}, {1000, 500}, {0, 500}})
+	require.Nil(t, err)
+	want := [6]float64{1, 0, 0, 0, 1, 0}
+	for i := range want {
+		require.True(t, math.Abs(want[i]-c.Correction[i]) < 1e-9, "correction %d is %f", i, c.Correction[i])
+	}
+	require.Equal(t, 0, c.MinX)
+	require.Equal(t, 1000, c.MaxX)
+	require.Equal(t, 500, c.MaxY)
+}
+
+func TestCalibrateErrors(t *testing.T) {
+	_, err := Calibrate(OrientationRight, [4][2]int{{5, 5}, {5, 5}, {5, 5}, {5, 5}})
+	require.NotNil(t, err)
+	_, err = Calibrate("upside-down", [4][2]int{{0, 0}, {1, 0}, {1, 1}, {0, 1}})
+	require.NotNil(t, err)
+}
+
+func TestSaveLoadCalibration(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "nested", "calibration.json")
+	c, err := Calibrate(OrientationVertical, [4][2]int{{1000, 0}, {1000, 500}, {0, 500}, {0, 0}})
+	require.Nil(t, err)
+	require.Nil(t, SaveCalibration(file, c))
+	loaded, err := LoadCalibration(file)
+	require.Nil(t, err)
+	require.Equal(t, c, *loaded)
+
+	missing, err := LoadCalibration(filepath.Join(t.TempDir(), "missing.json"))
+	require.Nil(t, err)
+	require.Nil(t, missing)
+}
@@ -0,0 +1,303 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CaptureFormatEvemu is the event file written by evemu-record.
+const CaptureFormatEvemu = "evemu"
+
+// ExportFormats are the names of the formats that WriteCapture writes.
+var ExportFormats = []string{CaptureFormatLibinput, CaptureFormatEvemu}
+
+// CaptureDevice describes the device that a capture came from so that tools
+// such as libinput replay and evemu-device can create a copy of it.
+type CaptureDevice struct {
+	// Name is the input device name.
+	Name string
+	// Node is the event file that the events were read from.
+	Node string
+	// Bus, Vendor, Product, and Version are the input device ID.
+	Bus     uint16
+	Vendor  uint16
+	Product uint16
+	Version uint16
+	// Codes are the event codes that the device supports, sorted by type and
+	// then code.
+	Codes []EventCode
+	// Axes are the ranges of the absolute axes keyed by ABS_* code.
+	Axes map[uint16]AxisInfo
+}
+
+// NewCaptureDevice describes the digitizer of a tablet profile. The codes are
+// those found in the events along with the axes listed in the profile. Axes
+// that the profile does not list are given the range of the values found in
+// the events, widened to at least one unit.
+func NewCaptureDevice(profile TabletProfile, events []EvdevEvent) CaptureDevice {
+	dev := CaptureDevice{
+		Name: profile.Name,
+		Node: profile.EventFile,
+		Axes: map[uint16]AxisInfo{
+			ABS_X: {Max: int32(profile.TabletWidth)},
+			ABS_Y: {Max: int32(profile.TabletHeight)},
+		},
+	}
+	if len(profile.Digitizers) > 0 {
+		dev.Name = profile.Digitizers[0]
+	}
+	for name, axis := range profile.Axes {
+		// The names are checked when the profiles are parsed.
+		code, _ := ParseCode("EV_ABS", name)
+		dev.Axes[code.Code] = axis
+	}
+	codes := map[EventCode]bool{{Type: EV_SYN, Code: SYN_REPORT}: true}
+	for code := range dev.Axes {
+		codes[EventCode{Type: EV_ABS, Code: code}] = true
+	}
+	seen := make(map[uint16]AxisInfo)
+	for _, evt := range events {
+		codes[evt.EventCode()] = true
+		if evt.Type != EV_ABS {
+			continue
+		}
+		axis, ok := seen[evt.Code]
+		if !ok || evt.Value < axis.Min {
+			axis.Min = evt.Value
+		}
+		if !ok || evt.Value > axis.Max {
+			axis.Max = evt.Value
+		}
+		seen[evt.Code] = axis
+	}
+	for code, axis := range seen {
+		if _, ok := dev.Axes[code]; !ok {
+			dev.Axes[code] = axis
+		}
+	}
+	for code, axis := range dev.Axes {
+		// libinput ignores an axis with an empty range, which is what an axis
+		// that only ever had one value in the events gets.
+		if axis.Max <= axis.Min {
+			axis.Max = axis.Min + 1
+			dev.Axes[code] = axis
+		}
+	}
+	for code := range codes {
+		dev.Codes = append(dev.Codes, code)
+	}
+	sort.Slice(dev.Codes, func(i, j int) bool {
+		if dev.Codes[i].Type != dev.Codes[j].Type {
+			return dev.Codes[i].Type < dev.Codes[j].Type
+		}
+		return dev.Codes[i].Code < dev.Codes[j].Code
+	})
+	return dev
+}
+
+// types returns the event types of the device in order with their codes.
+func (d CaptureDevice) types() ([]EventType, map[EventType][]uint16) {
+	var types []EventType
+	codes := make(map[EventType][]uint16)
+	for _, c := range d.Codes {
+		if _, ok := codes[c.Type]; !ok {
+			types = append(types, c.Type)
+		}
+		codes[c.Type] = append(codes[c.Type], c.Code)
+	}
+	return types, codes
+}
+
+// ReadCapture reads all of the events of an iterator and closes it. The end
+// of the events is not an error.
+func ReadCapture(it EvdevIterator) ([]EvdevEvent, error) {
+	var events []EvdevEvent
+	for it.Next() {
+		events = append(events, it.Current())
+	}
+	if err := it.Close(); err != nil && !errors.Is(err, io.EOF) {
+		return events, err
+	}
+	return events, nil
+}
+
+// WriteCapture writes the events in one of the ExportFormats.
+func WriteCapture(format string, w io.Writer, dev CaptureDevice, events []EvdevEvent) error {
+	switch format {
+	case CaptureFormatLibinput:
+		return WriteLibinputRecord(w, dev, events)
+	case CaptureFormatEvemu:
+		return WriteEvemu(w, dev, events)
+	default:
+		return fmt.Errorf("unknown export format %s. Available formats are: %s", format, strings.Join(ExportFormats, ", "))
+	}
+}
+
+// captureClock gives the times of events relative to the first one, which is
+// how both libinput record and evemu write them.
+type captureClock struct {
+	start     time.Time
+	lastFrame time.Time
+}
+
+func (c *captureClock) since(t time.Time) (sec int64, usec int64) {
+	if c.start.IsZero() {
+		c.start = t
+		c.lastFrame = t
+	}
+	d := t.Sub(c.start)
+	return int64(d / time.Second), int64(d%time.Second) / int64(time.Microsecond)
+}
+
+// describe returns the comment written after an event. The time since the
+// previous frame is given for each SYN_REPORT.
+func (c *captureClock) describe(evt EvdevEvent) string {
+	if evt.Type != EV_SYN {
+		return fmt.Sprintf("%s / %-20s %6d", evt.Type, evtestCodeName(evt), evt.Value)
+	}
+	if evt.Code != SYN_REPORT {
+		return fmt.Sprintf("++++++++++++ %s (%d) ++++++++++", evtestCodeName(evt), evt.Value)
+	}
+	ms := evt.Time.Sub(c.lastFrame).Milliseconds()
+	c.lastFrame = evt.Time
+	return fmt.Sprintf("------------ %s (%d) ---------- %+dms", evtestCodeName(evt), evt.Value, ms)
+}
+
+// WriteLibinputRecord writes the events as the YAML of libinput record so
+// that they can be played into a virtual device with libinput replay. Each
+// SYN_REPORT frame is written as its own entry of the events list.
+func WriteLibinputRecord(w io.Writer, dev CaptureDevice, events []EvdevEvent) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# libinput record\n")
+	fmt.Fprintf(bw, "version: 1\n")
+	fmt.Fprintf(bw, "ndevices: 1\n")
+	fmt.Fprintf(bw, "devices:\n")
+	fmt.Fprintf(bw, "- node: %s\n", dev.Node)
+	fmt.Fprintf(bw, "  evdev:\n")
+	fmt.Fprintf(bw, "    # Name: %s\n", dev.Name)
+	fmt.Fprintf(bw, "    # ID: bus 0x%x vendor 0x%x product 0x%x version 0x%x\n", dev.Bus, dev.Vendor, dev.Product, dev.Version)
+	fmt.Fprintf(bw, "    # Supported Events:\n")
+	types, codes := dev.types()
+	for _, etype := range types {
+		fmt.Fprintf(bw, "    # Event type %d (%s)\n", etype, etype)
+		for _, code := range codes[etype] {
+			evt := EvdevEvent{Type: etype, Code: code}
+			if axis, ok := dev.Axes[code]; ok && etype == EV_ABS {
+				fmt.Fprintf(bw, "    #   Event code %d (%s)\n", code, evtestCodeName(evt))
+				fmt.Fprintf(bw, "    #       Min        %6d\n", axis.Min)
+				fmt.Fprintf(bw, "    #       Max        %6d\n", axis.Max)
+				fmt.Fprintf(bw, "    #       Fuzz       %6d\n", axis.Fuzz)
+				fmt.Fprintf(bw, "    #       Flat       %6d\n", axis.Flat)
+				fmt.Fprintf(bw, "    #       Resolution %6d\n", axis.Resolution)
+				continue
+			}
+			fmt.Fprintf(bw, "    #   Event code %d (%s)\n", code, evtestCodeName(evt))
+		}
+	}
+	fmt.Fprintf(bw, "    # Properties:\n")
+	fmt.Fprintf(bw, "    name: %q\n", dev.Name)
+	fmt.Fprintf(bw, "    id: [%d, %d, %d, %d]\n", dev.Bus, dev.Vendor, dev.Product, dev.Version)
+	fmt.Fprintf(bw, "    codes:\n")
+	for _, etype := range types {
+		list := make([]string, 0, len(codes[etype]))
+		for _, code := range codes[etype] {
+			list = append(list, fmt.Sprintf("%d", code))
+		}
+		fmt.Fprintf(bw, "      %d: [%s] # %s\n", etype, strings.Join(list, ", "), etype)
+	}
+	fmt.Fprintf(bw, "    absinfo:\n")
+	for _, code := range codes[EV_ABS] {
+		axis := dev.Axes[code]
+		fmt.Fprintf(bw, "      %d: [%d, %d, %d, %d, %d]\n", code, axis.Min, axis.Max, axis.Fuzz, axis.Flat, axis.Resolution)
+	}
+	fmt.Fprintf(bw, "    properties: []\n")
+	fmt.Fprintf(bw, "  events:\n")
+	clock := &captureClock{}
+	frameStart := true
+	for _, evt := range events {
+		if frameStart {
+			fmt.Fprintf(bw, "  - evdev:\n")
+			frameStart = false
+		}
+		sec, usec := clock.since(evt.Time)
+		fmt.Fprintf(bw, "    - [%3d, %6d, %3d, %3d, %7d] # %s\n", sec, usec, evt.Type, evt.Code, evt.Value, clock.describe(evt))
+		frameStart = evt.Type == EV_SYN && evt.Code == SYN_REPORT
+	}
+	return bw.Flush()
+}
+
+// WriteEvemu writes the device description and events in the format of
+// evemu-record so that they can be played into a virtual device with
+// evemu-device and evemu-play.
+func WriteEvemu(w io.Writer, dev CaptureDevice, events []EvdevEvent) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# EVEMU 1.3\n")
+	fmt.Fprintf(bw, "# Input device name: %q\n", dev.Name)
+	fmt.Fprintf(bw, "# Input device ID: bus 0x%x vendor 0x%x product 0x%x version 0x%x\n", dev.Bus, dev.Vendor, dev.Product, dev.Version)
+	fmt.Fprintf(bw, "N: %s\n", dev.Name)
+	fmt.Fprintf(bw, "I: %04x %04x %04x %04x\n", dev.Bus, dev.Vendor, dev.Product, dev.Version)
+	writeEvemuMask(bw, "P:", nil)
+	types, codes := dev.types()
+	typeBits := make([]uint16, 0, len(types))
+	for _, etype := range types {
+		typeBits = append(typeBits, uint16(etype))
+	}
+	writeEvemuMask(bw, "B: 00", typeBits)
+	for _, etype := range types {
+		if etype == EV_SYN {
+			continue
+		}
+		writeEvemuMask(bw, fmt.Sprintf("B: %02x", uint16(etype)), codes[etype])
+	}
+	for _, code := range codes[EV_ABS] {
+		axis := dev.Axes[code]
+		fmt.Fprintf(bw, "A: %02x %d %d %d %d %d\n", code, axis.Min, axis.Max, axis.Fuzz, axis.Flat, axis.Resolution)
+	}
+	clock := &captureClock{}
+	for _, evt := range events {
+		sec, usec := clock.since(evt.Time)
+		fmt.Fprintf(bw, "E: %d.%06d %04x %04x %04d\t# %s\n", sec, usec, uint16(evt.Type), evt.Code, evt.Value, clock.describe(evt))
+	}
+	return bw.Flush()
+}
+
+// writeEvemuMask writes a bit mask of the given bits eight bytes per line,
+// each line starting with the prefix. At least one line is written.
+func writeEvemuMask(w io.Writer, prefix string, bits []uint16) {
+	size := 1
+	for _, b := range bits {
+		if int(b)/8+1 > size {
+			size = int(b)/8 + 1
+		}
+	}
+	mask := make([]byte, (size+7)/8*8)
+	for _, b := range bits {
+		mask[b/8] |= 1 << (b % 8)
+	}
+	for i := 0; i < len(mask); i += 8 {
+		fmt.Fprint(w, prefix)
+		for _, b := range mask[i : i+8] {
+			fmt.Fprintf(w, " %02x", b)
+		}
+		fmt.Fprintln(w)
+	}
+}
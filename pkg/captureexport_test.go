@@ -0,0 +1,152 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func exportEvents() []EvdevEvent {
+	start := time.Unix(1700000000, 500000000)
+	return []EvdevEvent{
+		{Time: start, Type: EV_KEY, Code: BTN_TOOL_PEN, Value: 1},
+		{Time: start, Type: EV_ABS, Code: ABS_X, Value: 2000},
+		{Time: start, Type: EV_ABS, Code: ABS_MT_POSITION_X, Value: -4},
+		{Time: start, Type: EV_SYN, Code: SYN_REPORT},
+		{Time: start.Add(1500 * time.Millisecond), Type: EV_ABS, Code: ABS_MT_POSITION_X, Value: 9},
+		{Time: start.Add(1500 * time.Millisecond), Type: EV_SYN, Code: SYN_REPORT},
+	}
+}
+
+func exportProfile() TabletProfile {
+	return TabletProfile{
+		Name:         "Test",
+		Digitizers:   []string{"Test Digitizer"},
+		EventFile:    "/dev/input/event1",
+		TabletWidth:  100,
+		TabletHeight: 50,
+		Axes:         map[string]AxisInfo{"ABS_PRESSURE": {Max: 4095}},
+	}
+}
+
+func TestNewCaptureDevice(t *testing.T) {
+	dev := NewCaptureDevice(exportProfile(), exportEvents())
+	require.Equal(t, "Test Digitizer", dev.Name)
+	require.Equal(t, "/dev/input/event1", dev.Node)
+	require.Equal(t, []EventCode{
+		{Type: EV_SYN, Code: SYN_REPORT},
+		{Type: EV_KEY, Code: BTN_TOOL_PEN},
+		{Type: EV_ABS, Code: ABS_X},
+		{Type: EV_ABS, Code: ABS_Y},
+		{Type: EV_ABS, Code: ABS_PRESSURE},
+		{Type: EV_ABS, Code: ABS_MT_POSITION_X},
+	}, dev.Codes)
+	require.Equal(t, map[uint16]AxisInfo{
+		ABS_X:             {Max: 100},
+		ABS_Y:             {Max: 50},
+		ABS_PRESSURE:      {Max: 4095},
+		ABS_MT_POSITION_X: {Min: -4, Max: 9},
+	}, dev.Axes)
+
+	// The ranges of the profile are kept even if the events do not reach
+	// them, and an axis that only had one value is given a range that
+	// libinput accepts.
+	dev = NewCaptureDevice(exportProfile(), []EvdevEvent{
+		{Type: EV_ABS, Code: ABS_PRESSURE, Value: 2000},
+		{Type: EV_ABS, Code: ABS_DISTANCE, Value: 30},
+		{Type: EV_ABS, Code: ABS_DISTANCE, Value: 30},
+	})
+	require.Equal(t, AxisInfo{Max: 4095}, dev.Axes[ABS_PRESSURE])
+	require.Equal(t, AxisInfo{Min: 30, Max: 31}, dev.Axes[ABS_DISTANCE])
+}
+
+func TestWriteLibinputRecord(t *testing.T) {
+	var out bytes.Buffer
+	events := exportEvents()
+	require.Nil(t, WriteCapture(CaptureFormatLibinput, &out, NewCaptureDevice(exportProfile(), events), events))
+	text := out.String()
+	require.Contains(t, text, `    name: "Test Digitizer"
+    id: [0, 0, 0, 0]
+    codes:
+      0: [0] # EV_SYN
+      1: [320] # EV_KEY
+      3: [0, 1, 24, 53] # EV_ABS
+    absinfo:
+      0: [0, 100, 0, 0, 0]
+      1: [0, 50, 0, 0, 0]
+      24: [0, 4095, 0, 0, 0]
+      53: [-4, 9, 0, 0, 0]
+    properties: []
+  events:
+  - evdev:
+    - [  0,      0,   1, 320,       1] # EV_KEY / BTN_TOOL_PEN              1
+`)
+	require.True(t, strings.HasSuffix(text, `  - evdev:
+    - [  1, 500000,   3,  53,       9] # EV_ABS / ABS_MT_POSITION_X         9
+    - [  1, 500000,   0,   0,       0] # ------------ SYN_REPORT (0) ---------- +1500ms
+`))
+
+	// The events can be read back with the libinput importer.
+	it := &LibinputEvdevIterator{Source: io.NopCloser(&out)}
+	read, err := ReadCapture(it)
+	require.Nil(t, err)
+	require.Len(t, read, len(events))
+	for i, evt := range events {
+		require.Equal(t, evt.EventCode(), read[i].EventCode())
+		require.Equal(t, evt.Value, read[i].Value)
+		require.Equal(t, evt.Time.Sub(events[0].Time), read[i].Time.Sub(read[0].Time))
+	}
+}
+
+func TestWriteEvemu(t *testing.T) {
+	var out bytes.Buffer
+	events := exportEvents()
+	require.Nil(t, WriteCapture(CaptureFormatEvemu, &out, NewCaptureDevice(exportProfile(), events), events))
+	require.Equal(t, `# EVEMU 1.3
+# Input device name: "Test Digitizer"
+# Input device ID: bus 0x0 vendor 0x0 product 0x0 version 0x0
+N: Test Digitizer
+I: 0000 0000 0000 0000
+P: 00 00 00 00 00 00 00 00
+B: 00 0b 00 00 00 00 00 00 00
+B: 01 00 00 00 00 00 00 00 00
+B: 01 00 00 00 00 00 00 00 00
+B: 01 00 00 00 00 00 00 00 00
+B: 01 00 00 00 00 00 00 00 00
+B: 01 00 00 00 00 00 00 00 00
+B: 01 01 00 00 00 00 00 00 00
+B: 03 03 00 00 01 00 00 20 00
+A: 00 0 100 0 0 0
+A: 01 0 50 0 0 0
+A: 18 0 4095 0 0 0
+A: 35 -4 9 0 0 0
+E: 0.000000 0001 0140 0001	# EV_KEY / BTN_TOOL_PEN              1
+E: 0.000000 0003 0000 2000	# EV_ABS / ABS_X                  2000
+E: 0.000000 0003 0035 -004	# EV_ABS / ABS_MT_POSITION_X        -4
+E: 0.000000 0000 0000 0000	# ------------ SYN_REPORT (0) ---------- +0ms
+E: 1.500000 0003 0035 0009	# EV_ABS / ABS_MT_POSITION_X         9
+E: 1.500000 0000 0000 0000	# ------------ SYN_REPORT (0) ---------- +1500ms
+`, out.String())
+}
+
+func TestWriteCaptureUnknown(t *testing.T) {
+	require.NotNil(t, WriteCapture("pcap", &bytes.Buffer{}, CaptureDevice{}, nil))
+}
@@ -0,0 +1,302 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidCapture is returned when a text capture has a line that looks like
+// an event but can not be read as one.
+var ErrInvalidCapture = errors.New("invalid capture")
+
+const (
+	// CaptureFormatRaw is the binary stream written by the record command.
+	CaptureFormatRaw = "raw"
+	// CaptureFormatEvtest is the text printed by the evtest tool.
+	CaptureFormatEvtest = "evtest"
+	// CaptureFormatLibinput is the YAML written by libinput record.
+	CaptureFormatLibinput = "libinput"
+)
+
+// CaptureFormats are the names of the formats that NewCaptureEvdevIterator
+// reads.
+var CaptureFormats = []string{CaptureFormatRaw, CaptureFormatEvtest, CaptureFormatLibinput}
+
+// NewCaptureEvdevIterator returns an iterator over a capture in one of the
+// CaptureFormat* formats. The layout is only used for raw captures.
+func NewCaptureEvdevIterator(format string, source io.ReadCloser, layout string) (EvdevIterator, error) {
+	switch format {
+	case CaptureFormatRaw:
+		return &FileEvdevIterator{Source: source, Layout: layout}, nil
+	case CaptureFormatEvtest:
+		return &EvtestEvdevIterator{Source: source}, nil
+	case CaptureFormatLibinput:
+		return &LibinputEvdevIterator{Source: source}, nil
+	default:
+		return nil, fmt.Errorf("unknown capture format %s. Available formats are: %s", format, strings.Join(CaptureFormats, ", "))
+	}
+}
+
+// lineScanner holds the state shared by the iterators that read text
+// captures one line at a time.
+type lineScanner struct {
+	scanner *bufio.Scanner
+	line    int
+	// mu guards err so that Close may be called while Next is blocked in a
+	// read.
+	mu      sync.Mutex
+	err     error
+	current EvdevEvent
+}
+
+// scan reads lines until parse finds an event in one of them. parse returns
+// false for lines that are not events.
+func (s *lineScanner) scan(source io.Reader, parse func(line string) (EvdevEvent, bool, error)) bool {
+	if s.err != nil {
+		// Prevent re-entry after an error.
+		return false
+	}
+	if s.scanner == nil {
+		s.scanner = bufio.NewScanner(source)
+	}
+	for s.scanner.Scan() {
+		s.line++
+		evt, ok, err := parse(strings.TrimSpace(s.scanner.Text()))
+		if err != nil {
+			s.setErr(fmt.Errorf("%w line %d: %v", ErrInvalidCapture, s.line, err))
+			return false
+		}
+		if ok {
+			s.current = evt
+			return true
+		}
+	}
+	err := s.scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	s.setErr(err)
+	return false
+}
+
+func (s *lineScanner) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// close closes the source and returns the error that ended the scan, if any.
+// Reaching the end of the text is how a capture ends so it is not an error.
+func (s *lineScanner) close(source io.Closer) error {
+	err := source.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil || s.err == io.EOF {
+		return err
+	}
+	return s.err
+}
+
+// EvtestEvdevIterator reads the events printed by evtest, such as:
+//
+//	Event: time 1700000000.123456, type 3 (EV_ABS), code 24 (ABS_PRESSURE), value 1000
+//	Event: time 1700000000.123456, -------------- SYN_REPORT ------------
+//
+// The device description that evtest prints first, and any other lines that
+// do not start with "Event:", are skipped. The numbers are used rather than
+// the names in parentheses.
+type EvtestEvdevIterator struct {
+	Source io.ReadCloser
+	lineScanner
+}
+
+// Next reads until the next event line.
+func (it *EvtestEvdevIterator) Next() bool {
+	return it.scan(it.Source, parseEvtestLine)
+}
+
+// Current returns the active element.
+func (it *EvtestEvdevIterator) Current() EvdevEvent {
+	return it.current
+}
+
+// Close the underlying source and return any errors.
+func (it *EvtestEvdevIterator) Close() error {
+	return it.close(it.Source)
+}
+
+func parseEvtestLine(line string) (EvdevEvent, bool, error) {
+	rest, ok := strings.CutPrefix(line, "Event: time ")
+	if !ok {
+		return EvdevEvent{}, false, nil
+	}
+	stamp, rest, ok := strings.Cut(rest, ", ")
+	if !ok {
+		return EvdevEvent{}, false, fmt.Errorf("missing event after the time")
+	}
+	t, err := parseEvtestTime(stamp)
+	if err != nil {
+		return EvdevEvent{}, false, err
+	}
+	if strings.HasPrefix(rest, "---") || strings.HasPrefix(rest, "+++") {
+		// SYN events are printed as a marker with only the code name.
+		code, err := ParseCode("EV_SYN", strings.Trim(rest, "-+ "))
+		if err != nil {
+			return EvdevEvent{}, false, err
+		}
+		return EvdevEvent{Time: t, Type: EV_SYN, Code: code.Code}, true, nil
+	}
+	fields := strings.Split(rest, ", ")
+	if len(fields) != 3 {
+		return EvdevEvent{}, false, fmt.Errorf("expected type, code, and value in %q", rest)
+	}
+	etype, err := parseEvtestField(fields[0], "type", 16)
+	if err != nil {
+		return EvdevEvent{}, false, err
+	}
+	code, err := parseEvtestField(fields[1], "code", 16)
+	if err != nil {
+		return EvdevEvent{}, false, err
+	}
+	value, ok := strings.CutPrefix(fields[2], "value ")
+	if !ok {
+		return EvdevEvent{}, false, fmt.Errorf("expected value in %q", fields[2])
+	}
+	evt := EvdevEvent{Time: t, Type: EventType(etype), Code: uint16(code)}
+	base := 10
+	if evt.Type == EV_MSC && (evt.Code == MSC_RAW || evt.Code == MSC_SCAN) {
+		// evtest prints these as hex.
+		base = 16
+	}
+	v, err := strconv.ParseInt(value, base, 32)
+	if err != nil {
+		return EvdevEvent{}, false, err
+	}
+	evt.Value = int32(v)
+	return evt, true, nil
+}
+
+// parseEvtestField reads the number from a field such as "type 3 (EV_ABS)".
+func parseEvtestField(field string, name string, bits int) (uint64, error) {
+	rest, ok := strings.CutPrefix(field, name+" ")
+	if !ok {
+		return 0, fmt.Errorf("expected %s in %q", name, field)
+	}
+	n, _, _ := strings.Cut(rest, " ")
+	return strconv.ParseUint(n, 10, bits)
+}
+
+// parseEvtestTime reads a time written as seconds and microseconds.
+func parseEvtestTime(stamp string) (time.Time, error) {
+	secs, usecs, ok := strings.Cut(stamp, ".")
+	if !ok {
+		return time.Time{}, fmt.Errorf("expected seconds.microseconds in %q", stamp)
+	}
+	sec, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	usec, err := strconv.ParseInt(usecs, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(sec, usec*int64(time.Microsecond)), nil
+}
+
+// LibinputEvdevIterator reads the evdev events from the YAML written by
+// libinput record. Each event is a list of the seconds and microseconds since
+// the recording started, the type, the code, and the value:
+//
+//	events:
+//	- evdev:
+//	  - [  0,      0,   3,  24,    1000] # EV_ABS / ABS_PRESSURE  1000
+//	  - [  0,      0,   0,   0,       0] # ------------ SYN_REPORT (0) ---------- +0ms
+//
+// Only the lines that are needed to find the events are read, so the rest of
+// the file does not need to be valid YAML.
+type LibinputEvdevIterator struct {
+	Source io.ReadCloser
+	// Device is the position of the device to read in the devices list of a
+	// recording that has more than one. The default is the first.
+	Device int
+	lineScanner
+	device   int
+	inEvents bool
+}
+
+// Next reads until the next evdev event of the selected device.
+func (it *LibinputEvdevIterator) Next() bool {
+	if it.scanner == nil {
+		it.device = -1
+	}
+	return it.scan(it.Source, it.parseLine)
+}
+
+// Current returns the active element.
+func (it *LibinputEvdevIterator) Current() EvdevEvent {
+	return it.current
+}
+
+// Close the underlying source and return any errors.
+func (it *LibinputEvdevIterator) Close() error {
+	return it.close(it.Source)
+}
+
+func (it *LibinputEvdevIterator) parseLine(line string) (EvdevEvent, bool, error) {
+	switch {
+	case strings.HasPrefix(line, "- node:"):
+		it.device++
+		it.inEvents = false
+		return EvdevEvent{}, false, nil
+	case line == "events:":
+		it.inEvents = it.device == it.Device
+		return EvdevEvent{}, false, nil
+	case !it.inEvents || !strings.HasPrefix(line, "- ["):
+		return EvdevEvent{}, false, nil
+	}
+	list, _, ok := strings.Cut(strings.TrimPrefix(line, "- ["), "]")
+	if !ok {
+		return EvdevEvent{}, false, fmt.Errorf("missing ] in %q", line)
+	}
+	fields := strings.Split(list, ",")
+	if len(fields) != 5 {
+		return EvdevEvent{}, false, fmt.Errorf("expected 5 numbers in %q", line)
+	}
+	var n [5]int64
+	for i, f := range fields {
+		v, err := strconv.ParseInt(strings.TrimSpace(f), 10, 64)
+		if err != nil {
+			return EvdevEvent{}, false, err
+		}
+		n[i] = v
+	}
+	if n[2] < 0 || n[2] > 0xffff || n[3] < 0 || n[3] > 0xffff {
+		return EvdevEvent{}, false, fmt.Errorf("type or code out of range in %q", line)
+	}
+	return EvdevEvent{
+		Time:  time.Unix(n[0], n[1]*int64(time.Microsecond)),
+		Type:  EventType(n[2]),
+		Code:  uint16(n[3]),
+		Value: int32(n[4]),
+	}, true, nil
+}
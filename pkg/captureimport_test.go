@@ -0,0 +1,157 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readCapture(t *testing.T, it EvdevIterator) []EvdevEvent {
+	var events []EvdevEvent
+	for it.Next() {
+		events = append(events, it.Current())
+	}
+	// The end of the text is not an error.
+	require.Nil(t, it.Close())
+	return events
+}
+
+func TestEvtestEvdevIterator(t *testing.T) {
+	capture := `Input driver version is 1.0.1
+Input device ID: bus 0x18 vendor 0x56a product 0x0 version 0x36
+Input device name: "Wacom I2C Digitizer"
+Testing ... (interrupt to exit)
+Event: time 1700000000.000100, type 1 (EV_KEY), code 320 (BTN_TOOL_PEN), value 1
+Event: time 1700000000.000100, type 3 (EV_ABS), code 0 (ABS_X), value 2000
+Event: time 1700000000.000100, type 4 (EV_MSC), code 4 (MSC_SCAN), value d0042
+Event: time 1700000000.000100, -------------- SYN_REPORT ------------
+Event: time 1700000000.007600, type 3 (EV_ABS), code 24 (ABS_PRESSURE), value -3
+`
+	it := &EvtestEvdevIterator{Source: io.NopCloser(strings.NewReader(capture))}
+	start := time.Unix(1700000000, 100000)
+	require.Equal(t, []EvdevEvent{
+		{Time: start, Type: EV_KEY, Code: BTN_TOOL_PEN, Value: 1},
+		{Time: start, Type: EV_ABS, Code: ABS_X, Value: 2000},
+		{Time: start, Type: EV_MSC, Code: MSC_SCAN, Value: 0xd0042},
+		{Time: start, Type: EV_SYN, Code: SYN_REPORT},
+		{Time: start.Add(7500 * time.Microsecond), Type: EV_ABS, Code: ABS_PRESSURE, Value: -3},
+	}, readCapture(t, it))
+}
+
+func TestEvtestEvdevIteratorReadsSink(t *testing.T) {
+	var out bytes.Buffer
+	sink, err := NewEventSink(EventFormatEvtest, &out)
+	require.Nil(t, err)
+	for _, evt := range sinkEvents() {
+		require.Nil(t, sink.WriteEvent(evt))
+	}
+	it := &EvtestEvdevIterator{Source: io.NopCloser(&out)}
+	events := readCapture(t, it)
+	require.Len(t, events, len(sinkEvents()))
+	for i, evt := range sinkEvents() {
+		require.True(t, evt.Time.Equal(events[i].Time))
+		require.Equal(t, evt.EventCode(), events[i].EventCode())
+		require.Equal(t, evt.Value, events[i].Value)
+	}
+}
+
+func TestEvtestEvdevIteratorInvalid(t *testing.T) {
+	capture := `Event: time 1700000000.000100, type 3 (EV_ABS), code 0 (ABS_X), value 1
+Event: time 1700000000.000200, type 3 (EV_ABS), code X (ABS_X), value 1
+`
+	it := &EvtestEvdevIterator{Source: io.NopCloser(strings.NewReader(capture))}
+	require.True(t, it.Next())
+	require.False(t, it.Next())
+	err := it.Close()
+	require.True(t, errors.Is(err, ErrInvalidCapture))
+	require.Contains(t, err.Error(), "line 2")
+}
+
+func TestLibinputEvdevIterator(t *testing.T) {
+	capture := `# libinput record
+version: 1
+ndevices: 2
+libinput:
+  version: "1.25.0"
+devices:
+- node: /dev/input/event1
+  evdev:
+    name: "Wacom I2C Digitizer"
+    id: [24, 1386, 0, 54]
+    codes:
+      0: [0, 1, 2] # EV_SYN
+      3: [0, 1, 24] # EV_ABS
+    absinfo:
+      0: [0, 20967, 0, 0, 100]
+    properties: [1]
+  udev:
+    properties:
+    - ID_INPUT=1
+  events:
+  # Current time is 10:00:00
+  - evdev:
+    - [  0,      0,   3,   0,    2000] # EV_ABS / ABS_X                 2000
+    - [  0,      0,   0,   0,       0] # ------------ SYN_REPORT (0) ---------- +0ms
+  - evdev:
+    - [  1,   7500,   3,  24,     -3] # EV_ABS / ABS_PRESSURE          -3
+    - [  1,   7500,   0,   0,       0] # ------------ SYN_REPORT (0) ---------- +1007ms
+- node: /dev/input/event2
+  evdev:
+    name: "pt_mt"
+  events:
+  - evdev:
+    - [  0,      0,   3,  53,     10] # EV_ABS / ABS_MT_POSITION_X     10
+`
+	it := &LibinputEvdevIterator{Source: io.NopCloser(strings.NewReader(capture))}
+	require.Equal(t, []EvdevEvent{
+		{Time: time.Unix(0, 0), Type: EV_ABS, Code: ABS_X, Value: 2000},
+		{Time: time.Unix(0, 0), Type: EV_SYN, Code: SYN_REPORT},
+		{Time: time.Unix(1, 7500000), Type: EV_ABS, Code: ABS_PRESSURE, Value: -3},
+		{Time: time.Unix(1, 7500000), Type: EV_SYN, Code: SYN_REPORT},
+	}, readCapture(t, it))
+
+	it = &LibinputEvdevIterator{Source: io.NopCloser(strings.NewReader(capture)), Device: 1}
+	require.Equal(t, []EvdevEvent{
+		{Time: time.Unix(0, 0), Type: EV_ABS, Code: ABS_MT_POSITION_X, Value: 10},
+	}, readCapture(t, it))
+}
+
+func TestLibinputEvdevIteratorInvalid(t *testing.T) {
+	capture := `devices:
+- node: /dev/input/event1
+  events:
+  - evdev:
+    - [  0,      0,   3,   0] # EV_ABS / ABS_X
+`
+	it := &LibinputEvdevIterator{Source: io.NopCloser(strings.NewReader(capture))}
+	require.False(t, it.Next())
+	require.True(t, errors.Is(it.Close(), ErrInvalidCapture))
+}
+
+func TestNewCaptureEvdevIterator(t *testing.T) {
+	for _, format := range CaptureFormats {
+		_, err := NewCaptureEvdevIterator(format, io.NopCloser(&bytes.Buffer{}), EventLayoutTimeval32)
+		require.Nil(t, err)
+	}
+	_, err := NewCaptureEvdevIterator("pcap", io.NopCloser(&bytes.Buffer{}), EventLayoutTimeval32)
+	require.NotNil(t, err)
+}
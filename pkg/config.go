@@ -0,0 +1,176 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// ErrUnknownProfile is returned when a profile is selected that is not in the
+// configuration file.
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// PathFlagAnnotation marks flags that take file paths. ApplyFlagValues expands
+// a leading ~ in their values because no shell does so for values that come
+// from the configuration file or the environment.
+const PathFlagAnnotation = "remouseable_path"
+
+// MarkPathFlags annotates the named flags with PathFlagAnnotation. Names that
+// are not in the flag set are ignored so that one list can serve every
+// command.
+func MarkPathFlags(fs *flag.FlagSet, names ...string) {
+	for _, name := range names {
+		if fs.Lookup(name) != nil {
+			_ = fs.SetAnnotation(name, PathFlagAnnotation, []string{"true"})
+		}
+	}
+}
+
+// Config is the contents of the remouseable configuration file. It is JSON
+// formatted, for example:
+//
+//	{
+//	  "profile": "desk",
+//	  "profiles": {
+//	    "desk": {"orientation": "left", "screen-width": 2560},
+//	    "office": {"ssh-ip": "192.168.1.50:22", "ssh-key": ["~/.ssh/id_rm"]}
+//	  }
+//	}
+type Config struct {
+	// Profile is the name of the profile to use when none is selected.
+	Profile string `json:"profile"`
+	// Profiles are named sets of option values. Each key is the name of a
+	// command line option without the leading dashes.
+	Profiles map[string]ConfigProfile `json:"profiles"`
+}
+
+// ConfigProfile maps option names to values. Values may be strings, numbers,
+// booleans, or lists of those for options that may be given more than once.
+type ConfigProfile map[string]interface{}
+
+// LoadConfig reads the configuration file at the given path. A missing file
+// results in an empty configuration.
+func LoadConfig(file string) (*Config, error) {
+	c := &Config{}
+	if file == "" {
+		return c, nil
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return c, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return c, nil
+}
+
+// Values returns the option values of the named profile. If the name is empty
+// then the configured default profile is used, if any.
+func (c *Config) Values(profile string) (map[string][]string, error) {
+	if profile == "" {
+		profile = c.Profile
+	}
+	if profile == "" {
+		return map[string][]string{}, nil
+	}
+	p, ok := c.Profiles[profile]
+	if !ok {
+		names := make([]string, 0, len(c.Profiles))
+		for name := range c.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("%w %s. Available profiles are: %s", ErrUnknownProfile, profile, strings.Join(names, ", "))
+	}
+	values := make(map[string][]string, len(p))
+	for name, v := range p {
+		converted, err := configValueStrings(v)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s option %s: %w", profile, name, err)
+		}
+		values[name] = converted
+	}
+	return values, nil
+}
+
+func configValueStrings(v interface{}) ([]string, error) {
+	switch value := v.(type) {
+	case string:
+		return []string{value}, nil
+	case bool:
+		return []string{strconv.FormatBool(value)}, nil
+	case float64:
+		return []string{strconv.FormatFloat(value, 'f', -1, 64)}, nil
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			converted, err := configValueStrings(item)
+			if err != nil {
+				return nil, err
+			}
+			if len(converted) != 1 {
+				return nil, fmt.Errorf("lists may not be nested")
+			}
+			values = append(values, converted[0])
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("unsupported value %v", v)
+	}
+}
+
+// ApplyFlagValues sets flags that have not already been set. This is used to
+// layer values from lower precedence sources underneath the command line.
+// Flags that are set are marked as changed so each layer only fills in what
+// the layers above it did not. A leading ~ in the values of flags marked with
+// MarkPathFlags is replaced with the home directory.
+func ApplyFlagValues(fs *flag.FlagSet, values map[string][]string) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := fs.Lookup(name)
+		if f == nil {
+			return fmt.Errorf("unknown option %s", name)
+		}
+		if f.Changed {
+			continue
+		}
+		_, isPath := f.Annotations[PathFlagAnnotation]
+		for _, v := range values[name] {
+			if isPath {
+				v = expandHome(v)
+			}
+			if err := fs.Set(name, v); err != nil {
+				return fmt.Errorf("invalid value %q for option %s: %w", v, name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	flag "github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `{
+  "profile": "desk",
+  "profiles": {
+    "desk": {"orientation": "left", "screen-width": 2560},
+    "office": {
+      "ssh-ip": "192.168.1.50:22",
+      "ssh-key": ["/keys/a", "/keys/b"],
+      "disable-drag-event": true,
+      "screen-width": 1920
+    }
+  }
+}`
+
+func testConfigFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("orientation", "right", "")
+	fs.Int("screen-width", 1024, "")
+	fs.String("ssh-ip", "10.11.99.1:22", "")
+	fs.StringArray("ssh-key", nil, "")
+	fs.Bool("disable-drag-event", false, "")
+	return fs
+}
+
+func TestConfigValues(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	require.Nil(t, os.WriteFile(file, []byte(testConfig), 0o600))
+	c, err := LoadConfig(file)
+	require.Nil(t, err)
+
+	values, err := c.Values("")
+	require.Nil(t, err)
+	require.Equal(t, map[string][]string{
+		"orientation":  {"left"},
+		"screen-width": {"2560"},
+	}, values)
+
+	values, err = c.Values("office")
+	require.Nil(t, err)
+	require.Equal(t, []string{"/keys/a", "/keys/b"}, values["ssh-key"])
+	require.Equal(t, []string{"true"}, values["disable-drag-event"])
+
+	_, err = c.Values("missing")
+	require.True(t, errors.Is(err, ErrUnknownProfile))
+
+	c, err = LoadConfig(filepath.Join(t.TempDir(), "missing.json"))
+	require.Nil(t, err)
+	values, err = c.Values("")
+	require.Nil(t, err)
+	require.Empty(t, values)
+}
+
+func TestApplyFlagValues(t *testing.T) {
+	fs := testConfigFlagSet()
+	require.Nil(t, fs.Parse([]string{"--screen-width", "800"}))
+	require.Nil(t, ApplyFlagValues(fs, map[string][]string{
+		"screen-width":       {"1920"},
+		"ssh-key":            {"/keys/a", "/keys/b"},
+		"disable-drag-event": {"true"},
+	}))
+	width, _ := fs.GetInt("screen-width")
+	require.Equal(t, 800, width)
+	keys, _ := fs.GetStringArray("ssh-key")
+	require.Equal(t, []string{"/keys/a", "/keys/b"}, keys)
+	drag, _ := fs.GetBool("disable-drag-event")
+	require.True(t, drag)
+	require.True(t, fs.Changed("ssh-key"))
+	require.False(t, fs.Changed("orientation"))
+
+	require.NotNil(t, ApplyFlagValues(testConfigFlagSet(), map[string][]string{"missing": {"1"}}))
+	require.NotNil(t, ApplyFlagValues(testConfigFlagSet(), map[string][]string{"screen-width": {"wide"}}))
+}
+
+func TestApplyFlagValuesExpandsPaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	fs := testConfigFlagSet()
+	fs.String("ssh-config", "", "")
+	MarkPathFlags(fs, "ssh-key", "ssh-config", "missing")
+	require.Nil(t, fs.Parse(nil))
+	require.Nil(t, ApplyFlagValues(fs, map[string][]string{
+		"ssh-key":     {"~/.ssh/id_rm", "/keys/b", "~other/key"},
+		"ssh-config":  {"~"},
+		"orientation": {"~/left"},
+	}))
+	keys, _ := fs.GetStringArray("ssh-key")
+	require.Equal(t, []string{filepath.Join(home, ".ssh/id_rm"), "/keys/b", "~other/key"}, keys)
+	config, _ := fs.GetString("ssh-config")
+	require.Equal(t, home, config)
+	orientation, _ := fs.GetString("orientation")
+	require.Equal(t, "~/left", orientation)
+}
+
+func TestFlagSources(t *testing.T) {
+	fs := testConfigFlagSet()
+	fs.String("ssh-password", "", "")
+	require.Nil(t, fs.Parse([]string{"--screen-width", "800"}))
+	sources := NewFlagSources(fs)
+	env := map[string]string{
+		"REMOUSEABLE_SCREEN_WIDTH": "900",
+		"REMOUSEABLE_SSH_IP":       "192.168.1.2:22",
+		"REMOUSEABLE_SSH_PASSWORD": "secret",
+		"REMOUSEABLE_ORIENTATION":  "",
+	}
+	require.Nil(t, sources.ApplyEnv(fs, func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}))
+	require.Nil(t, sources.Apply(fs, map[string][]string{
+		"ssh-ip":      {"192.168.1.3:22"},
+		"orientation": {"left"},
+	}, "profile office"))
+
+	ip, _ := fs.GetString("ssh-ip")
+	require.Equal(t, "192.168.1.2:22", ip)
+	require.Equal(t, FlagSourceCommandLine, sources.Source("screen-width"))
+	require.Equal(t, "environment variable REMOUSEABLE_SSH_IP", sources.Source("ssh-ip"))
+	require.Equal(t, "profile office", sources.Source("orientation"))
+	require.Equal(t, FlagSourceDefault, sources.Source("ssh-key"))
+	require.Equal(t, []string{
+		"orientation=left (profile office)",
+		"screen-width=800 (command line)",
+		"ssh-ip=192.168.1.2:22 (environment variable REMOUSEABLE_SSH_IP)",
+		"ssh-password=******** (environment variable REMOUSEABLE_SSH_PASSWORD)",
+	}, sources.Describe(fs, "ssh-password"))
+}
@@ -0,0 +1,417 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultUSBAddress is the address of a tablet that is connected over USB.
+const DefaultUSBAddress = "10.11.99.1:22"
+
+const (
+	// DiscoverySourceUSB marks a tablet found at the USB network address.
+	DiscoverySourceUSB = "usb"
+	// DiscoverySourceMDNS marks a tablet that answered an mDNS query.
+	DiscoverySourceMDNS = "mdns"
+	// DiscoverySourceScan marks a tablet found by scanning a local subnet.
+	DiscoverySourceScan = "scan"
+)
+
+// TabletBannerPatterns match the SSH banners that a host found by scanning
+// must send to be reported as a tablet. Older tablet software runs dropbear.
+// Newer versions run a stock OpenSSH build whose banner is the same as that of
+// many other servers, so those tablets are only found at the USB address or
+// over mDNS.
+var TabletBannerPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^SSH-2\.0-dropbear`),
+}
+
+// DefaultMDNSNames are the host names that tablets advertise over mDNS.
+var DefaultMDNSNames = []string{"remarkable.local"}
+
+// defaultProbeTimeout is used when a Discoverer has no Timeout.
+const defaultProbeTimeout = time.Second
+
+// maxScanPrefix is the largest subnet, by prefix length, that is scanned in
+// full. Larger networks are narrowed to the /24 that contains the host.
+const maxScanPrefix = 22
+
+// TabletCandidate is a host that looks like a reMarkable tablet.
+type TabletCandidate struct {
+	// Address is the host and port of the SSH server.
+	Address string
+	// Banner is the SSH version banner the host sent.
+	Banner string
+	// Source is one of the DiscoverySource* values.
+	Source string
+}
+
+// Discoverer searches for tablets that are reachable from the host.
+//
+// The USB network address is always probed and, if enabled, an mDNS query is
+// sent for the tablet host names. Any SSH server at those addresses is a
+// tablet. Each IPv4 network that the host is attached to may also be scanned
+// for SSH servers with a tablet banner.
+type Discoverer struct {
+	// Timeout limits how long each probe may take. The default is one second.
+	Timeout time.Duration
+	// Concurrency limits how many probes run at once.
+	Concurrency int
+	// Scan enables scanning the local subnets.
+	Scan bool
+	// MDNS enables mDNS queries. MDNSNames defaults to DefaultMDNSNames.
+	MDNS      bool
+	MDNSNames []string
+	// Dial and InterfaceAddrs default to the net package implementations and
+	// are replaceable for testing.
+	Dial           func(ctx context.Context, network string, address string) (net.Conn, error)
+	InterfaceAddrs func() ([]net.Addr, error)
+}
+
+// Discover returns all tablets that were found. Tablets found at the USB
+// address come first, followed by mDNS results and then scan results.
+func (d *Discoverer) Discover(ctx context.Context) ([]TabletCandidate, error) {
+	results := make(map[string]TabletCandidate)
+	var lock sync.Mutex
+	record := func(c TabletCandidate) {
+		lock.Lock()
+		defer lock.Unlock()
+		if _, ok := results[c.Address]; !ok {
+			results[c.Address] = c
+		}
+	}
+
+	addresses := []string{DefaultUSBAddress}
+	sources := map[string]string{DefaultUSBAddress: DiscoverySourceUSB}
+	if d.MDNS {
+		ips, err := d.queryMDNS(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			addr := net.JoinHostPort(ip.String(), "22")
+			if _, ok := sources[addr]; !ok {
+				addresses = append(addresses, addr)
+				sources[addr] = DiscoverySourceMDNS
+			}
+		}
+	}
+	if d.Scan {
+		hosts, err := d.scanTargets()
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range hosts {
+			addr := net.JoinHostPort(ip.String(), "22")
+			if _, ok := sources[addr]; !ok {
+				addresses = append(addresses, addr)
+				sources[addr] = DiscoverySourceScan
+			}
+		}
+	}
+
+	concurrency := d.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := range work {
+				banner, err := d.probe(ctx, addr)
+				if err != nil || !acceptBanner(sources[addr], banner) {
+					continue
+				}
+				record(TabletCandidate{Address: addr, Banner: banner, Source: sources[addr]})
+			}
+		}()
+	}
+feed:
+	for _, addr := range addresses {
+		select {
+		case work <- addr:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	found := make([]TabletCandidate, 0, len(results))
+	for _, addr := range addresses {
+		if c, ok := results[addr]; ok {
+			found = append(found, c)
+		}
+	}
+	return found, ctx.Err()
+}
+
+func (d *Discoverer) timeout() time.Duration {
+	if d.Timeout <= 0 {
+		return defaultProbeTimeout
+	}
+	return d.Timeout
+}
+
+// IsTabletBanner reports whether an SSH banner matches one of the
+// TabletBannerPatterns.
+func IsTabletBanner(banner string) bool {
+	for _, p := range TabletBannerPatterns {
+		if p.MatchString(banner) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptBanner reports whether a host that sent the banner is a tablet. The
+// USB address and the mDNS names belong to tablets so any SSH server found
+// there is accepted, while scanned hosts must send a tablet banner.
+func acceptBanner(source string, banner string) bool {
+	if source == DiscoverySourceScan {
+		return IsTabletBanner(banner)
+	}
+	return strings.HasPrefix(banner, "SSH-")
+}
+
+// probe connects to an address and returns the SSH banner it sends.
+func (d *Discoverer) probe(ctx context.Context, addr string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout())
+	defer cancel()
+	dial := d.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetReadDeadline(deadline)
+	}
+	// RFC 4253 limits the banner line to 255 characters.
+	line, err := bufio.NewReaderSize(conn, 256).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// scanTargets lists the addresses of every other host on the local IPv4
+// networks.
+func (d *Discoverer) scanTargets() ([]net.IP, error) {
+	interfaceAddrs := d.InterfaceAddrs
+	if interfaceAddrs == nil {
+		interfaceAddrs = net.InterfaceAddrs
+	}
+	addrs, err := interfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]net.IP, 0)
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		self := ipnet.IP.To4()
+		if self == nil || self.IsLoopback() || self.IsLinkLocalUnicast() {
+			continue
+		}
+		ones, bits := ipnet.Mask.Size()
+		if bits == 8*net.IPv6len {
+			// IPv4 networks are sometimes described with a 16 byte mask.
+			ones, bits = ones-8*(net.IPv6len-net.IPv4len), 8*net.IPv4len
+		}
+		if bits != 8*net.IPv4len || ones < 0 {
+			continue
+		}
+		if ones < maxScanPrefix {
+			ones = 24
+		}
+		mask := net.CIDRMask(ones, 32)
+		base := binary.BigEndian.Uint32(self.Mask(mask))
+		size := uint32(1) << uint32(32-ones)
+		for i := uint32(1); i+1 < size; i++ {
+			ip := make(net.IP, 4)
+			binary.BigEndian.PutUint32(ip, base+i)
+			if ip.Equal(self) {
+				continue
+			}
+			targets = append(targets, ip)
+		}
+	}
+	return targets, nil
+}
+
+// queryMDNS sends a one-shot mDNS query for the tablet names and collects the
+// IPv4 addresses from any answers that arrive before the timeout or until ctx
+// is cancelled.
+func (d *Discoverer) queryMDNS(ctx context.Context) ([]net.IP, error) {
+	names := d.MDNSNames
+	if len(names) == 0 {
+		names = DefaultMDNSNames
+	}
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	group := &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+	for _, name := range names {
+		if _, err = conn.WriteToUDP(buildMDNSQuery(name), group); err != nil {
+			// Hosts without a multicast route cannot use mDNS. That is not
+			// fatal to discovery as a whole.
+			return nil, nil
+		}
+	}
+	deadline := time.Now().Add(d.timeout())
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	_ = conn.SetReadDeadline(deadline)
+	// Cancellation does not interrupt a blocked read so the deadline is moved
+	// up to unblock it instead.
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+	ips := make([]net.IP, 0)
+	buf := make([]byte, 9000)
+	for {
+		n, _, readErr := conn.ReadFromUDP(buf)
+		if readErr != nil {
+			var netErr net.Error
+			if errors.As(readErr, &netErr) && netErr.Timeout() {
+				return ips, ctx.Err()
+			}
+			return ips, readErr
+		}
+		answers, parseErr := parseMDNSAnswers(buf[:n])
+		if parseErr != nil {
+			continue
+		}
+		for name, ip := range answers {
+			for _, want := range names {
+				if strings.EqualFold(name, want) {
+					ips = append(ips, ip)
+				}
+			}
+		}
+	}
+}
+
+// buildMDNSQuery creates a DNS query for the A record of a name with the
+// unicast response bit set so that the answer is sent back to our socket.
+func buildMDNSQuery(name string) []byte {
+	msg := make([]byte, 12, 12+len(name)+6)
+	binary.BigEndian.PutUint16(msg[4:], 1) // QDCOUNT
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, 1)      // TYPE A
+	msg = binary.BigEndian.AppendUint16(msg, 0x8001) // QU bit and CLASS IN
+	return msg
+}
+
+// parseMDNSAnswers extracts all A records from a DNS message.
+func parseMDNSAnswers(msg []byte) (map[string]net.IP, error) {
+	if len(msg) < 12 {
+		return nil, fmt.Errorf("dns message too short")
+	}
+	questions := int(binary.BigEndian.Uint16(msg[4:]))
+	records := int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + int(binary.BigEndian.Uint16(msg[10:]))
+	offset := 12
+	for i := 0; i < questions; i++ {
+		_, next, err := readDNSName(msg, offset)
+		if err != nil {
+			return nil, err
+		}
+		offset = next + 4
+	}
+	answers := make(map[string]net.IP)
+	for i := 0; i < records; i++ {
+		name, next, err := readDNSName(msg, offset)
+		if err != nil {
+			return nil, err
+		}
+		if next+10 > len(msg) {
+			return nil, fmt.Errorf("dns record truncated")
+		}
+		rtype := binary.BigEndian.Uint16(msg[next:])
+		length := int(binary.BigEndian.Uint16(msg[next+8:]))
+		data := next + 10
+		if data+length > len(msg) {
+			return nil, fmt.Errorf("dns record truncated")
+		}
+		if rtype == 1 && length == 4 {
+			answers[name] = net.IPv4(msg[data], msg[data+1], msg[data+2], msg[data+3])
+		}
+		offset = data + length
+	}
+	return answers, nil
+}
+
+// readDNSName decodes a possibly compressed name and returns it along with
+// the offset of the data that follows it.
+func readDNSName(msg []byte, offset int) (string, int, error) {
+	labels := make([]string, 0, 4)
+	next := -1
+	for jumps := 0; jumps < 16; {
+		if offset >= len(msg) {
+			return "", 0, fmt.Errorf("dns name truncated")
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = offset + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case length&0xC0 == 0xC0:
+			if offset+1 >= len(msg) {
+				return "", 0, fmt.Errorf("dns name truncated")
+			}
+			if next < 0 {
+				next = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:]) & 0x3FFF)
+			jumps++
+		default:
+			if offset+1+length > len(msg) {
+				return "", 0, fmt.Errorf("dns name truncated")
+			}
+			labels = append(labels, string(msg[offset+1:offset+1+length]))
+			offset = offset + 1 + length
+		}
+	}
+	return "", 0, fmt.Errorf("dns name has too many compression pointers")
+}
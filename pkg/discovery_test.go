@@ -0,0 +1,163 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsTabletBanner(t *testing.T) {
+	tests := []struct {
+		banner string
+		want   bool
+	}{
+		{banner: "SSH-2.0-dropbear_2019.78", want: true},
+		{banner: "SSH-2.0-dropbear", want: true},
+		{banner: "SSH-2.0-OpenSSH_9.6", want: false},
+		{banner: "SSH-2.0-OpenSSH_8.9p1", want: false},
+		{banner: "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.10", want: false},
+		{banner: "SSH-2.0-OpenSSH_9.2p1 Debian-2+deb12u3", want: false},
+		{banner: "SSH-2.0-Go", want: false},
+		{banner: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.banner, func(t *testing.T) {
+			require.Equal(t, tt.want, IsTabletBanner(tt.banner))
+		})
+	}
+}
+
+func TestDiscovererDiscover(t *testing.T) {
+	banners := map[string]string{
+		DefaultUSBAddress: "SSH-2.0-OpenSSH_9.6",
+		"192.168.1.2:22":  "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.10",
+		"192.168.1.4:22":  "SSH-2.0-OpenSSH_9.6",
+		"192.168.1.5:22":  "SSH-2.0-dropbear_2019.78",
+	}
+	probed := make(chan string, 16)
+	d := &Discoverer{
+		Timeout:     time.Second,
+		Concurrency: 4,
+		Scan:        true,
+		InterfaceAddrs: func() ([]net.Addr, error) {
+			return []net.Addr{
+				&net.IPNet{IP: net.IPv4(127, 0, 0, 1), Mask: net.CIDRMask(8, 32)},
+				&net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)},
+				&net.IPNet{IP: net.IPv4(192, 168, 1, 1), Mask: net.CIDRMask(29, 32)},
+			}, nil
+		},
+		Dial: func(_ context.Context, _ string, addr string) (net.Conn, error) {
+			probed <- addr
+			banner, ok := banners[addr]
+			if !ok {
+				return nil, fmt.Errorf("connection refused")
+			}
+			client, server := net.Pipe()
+			go func() {
+				_, _ = server.Write([]byte(banner + "\r\n"))
+				_ = server.Close()
+			}()
+			return client, nil
+		},
+	}
+	found, err := d.Discover(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, []TabletCandidate{
+		// A stock OpenSSH banner is only trusted at the USB address.
+		{Address: DefaultUSBAddress, Banner: "SSH-2.0-OpenSSH_9.6", Source: DiscoverySourceUSB},
+		{Address: "192.168.1.5:22", Banner: "SSH-2.0-dropbear_2019.78", Source: DiscoverySourceScan},
+	}, found)
+	close(probed)
+	all := make([]string, 0, len(probed))
+	for addr := range probed {
+		all = append(all, addr)
+	}
+	// The host itself, the network address, and the broadcast address are
+	// never probed.
+	require.ElementsMatch(t, []string{
+		DefaultUSBAddress,
+		"192.168.1.2:22", "192.168.1.3:22", "192.168.1.4:22",
+		"192.168.1.5:22", "192.168.1.6:22",
+	}, all)
+}
+
+func TestDiscovererDiscoverCancelled(t *testing.T) {
+	d := &Discoverer{
+		Timeout:     time.Minute,
+		Concurrency: 2,
+		Scan:        true,
+		InterfaceAddrs: func() ([]net.Addr, error) {
+			return []net.Addr{&net.IPNet{IP: net.IPv4(192, 168, 1, 1), Mask: net.CIDRMask(24, 32)}}, nil
+		},
+		Dial: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	found, err := d.Discover(ctx)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Empty(t, found)
+	require.Less(t, time.Since(start), 10*time.Second)
+}
+
+func TestDiscovererScanTargetsNarrowsLargeNetworks(t *testing.T) {
+	d := &Discoverer{
+		InterfaceAddrs: func() ([]net.Addr, error) {
+			return []net.Addr{
+				&net.IPNet{IP: net.IPv4(10, 1, 2, 3), Mask: net.CIDRMask(104, 128)},
+			}, nil
+		},
+	}
+	targets, err := d.scanTargets()
+	require.Nil(t, err)
+	require.Len(t, targets, 253)
+	require.Equal(t, "10.1.2.1", targets[0].String())
+	require.Equal(t, "10.1.2.254", targets[len(targets)-1].String())
+}
+
+func TestParseMDNSAnswers(t *testing.T) {
+	query := buildMDNSQuery("remarkable.local")
+	// Build a response that repeats the question and answers it using a
+	// compressed name that points back at the question.
+	resp := append([]byte{}, query...)
+	binary.BigEndian.PutUint16(resp[2:], 0x8400)
+	binary.BigEndian.PutUint16(resp[6:], 1)
+	resp = append(resp, 0xC0, 12)
+	resp = binary.BigEndian.AppendUint16(resp, 1)
+	resp = binary.BigEndian.AppendUint16(resp, 0x8001)
+	resp = binary.BigEndian.AppendUint32(resp, 120)
+	resp = binary.BigEndian.AppendUint16(resp, 4)
+	resp = append(resp, 192, 168, 1, 77)
+
+	answers, err := parseMDNSAnswers(resp)
+	require.Nil(t, err)
+	require.Equal(t, "192.168.1.77", answers["remarkable.local"].String())
+
+	_, err = parseMDNSAnswers(resp[:len(resp)-2])
+	require.NotNil(t, err)
+	_, err = parseMDNSAnswers([]byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0xC0, 12})
+	require.NotNil(t, err)
+}
@@ -23,7 +23,7 @@ type EvdevEvent struct {
 	// Time of the event
 	Time time.Time
 	// Type is one of the EV_* named constants in evdevcodes.go
-	Type uint16
+	Type EventType
 	// Code is the relevant event constant from evdevcodes.go
 	Code uint16
 	// Numeric value of the event. Dependent on the event type.
@@ -62,8 +62,8 @@ const (
 
 // StateChangeMove contains mouse movement data.
 type StateChangeMove struct {
-	X int
-	Y int
+	X float64
+	Y float64
 }
 
 // Type returns the specific change type.
@@ -73,8 +73,8 @@ func (*StateChangeMove) Type() string {
 
 // StateChangeDrag contains mouse movement data when clicked.
 type StateChangeDrag struct {
-	X int
-	Y int
+	X float64
+	Y float64
 }
 
 // Type returns the specific change type.
@@ -121,15 +121,18 @@ type StateMachine interface {
 }
 
 // PositionScaler implements scaling rules for converting x/y coordinates
-// between differently sized screens.
+// between differently sized screens. Coordinates are not rounded so that no
+// precision is lost before they reach the Driver.
 type PositionScaler interface {
-	ScalePosition(x int, y int) (int, int)
+	ScalePosition(x float64, y float64) (float64, float64)
 }
 
-// Driver is used to control a host system.
+// Driver is used to control a host system. Positions are given in screen
+// pixels and may have a fractional part. Drivers that can only move to whole
+// pixels should round them with RoundPosition.
 type Driver interface {
-	MoveMouse(x int, y int) error
-	DragMouse(x int, y int) error
+	MoveMouse(x float64, y float64) error
+	DragMouse(x float64, y float64) error
 	Click() error
 	Unclick() error
 	GetSize() (width int, height int, err error)
@@ -1,3 +1,5 @@
+//go:build cgo
+
 // This file is part of remouseable.
 //
 // remouseable is free software: you can redistribute it and/or modify
@@ -16,6 +18,12 @@ package remouseable
 
 import "github.com/kevinconway/remouseable/pkg/internal/robotgo"
 
+func init() {
+	RegisterDriver(DriverRobotgo, func(DriverOptions) (Driver, error) {
+		return &RobotgoDriver{}, nil
+	})
+}
+
 // RobotgoDriver implements Driver using the robotgo cgo library.
 type RobotgoDriver struct{}
 
@@ -38,17 +46,17 @@ func (*RobotgoDriver) Unclick() error {
 }
 
 // MoveMouse sets the mouse to a specified location.
-func (*RobotgoDriver) MoveMouse(x int, y int) error {
+func (*RobotgoDriver) MoveMouse(x float64, y float64) error {
 	// Reversing the x/y due to robotgo seemingly having an opposite
 	// x/y concept as the typical event source of evdev, etc.
-	robotgo.MoveMouse(x, y)
+	robotgo.MoveMouse(RoundPosition(x, y))
 	return nil
 }
 
 // DragMouse sets the mouse to a specified location while dragging a screen element.
-func (*RobotgoDriver) DragMouse(x int, y int) error {
+func (*RobotgoDriver) DragMouse(x float64, y float64) error {
 	// Reversing the x/y due to robotgo seemingly having an opposite
 	// x/y concept as the typical event source of evdev, etc.
-	robotgo.DragMouse(x, y)
+	robotgo.DragMouse(RoundPosition(x, y))
 	return nil
 }
@@ -0,0 +1,148 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownDriver is returned when a driver is selected that is not
+// registered. Some drivers are only available on some platforms.
+var ErrUnknownDriver = errors.New("unknown driver")
+
+// ErrNoDriver is returned by DetectDriver when none of the registered drivers
+// can move the mouse in the current session.
+var ErrNoDriver = errors.New("no usable driver")
+
+const (
+	// DriverRobotgo is the name of the RobotgoDriver.
+	DriverRobotgo = "robotgo"
+	// DriverXTest is the name of the XTestDriver.
+	DriverXTest = "xtest"
+	// DriverUinput is the name of the UinputDriver.
+	DriverUinput = "uinput"
+	// DriverLog is the name of the LoggingDriver.
+	DriverLog = "log"
+	// DriverNull is the name of the NullDriver.
+	DriverNull = "null"
+	// DriverAuto selects a driver for the current session with DetectDriver.
+	DriverAuto = "auto"
+	// DefaultDriver is the driver that is used when none is selected.
+	DefaultDriver = DriverAuto
+)
+
+// DriverOptions are the settings given to a DriverFactory.
+type DriverOptions struct {
+	// ScreenWidth and ScreenHeight are the size of the host screen. They are
+	// zero if the size was not given and should be reported by the driver.
+	ScreenWidth  int
+	ScreenHeight int
+	// Output is where drivers that print their actions write them.
+	Output io.Writer
+}
+
+// DriverFactory creates a Driver. Drivers are created only after one is
+// selected so that a driver that needs a display, or a device, is never
+// initialized unless it is used.
+type DriverFactory func(DriverOptions) (Driver, error)
+
+var (
+	driversLock sync.Mutex
+	drivers     = make(map[string]DriverFactory)
+)
+
+// RegisterDriver makes a driver available by name. Drivers that are only
+// available on some platforms register themselves from files with the
+// matching build constraints.
+func RegisterDriver(name string, factory DriverFactory) {
+	driversLock.Lock()
+	defer driversLock.Unlock()
+	drivers[name] = factory
+}
+
+// DriverNames returns the names of the registered drivers in sorted order.
+func DriverNames() []string {
+	driversLock.Lock()
+	defer driversLock.Unlock()
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsWaylandSession reports whether the current desktop session is Wayland.
+// XDG_SESSION_TYPE is set by the login manager and is trusted when it is
+// present. Otherwise a WAYLAND_DISPLAY means a compositor is running.
+func IsWaylandSession(getenv func(string) string) bool {
+	switch strings.ToLower(getenv("XDG_SESSION_TYPE")) {
+	case "wayland":
+		return true
+	case "x11":
+		return false
+	}
+	return getenv("WAYLAND_DISPLAY") != ""
+}
+
+// DetectDriver chooses a driver for the current session. The robotgo and
+// xtest drivers use X11 which, in a Wayland session, only reaches XWayland
+// windows or triggers a remote desktop prompt. Wayland sessions use the uinput
+// driver instead when it is available. Otherwise robotgo is preferred and
+// xtest is used in builds without cgo. Builds without cgo for operating
+// systems that do not use X11 have neither, which results in ErrNoDriver.
+func DetectDriver(getenv func(string) string) (string, error) {
+	driversLock.Lock()
+	defer driversLock.Unlock()
+	if _, ok := drivers[DriverUinput]; ok && IsWaylandSession(getenv) {
+		return DriverUinput, nil
+	}
+	for _, name := range []string{DriverRobotgo, DriverXTest} {
+		if _, ok := drivers[name]; ok {
+			return name, nil
+		}
+	}
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("%w for this system. Use a build made with cgo or choose one of: %s", ErrNoDriver, strings.Join(names, ", "))
+}
+
+// NewDriver creates the named driver.
+func NewDriver(name string, opts DriverOptions) (Driver, error) {
+	driversLock.Lock()
+	factory, ok := drivers[name]
+	driversLock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %s. Available drivers are: %s", ErrUnknownDriver, name, strings.Join(DriverNames(), ", "))
+	}
+	return factory(opts)
+}
+
+// CloseDriver releases any resources held by a driver. Drivers that hold
+// resources, such as a virtual input device, implement io.Closer.
+func CloseDriver(d Driver) error {
+	if c, ok := d.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDriver(t *testing.T) {
+	names := DriverNames()
+	require.Contains(t, names, DriverLog)
+	require.Contains(t, names, DriverNull)
+
+	d, err := NewDriver(DriverNull, DriverOptions{ScreenWidth: 800})
+	require.Nil(t, err)
+	w, h, err := d.GetSize()
+	require.Nil(t, err)
+	require.Equal(t, 800, w)
+	require.Equal(t, DefaultScreenHeight, h)
+	require.Nil(t, CloseDriver(d))
+
+	_, err = NewDriver("missing", DriverOptions{})
+	require.True(t, errors.Is(err, ErrUnknownDriver))
+}
+
+func TestLoggingDriver(t *testing.T) {
+	out := &bytes.Buffer{}
+	d, err := NewDriver(DriverLog, DriverOptions{Output: out})
+	require.Nil(t, err)
+	require.Nil(t, d.MoveMouse(10.5, 0))
+	require.Nil(t, d.Click())
+	require.Nil(t, d.DragMouse(11, 2.25))
+	require.Nil(t, d.Unclick())
+	require.Equal(t, `{"action":"move","x":10.5,"y":0}
+{"action":"click"}
+{"action":"drag","x":11,"y":2.25}
+{"action":"unclick"}
+`, out.String())
+}
+
+func TestDetectDriver(t *testing.T) {
+	// Builds without cgo have no robotgo driver and use xtest for X11.
+	registered := make(map[string]bool)
+	for _, name := range DriverNames() {
+		registered[name] = true
+	}
+	if !registered[DriverRobotgo] && !registered[DriverXTest] {
+		t.Skip("this build has no X11 driver")
+	}
+	x11 := DriverRobotgo
+	if !registered[DriverRobotgo] {
+		x11 = DriverXTest
+	}
+	wayland := x11
+	if registered[DriverUinput] {
+		wayland = DriverUinput
+	}
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{name: "x11", env: map[string]string{"XDG_SESSION_TYPE": "x11", "WAYLAND_DISPLAY": "wayland-0"}, want: x11},
+		{name: "wayland", env: map[string]string{"XDG_SESSION_TYPE": "wayland"}, want: wayland},
+		{name: "wayland display", env: map[string]string{"WAYLAND_DISPLAY": "wayland-0"}, want: wayland},
+		{name: "empty", env: map[string]string{}, want: x11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectDriver(func(k string) string { return tt.env[k] })
+			require.Nil(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDetectDriverNoDriver(t *testing.T) {
+	driversLock.Lock()
+	saved := drivers
+	drivers = map[string]DriverFactory{DriverLog: saved[DriverLog]}
+	driversLock.Unlock()
+	defer func() {
+		driversLock.Lock()
+		drivers = saved
+		driversLock.Unlock()
+	}()
+
+	_, err := DetectDriver(func(string) string { return "" })
+	require.True(t, errors.Is(err, ErrNoDriver))
+	require.Contains(t, err.Error(), DriverLog)
+}
@@ -2,782 +2,892 @@ package remouseable
 
 // Code generated DO NOT EDIT
 
-// Generated using Linux 5.0.0-31-generic x86_64.
-// Generated at 2019-10-17T00:10:35-05:00.
-// Generated from /usr/include/linux/input.h, /usr/include/linux/input-event-codes.h.
+// Generated from the Linux 6.1.153 headers input.h, input-event-codes.h.
 const (
-	EV_VERSION                   = 0x010001
-	ID_BUS                       = 0
-	ID_VENDOR                    = 1
-	ID_PRODUCT                   = 2
-	ID_VERSION                   = 3
-	BUS_PCI                      = 0x01
-	BUS_ISAPNP                   = 0x02
-	BUS_USB                      = 0x03
-	BUS_HIL                      = 0x04
-	BUS_BLUETOOTH                = 0x05
-	BUS_VIRTUAL                  = 0x06
-	BUS_ISA                      = 0x10
-	BUS_I8042                    = 0x11
-	BUS_XTKBD                    = 0x12
-	BUS_RS232                    = 0x13
-	BUS_GAMEPORT                 = 0x14
-	BUS_PARPORT                  = 0x15
-	BUS_AMIGA                    = 0x16
-	BUS_ADB                      = 0x17
-	BUS_I2C                      = 0x18
-	BUS_HOST                     = 0x19
-	BUS_GSC                      = 0x1A
-	BUS_ATARI                    = 0x1B
-	BUS_SPI                      = 0x1C
-	BUS_RMI                      = 0x1D
-	BUS_CEC                      = 0x1E
-	BUS_INTEL_ISHTP              = 0x1F
-	FF_STATUS_STOPPED            = 0x00
-	FF_STATUS_PLAYING            = 0x01
-	FF_STATUS_MAX                = 0x01
-	FF_RUMBLE                    = 0x50
-	FF_PERIODIC                  = 0x51
-	FF_CONSTANT                  = 0x52
-	FF_SPRING                    = 0x53
-	FF_FRICTION                  = 0x54
-	FF_DAMPER                    = 0x55
-	FF_INERTIA                   = 0x56
-	FF_RAMP                      = 0x57
-	FF_EFFECT_MIN                = FF_RUMBLE
-	FF_EFFECT_MAX                = FF_RAMP
-	FF_SQUARE                    = 0x58
-	FF_TRIANGLE                  = 0x59
-	FF_SINE                      = 0x5a
-	FF_SAW_UP                    = 0x5b
-	FF_SAW_DOWN                  = 0x5c
-	FF_CUSTOM                    = 0x5d
-	FF_WAVEFORM_MIN              = FF_SQUARE
-	FF_WAVEFORM_MAX              = FF_CUSTOM
-	FF_GAIN                      = 0x60
-	FF_AUTOCENTER                = 0x61
-	FF_MAX_EFFECTS               = FF_GAIN
-	FF_MAX                       = 0x7f
-	EV_SYN                       = 0x00
-	EV_KEY                       = 0x01
-	EV_REL                       = 0x02
-	EV_ABS                       = 0x03
-	EV_MSC                       = 0x04
-	EV_SW                        = 0x05
-	EV_LED                       = 0x11
-	EV_SND                       = 0x12
-	EV_REP                       = 0x14
-	EV_FF                        = 0x15
-	EV_PWR                       = 0x16
-	EV_FF_STATUS                 = 0x17
-	EV_MAX                       = 0x1f
-	SYN_REPORT                   = 0
-	SYN_CONFIG                   = 1
-	SYN_MT_REPORT                = 2
-	SYN_DROPPED                  = 3
-	SYN_MAX                      = 0xf
-	KEY_RESERVED                 = 0
-	KEY_ESC                      = 1
-	KEY_1                        = 2
-	KEY_2                        = 3
-	KEY_3                        = 4
-	KEY_4                        = 5
-	KEY_5                        = 6
-	KEY_6                        = 7
-	KEY_7                        = 8
-	KEY_8                        = 9
-	KEY_9                        = 10
-	KEY_0                        = 11
-	KEY_MINUS                    = 12
-	KEY_EQUAL                    = 13
-	KEY_BACKSPACE                = 14
-	KEY_TAB                      = 15
-	KEY_Q                        = 16
-	KEY_W                        = 17
-	KEY_E                        = 18
-	KEY_R                        = 19
-	KEY_T                        = 20
-	KEY_Y                        = 21
-	KEY_U                        = 22
-	KEY_I                        = 23
-	KEY_O                        = 24
-	KEY_P                        = 25
-	KEY_LEFTBRACE                = 26
-	KEY_RIGHTBRACE               = 27
-	KEY_ENTER                    = 28
-	KEY_LEFTCTRL                 = 29
-	KEY_A                        = 30
-	KEY_S                        = 31
-	KEY_D                        = 32
-	KEY_F                        = 33
-	KEY_G                        = 34
-	KEY_H                        = 35
-	KEY_J                        = 36
-	KEY_K                        = 37
-	KEY_L                        = 38
-	KEY_SEMICOLON                = 39
-	KEY_APOSTROPHE               = 40
-	KEY_GRAVE                    = 41
-	KEY_LEFTSHIFT                = 42
-	KEY_BACKSLASH                = 43
-	KEY_Z                        = 44
-	KEY_X                        = 45
-	KEY_C                        = 46
-	KEY_V                        = 47
-	KEY_B                        = 48
-	KEY_N                        = 49
-	KEY_M                        = 50
-	KEY_COMMA                    = 51
-	KEY_DOT                      = 52
-	KEY_SLASH                    = 53
-	KEY_RIGHTSHIFT               = 54
-	KEY_KPASTERISK               = 55
-	KEY_LEFTALT                  = 56
-	KEY_SPACE                    = 57
-	KEY_CAPSLOCK                 = 58
-	KEY_F1                       = 59
-	KEY_F2                       = 60
-	KEY_F3                       = 61
-	KEY_F4                       = 62
-	KEY_F5                       = 63
-	KEY_F6                       = 64
-	KEY_F7                       = 65
-	KEY_F8                       = 66
-	KEY_F9                       = 67
-	KEY_F10                      = 68
-	KEY_NUMLOCK                  = 69
-	KEY_SCROLLLOCK               = 70
-	KEY_KP7                      = 71
-	KEY_KP8                      = 72
-	KEY_KP9                      = 73
-	KEY_KPMINUS                  = 74
-	KEY_KP4                      = 75
-	KEY_KP5                      = 76
-	KEY_KP6                      = 77
-	KEY_KPPLUS                   = 78
-	KEY_KP1                      = 79
-	KEY_KP2                      = 80
-	KEY_KP3                      = 81
-	KEY_KP0                      = 82
-	KEY_KPDOT                    = 83
-	KEY_ZENKAKUHANKAKU           = 85
-	KEY_102ND                    = 86
-	KEY_F11                      = 87
-	KEY_F12                      = 88
-	KEY_RO                       = 89
-	KEY_KATAKANA                 = 90
-	KEY_HIRAGANA                 = 91
-	KEY_HENKAN                   = 92
-	KEY_KATAKANAHIRAGANA         = 93
-	KEY_MUHENKAN                 = 94
-	KEY_KPJPCOMMA                = 95
-	KEY_KPENTER                  = 96
-	KEY_RIGHTCTRL                = 97
-	KEY_KPSLASH                  = 98
-	KEY_SYSRQ                    = 99
-	KEY_RIGHTALT                 = 100
-	KEY_LINEFEED                 = 101
-	KEY_HOME                     = 102
-	KEY_UP                       = 103
-	KEY_PAGEUP                   = 104
-	KEY_LEFT                     = 105
-	KEY_RIGHT                    = 106
-	KEY_END                      = 107
-	KEY_DOWN                     = 108
-	KEY_PAGEDOWN                 = 109
-	KEY_INSERT                   = 110
-	KEY_DELETE                   = 111
-	KEY_MACRO                    = 112
-	KEY_MUTE                     = 113
-	KEY_VOLUMEDOWN               = 114
-	KEY_VOLUMEUP                 = 115
-	KEY_POWER                    = 116
-	KEY_KPEQUAL                  = 117
-	KEY_KPPLUSMINUS              = 118
-	KEY_PAUSE                    = 119
-	KEY_SCALE                    = 120
-	KEY_KPCOMMA                  = 121
-	KEY_HANGEUL                  = 122
-	KEY_HANGUEL                  = KEY_HANGEUL
-	KEY_HANJA                    = 123
-	KEY_YEN                      = 124
-	KEY_LEFTMETA                 = 125
-	KEY_RIGHTMETA                = 126
-	KEY_COMPOSE                  = 127
-	KEY_STOP                     = 128
-	KEY_AGAIN                    = 129
-	KEY_PROPS                    = 130
-	KEY_UNDO                     = 131
-	KEY_FRONT                    = 132
-	KEY_COPY                     = 133
-	KEY_OPEN                     = 134
-	KEY_PASTE                    = 135
-	KEY_FIND                     = 136
-	KEY_CUT                      = 137
-	KEY_HELP                     = 138
-	KEY_MENU                     = 139
-	KEY_CALC                     = 140
-	KEY_SETUP                    = 141
-	KEY_SLEEP                    = 142
-	KEY_WAKEUP                   = 143
-	KEY_FILE                     = 144
-	KEY_SENDFILE                 = 145
-	KEY_DELETEFILE               = 146
-	KEY_XFER                     = 147
-	KEY_PROG1                    = 148
-	KEY_PROG2                    = 149
-	KEY_WWW                      = 150
-	KEY_MSDOS                    = 151
-	KEY_COFFEE                   = 152
-	KEY_SCREENLOCK               = KEY_COFFEE
-	KEY_ROTATE_DISPLAY           = 153
-	KEY_DIRECTION                = KEY_ROTATE_DISPLAY
-	KEY_CYCLEWINDOWS             = 154
-	KEY_MAIL                     = 155
-	KEY_BOOKMARKS                = 156
-	KEY_COMPUTER                 = 157
-	KEY_BACK                     = 158
-	KEY_FORWARD                  = 159
-	KEY_CLOSECD                  = 160
-	KEY_EJECTCD                  = 161
-	KEY_EJECTCLOSECD             = 162
-	KEY_NEXTSONG                 = 163
-	KEY_PLAYPAUSE                = 164
-	KEY_PREVIOUSSONG             = 165
-	KEY_STOPCD                   = 166
-	KEY_RECORD                   = 167
-	KEY_REWIND                   = 168
-	KEY_PHONE                    = 169
-	KEY_ISO                      = 170
-	KEY_CONFIG                   = 171
-	KEY_HOMEPAGE                 = 172
-	KEY_REFRESH                  = 173
-	KEY_EXIT                     = 174
-	KEY_MOVE                     = 175
-	KEY_EDIT                     = 176
-	KEY_SCROLLUP                 = 177
-	KEY_SCROLLDOWN               = 178
-	KEY_KPLEFTPAREN              = 179
-	KEY_KPRIGHTPAREN             = 180
-	KEY_NEW                      = 181
-	KEY_REDO                     = 182
-	KEY_F13                      = 183
-	KEY_F14                      = 184
-	KEY_F15                      = 185
-	KEY_F16                      = 186
-	KEY_F17                      = 187
-	KEY_F18                      = 188
-	KEY_F19                      = 189
-	KEY_F20                      = 190
-	KEY_F21                      = 191
-	KEY_F22                      = 192
-	KEY_F23                      = 193
-	KEY_F24                      = 194
-	KEY_PLAYCD                   = 200
-	KEY_PAUSECD                  = 201
-	KEY_PROG3                    = 202
-	KEY_PROG4                    = 203
-	KEY_DASHBOARD                = 204
-	KEY_SUSPEND                  = 205
-	KEY_CLOSE                    = 206
-	KEY_PLAY                     = 207
-	KEY_FASTFORWARD              = 208
-	KEY_BASSBOOST                = 209
-	KEY_PRINT                    = 210
-	KEY_HP                       = 211
-	KEY_CAMERA                   = 212
-	KEY_SOUND                    = 213
-	KEY_QUESTION                 = 214
-	KEY_EMAIL                    = 215
-	KEY_CHAT                     = 216
-	KEY_SEARCH                   = 217
-	KEY_CONNECT                  = 218
-	KEY_FINANCE                  = 219
-	KEY_SPORT                    = 220
-	KEY_SHOP                     = 221
-	KEY_ALTERASE                 = 222
-	KEY_CANCEL                   = 223
-	KEY_BRIGHTNESSDOWN           = 224
-	KEY_BRIGHTNESSUP             = 225
-	KEY_MEDIA                    = 226
-	KEY_SWITCHVIDEOMODE          = 227
-	KEY_KBDILLUMTOGGLE           = 228
-	KEY_KBDILLUMDOWN             = 229
-	KEY_KBDILLUMUP               = 230
-	KEY_SEND                     = 231
-	KEY_REPLY                    = 232
-	KEY_FORWARDMAIL              = 233
-	KEY_SAVE                     = 234
-	KEY_DOCUMENTS                = 235
-	KEY_BATTERY                  = 236
-	KEY_BLUETOOTH                = 237
-	KEY_WLAN                     = 238
-	KEY_UWB                      = 239
-	KEY_UNKNOWN                  = 240
-	KEY_VIDEO_NEXT               = 241
-	KEY_VIDEO_PREV               = 242
-	KEY_BRIGHTNESS_CYCLE         = 243
-	KEY_BRIGHTNESS_AUTO          = 244
-	KEY_BRIGHTNESS_ZERO          = KEY_BRIGHTNESS_AUTO
-	KEY_DISPLAY_OFF              = 245
-	KEY_WWAN                     = 246
-	KEY_WIMAX                    = KEY_WWAN
-	KEY_RFKILL                   = 247
-	KEY_MICMUTE                  = 248
-	BTN_MISC                     = 0x100
-	BTN_0                        = 0x100
-	BTN_1                        = 0x101
-	BTN_2                        = 0x102
-	BTN_3                        = 0x103
-	BTN_4                        = 0x104
-	BTN_5                        = 0x105
-	BTN_6                        = 0x106
-	BTN_7                        = 0x107
-	BTN_8                        = 0x108
-	BTN_9                        = 0x109
-	BTN_MOUSE                    = 0x110
-	BTN_LEFT                     = 0x110
-	BTN_RIGHT                    = 0x111
-	BTN_MIDDLE                   = 0x112
-	BTN_SIDE                     = 0x113
-	BTN_EXTRA                    = 0x114
-	BTN_FORWARD                  = 0x115
-	BTN_BACK                     = 0x116
-	BTN_TASK                     = 0x117
-	BTN_JOYSTICK                 = 0x120
-	BTN_TRIGGER                  = 0x120
-	BTN_THUMB                    = 0x121
-	BTN_THUMB2                   = 0x122
-	BTN_TOP                      = 0x123
-	BTN_TOP2                     = 0x124
-	BTN_PINKIE                   = 0x125
-	BTN_BASE                     = 0x126
-	BTN_BASE2                    = 0x127
-	BTN_BASE3                    = 0x128
-	BTN_BASE4                    = 0x129
-	BTN_BASE5                    = 0x12a
-	BTN_BASE6                    = 0x12b
-	BTN_DEAD                     = 0x12f
-	BTN_GAMEPAD                  = 0x130
-	BTN_SOUTH                    = 0x130
-	BTN_A                        = BTN_SOUTH
-	BTN_EAST                     = 0x131
-	BTN_B                        = BTN_EAST
-	BTN_C                        = 0x132
-	BTN_NORTH                    = 0x133
-	BTN_X                        = BTN_NORTH
-	BTN_WEST                     = 0x134
-	BTN_Y                        = BTN_WEST
-	BTN_Z                        = 0x135
-	BTN_TL                       = 0x136
-	BTN_TR                       = 0x137
-	BTN_TL2                      = 0x138
-	BTN_TR2                      = 0x139
-	BTN_SELECT                   = 0x13a
-	BTN_START                    = 0x13b
-	BTN_MODE                     = 0x13c
-	BTN_THUMBL                   = 0x13d
-	BTN_THUMBR                   = 0x13e
-	BTN_DIGI                     = 0x140
-	BTN_TOOL_PEN                 = 0x140
-	BTN_TOOL_RUBBER              = 0x141
-	BTN_TOOL_BRUSH               = 0x142
-	BTN_TOOL_PENCIL              = 0x143
-	BTN_TOOL_AIRBRUSH            = 0x144
-	BTN_TOOL_FINGER              = 0x145
-	BTN_TOOL_MOUSE               = 0x146
-	BTN_TOOL_LENS                = 0x147
-	BTN_TOOL_QUINTTAP            = 0x148
-	BTN_STYLUS3                  = 0x149
-	BTN_TOUCH                    = 0x14a
-	BTN_STYLUS                   = 0x14b
-	BTN_STYLUS2                  = 0x14c
-	BTN_TOOL_DOUBLETAP           = 0x14d
-	BTN_TOOL_TRIPLETAP           = 0x14e
-	BTN_TOOL_QUADTAP             = 0x14f
-	BTN_WHEEL                    = 0x150
-	BTN_GEAR_DOWN                = 0x150
-	BTN_GEAR_UP                  = 0x151
-	KEY_OK                       = 0x160
-	KEY_SELECT                   = 0x161
-	KEY_GOTO                     = 0x162
-	KEY_CLEAR                    = 0x163
-	KEY_POWER2                   = 0x164
-	KEY_OPTION                   = 0x165
-	KEY_INFO                     = 0x166
-	KEY_TIME                     = 0x167
-	KEY_VENDOR                   = 0x168
-	KEY_ARCHIVE                  = 0x169
-	KEY_PROGRAM                  = 0x16a
-	KEY_CHANNEL                  = 0x16b
-	KEY_FAVORITES                = 0x16c
-	KEY_EPG                      = 0x16d
-	KEY_PVR                      = 0x16e
-	KEY_MHP                      = 0x16f
-	KEY_LANGUAGE                 = 0x170
-	KEY_TITLE                    = 0x171
-	KEY_SUBTITLE                 = 0x172
-	KEY_ANGLE                    = 0x173
-	KEY_ZOOM                     = 0x174
-	KEY_MODE                     = 0x175
-	KEY_KEYBOARD                 = 0x176
-	KEY_SCREEN                   = 0x177
-	KEY_PC                       = 0x178
-	KEY_TV                       = 0x179
-	KEY_TV2                      = 0x17a
-	KEY_VCR                      = 0x17b
-	KEY_VCR2                     = 0x17c
-	KEY_SAT                      = 0x17d
-	KEY_SAT2                     = 0x17e
-	KEY_CD                       = 0x17f
-	KEY_TAPE                     = 0x180
-	KEY_RADIO                    = 0x181
-	KEY_TUNER                    = 0x182
-	KEY_PLAYER                   = 0x183
-	KEY_TEXT                     = 0x184
-	KEY_DVD                      = 0x185
-	KEY_AUX                      = 0x186
-	KEY_MP3                      = 0x187
-	KEY_AUDIO                    = 0x188
-	KEY_VIDEO                    = 0x189
-	KEY_DIRECTORY                = 0x18a
-	KEY_LIST                     = 0x18b
-	KEY_MEMO                     = 0x18c
-	KEY_CALENDAR                 = 0x18d
-	KEY_RED                      = 0x18e
-	KEY_GREEN                    = 0x18f
-	KEY_YELLOW                   = 0x190
-	KEY_BLUE                     = 0x191
-	KEY_CHANNELUP                = 0x192
-	KEY_CHANNELDOWN              = 0x193
-	KEY_FIRST                    = 0x194
-	KEY_LAST                     = 0x195
-	KEY_AB                       = 0x196
-	KEY_NEXT                     = 0x197
-	KEY_RESTART                  = 0x198
-	KEY_SLOW                     = 0x199
-	KEY_SHUFFLE                  = 0x19a
-	KEY_BREAK                    = 0x19b
-	KEY_PREVIOUS                 = 0x19c
-	KEY_DIGITS                   = 0x19d
-	KEY_TEEN                     = 0x19e
-	KEY_TWEN                     = 0x19f
-	KEY_VIDEOPHONE               = 0x1a0
-	KEY_GAMES                    = 0x1a1
-	KEY_ZOOMIN                   = 0x1a2
-	KEY_ZOOMOUT                  = 0x1a3
-	KEY_ZOOMRESET                = 0x1a4
-	KEY_WORDPROCESSOR            = 0x1a5
-	KEY_EDITOR                   = 0x1a6
-	KEY_SPREADSHEET              = 0x1a7
-	KEY_GRAPHICSEDITOR           = 0x1a8
-	KEY_PRESENTATION             = 0x1a9
-	KEY_DATABASE                 = 0x1aa
-	KEY_NEWS                     = 0x1ab
-	KEY_VOICEMAIL                = 0x1ac
-	KEY_ADDRESSBOOK              = 0x1ad
-	KEY_MESSENGER                = 0x1ae
-	KEY_DISPLAYTOGGLE            = 0x1af
-	KEY_BRIGHTNESS_TOGGLE        = KEY_DISPLAYTOGGLE
-	KEY_SPELLCHECK               = 0x1b0
-	KEY_LOGOFF                   = 0x1b1
-	KEY_DOLLAR                   = 0x1b2
-	KEY_EURO                     = 0x1b3
-	KEY_FRAMEBACK                = 0x1b4
-	KEY_FRAMEFORWARD             = 0x1b5
-	KEY_CONTEXT_MENU             = 0x1b6
-	KEY_MEDIA_REPEAT             = 0x1b7
-	KEY_10CHANNELSUP             = 0x1b8
-	KEY_10CHANNELSDOWN           = 0x1b9
-	KEY_IMAGES                   = 0x1ba
-	KEY_DEL_EOL                  = 0x1c0
-	KEY_DEL_EOS                  = 0x1c1
-	KEY_INS_LINE                 = 0x1c2
-	KEY_DEL_LINE                 = 0x1c3
-	KEY_FN                       = 0x1d0
-	KEY_FN_ESC                   = 0x1d1
-	KEY_FN_F1                    = 0x1d2
-	KEY_FN_F2                    = 0x1d3
-	KEY_FN_F3                    = 0x1d4
-	KEY_FN_F4                    = 0x1d5
-	KEY_FN_F5                    = 0x1d6
-	KEY_FN_F6                    = 0x1d7
-	KEY_FN_F7                    = 0x1d8
-	KEY_FN_F8                    = 0x1d9
-	KEY_FN_F9                    = 0x1da
-	KEY_FN_F10                   = 0x1db
-	KEY_FN_F11                   = 0x1dc
-	KEY_FN_F12                   = 0x1dd
-	KEY_FN_1                     = 0x1de
-	KEY_FN_2                     = 0x1df
-	KEY_FN_D                     = 0x1e0
-	KEY_FN_E                     = 0x1e1
-	KEY_FN_F                     = 0x1e2
-	KEY_FN_S                     = 0x1e3
-	KEY_FN_B                     = 0x1e4
-	KEY_BRL_DOT1                 = 0x1f1
-	KEY_BRL_DOT2                 = 0x1f2
-	KEY_BRL_DOT3                 = 0x1f3
-	KEY_BRL_DOT4                 = 0x1f4
-	KEY_BRL_DOT5                 = 0x1f5
-	KEY_BRL_DOT6                 = 0x1f6
-	KEY_BRL_DOT7                 = 0x1f7
-	KEY_BRL_DOT8                 = 0x1f8
-	KEY_BRL_DOT9                 = 0x1f9
-	KEY_BRL_DOT10                = 0x1fa
-	KEY_NUMERIC_0                = 0x200
-	KEY_NUMERIC_1                = 0x201
-	KEY_NUMERIC_2                = 0x202
-	KEY_NUMERIC_3                = 0x203
-	KEY_NUMERIC_4                = 0x204
-	KEY_NUMERIC_5                = 0x205
-	KEY_NUMERIC_6                = 0x206
-	KEY_NUMERIC_7                = 0x207
-	KEY_NUMERIC_8                = 0x208
-	KEY_NUMERIC_9                = 0x209
-	KEY_NUMERIC_STAR             = 0x20a
-	KEY_NUMERIC_POUND            = 0x20b
-	KEY_NUMERIC_A                = 0x20c
-	KEY_NUMERIC_B                = 0x20d
-	KEY_NUMERIC_C                = 0x20e
-	KEY_NUMERIC_D                = 0x20f
-	KEY_CAMERA_FOCUS             = 0x210
-	KEY_WPS_BUTTON               = 0x211
-	KEY_TOUCHPAD_TOGGLE          = 0x212
-	KEY_TOUCHPAD_ON              = 0x213
-	KEY_TOUCHPAD_OFF             = 0x214
-	KEY_CAMERA_ZOOMIN            = 0x215
-	KEY_CAMERA_ZOOMOUT           = 0x216
-	KEY_CAMERA_UP                = 0x217
-	KEY_CAMERA_DOWN              = 0x218
-	KEY_CAMERA_LEFT              = 0x219
-	KEY_CAMERA_RIGHT             = 0x21a
-	KEY_ATTENDANT_ON             = 0x21b
-	KEY_ATTENDANT_OFF            = 0x21c
-	KEY_ATTENDANT_TOGGLE         = 0x21d
-	KEY_LIGHTS_TOGGLE            = 0x21e
-	BTN_DPAD_UP                  = 0x220
-	BTN_DPAD_DOWN                = 0x221
-	BTN_DPAD_LEFT                = 0x222
-	BTN_DPAD_RIGHT               = 0x223
-	KEY_ALS_TOGGLE               = 0x230
-	KEY_BUTTONCONFIG             = 0x240
-	KEY_TASKMANAGER              = 0x241
-	KEY_JOURNAL                  = 0x242
-	KEY_CONTROLPANEL             = 0x243
-	KEY_APPSELECT                = 0x244
-	KEY_SCREENSAVER              = 0x245
-	KEY_VOICECOMMAND             = 0x246
-	KEY_ASSISTANT                = 0x247
-	KEY_BRIGHTNESS_MIN           = 0x250
-	KEY_BRIGHTNESS_MAX           = 0x251
-	KEY_KBDINPUTASSIST_PREV      = 0x260
-	KEY_KBDINPUTASSIST_NEXT      = 0x261
-	KEY_KBDINPUTASSIST_PREVGROUP = 0x262
-	KEY_KBDINPUTASSIST_NEXTGROUP = 0x263
-	KEY_KBDINPUTASSIST_ACCEPT    = 0x264
-	KEY_KBDINPUTASSIST_CANCEL    = 0x265
-	KEY_RIGHT_UP                 = 0x266
-	KEY_RIGHT_DOWN               = 0x267
-	KEY_LEFT_UP                  = 0x268
-	KEY_LEFT_DOWN                = 0x269
-	KEY_ROOT_MENU                = 0x26a
-	KEY_MEDIA_TOP_MENU           = 0x26b
-	KEY_NUMERIC_11               = 0x26c
-	KEY_NUMERIC_12               = 0x26d
-	KEY_AUDIO_DESC               = 0x26e
-	KEY_3D_MODE                  = 0x26f
-	KEY_NEXT_FAVORITE            = 0x270
-	KEY_STOP_RECORD              = 0x271
-	KEY_PAUSE_RECORD             = 0x272
-	KEY_VOD                      = 0x273
-	KEY_UNMUTE                   = 0x274
-	KEY_FASTREVERSE              = 0x275
-	KEY_SLOWREVERSE              = 0x276
-	KEY_DATA                     = 0x277
-	KEY_ONSCREEN_KEYBOARD        = 0x278
-	BTN_TRIGGER_HAPPY            = 0x2c0
-	BTN_TRIGGER_HAPPY1           = 0x2c0
-	BTN_TRIGGER_HAPPY2           = 0x2c1
-	BTN_TRIGGER_HAPPY3           = 0x2c2
-	BTN_TRIGGER_HAPPY4           = 0x2c3
-	BTN_TRIGGER_HAPPY5           = 0x2c4
-	BTN_TRIGGER_HAPPY6           = 0x2c5
-	BTN_TRIGGER_HAPPY7           = 0x2c6
-	BTN_TRIGGER_HAPPY8           = 0x2c7
-	BTN_TRIGGER_HAPPY9           = 0x2c8
-	BTN_TRIGGER_HAPPY10          = 0x2c9
-	BTN_TRIGGER_HAPPY11          = 0x2ca
-	BTN_TRIGGER_HAPPY12          = 0x2cb
-	BTN_TRIGGER_HAPPY13          = 0x2cc
-	BTN_TRIGGER_HAPPY14          = 0x2cd
-	BTN_TRIGGER_HAPPY15          = 0x2ce
-	BTN_TRIGGER_HAPPY16          = 0x2cf
-	BTN_TRIGGER_HAPPY17          = 0x2d0
-	BTN_TRIGGER_HAPPY18          = 0x2d1
-	BTN_TRIGGER_HAPPY19          = 0x2d2
-	BTN_TRIGGER_HAPPY20          = 0x2d3
-	BTN_TRIGGER_HAPPY21          = 0x2d4
-	BTN_TRIGGER_HAPPY22          = 0x2d5
-	BTN_TRIGGER_HAPPY23          = 0x2d6
-	BTN_TRIGGER_HAPPY24          = 0x2d7
-	BTN_TRIGGER_HAPPY25          = 0x2d8
-	BTN_TRIGGER_HAPPY26          = 0x2d9
-	BTN_TRIGGER_HAPPY27          = 0x2da
-	BTN_TRIGGER_HAPPY28          = 0x2db
-	BTN_TRIGGER_HAPPY29          = 0x2dc
-	BTN_TRIGGER_HAPPY30          = 0x2dd
-	BTN_TRIGGER_HAPPY31          = 0x2de
-	BTN_TRIGGER_HAPPY32          = 0x2df
-	BTN_TRIGGER_HAPPY33          = 0x2e0
-	BTN_TRIGGER_HAPPY34          = 0x2e1
-	BTN_TRIGGER_HAPPY35          = 0x2e2
-	BTN_TRIGGER_HAPPY36          = 0x2e3
-	BTN_TRIGGER_HAPPY37          = 0x2e4
-	BTN_TRIGGER_HAPPY38          = 0x2e5
-	BTN_TRIGGER_HAPPY39          = 0x2e6
-	BTN_TRIGGER_HAPPY40          = 0x2e7
-	KEY_MIN_INTERESTING          = KEY_MUTE
-	KEY_MAX                      = 0x2ff
-	REL_X                        = 0x00
-	REL_Y                        = 0x01
-	REL_Z                        = 0x02
-	REL_RX                       = 0x03
-	REL_RY                       = 0x04
-	REL_RZ                       = 0x05
-	REL_HWHEEL                   = 0x06
-	REL_DIAL                     = 0x07
-	REL_WHEEL                    = 0x08
-	REL_MISC                     = 0x09
-	REL_MAX                      = 0x0f
-	ABS_X                        = 0x00
-	ABS_Y                        = 0x01
-	ABS_Z                        = 0x02
-	ABS_RX                       = 0x03
-	ABS_RY                       = 0x04
-	ABS_RZ                       = 0x05
-	ABS_THROTTLE                 = 0x06
-	ABS_RUDDER                   = 0x07
-	ABS_WHEEL                    = 0x08
-	ABS_GAS                      = 0x09
-	ABS_BRAKE                    = 0x0a
-	ABS_HAT0X                    = 0x10
-	ABS_HAT0Y                    = 0x11
-	ABS_HAT1X                    = 0x12
-	ABS_HAT1Y                    = 0x13
-	ABS_HAT2X                    = 0x14
-	ABS_HAT2Y                    = 0x15
-	ABS_HAT3X                    = 0x16
-	ABS_HAT3Y                    = 0x17
-	ABS_PRESSURE                 = 0x18
-	ABS_DISTANCE                 = 0x19
-	ABS_TILT_X                   = 0x1a
-	ABS_TILT_Y                   = 0x1b
-	ABS_TOOL_WIDTH               = 0x1c
-	ABS_VOLUME                   = 0x20
-	ABS_MISC                     = 0x28
-	ABS_RESERVED                 = 0x2e
-	ABS_MT_SLOT                  = 0x2f
-	ABS_MT_TOUCH_MAJOR           = 0x30
-	ABS_MT_TOUCH_MINOR           = 0x31
-	ABS_MT_WIDTH_MAJOR           = 0x32
-	ABS_MT_WIDTH_MINOR           = 0x33
-	ABS_MT_ORIENTATION           = 0x34
-	ABS_MT_POSITION_X            = 0x35
-	ABS_MT_POSITION_Y            = 0x36
-	ABS_MT_TOOL_TYPE             = 0x37
-	ABS_MT_BLOB_ID               = 0x38
-	ABS_MT_TRACKING_ID           = 0x39
-	ABS_MT_PRESSURE              = 0x3a
-	ABS_MT_DISTANCE              = 0x3b
-	ABS_MT_TOOL_X                = 0x3c
-	ABS_MT_TOOL_Y                = 0x3d
-	ABS_MAX                      = 0x3f
-	SW_LID                       = 0x00
-	SW_TABLET_MODE               = 0x01
-	SW_HEADPHONE_INSERT          = 0x02
-	SW_RFKILL_ALL                = 0x03
-	SW_RADIO                     = SW_RFKILL_ALL
-	SW_MICROPHONE_INSERT         = 0x04
-	SW_DOCK                      = 0x05
-	SW_LINEOUT_INSERT            = 0x06
-	SW_JACK_PHYSICAL_INSERT      = 0x07
-	SW_VIDEOOUT_INSERT           = 0x08
-	SW_CAMERA_LENS_COVER         = 0x09
-	SW_KEYPAD_SLIDE              = 0x0a
-	SW_FRONT_PROXIMITY           = 0x0b
-	SW_ROTATE_LOCK               = 0x0c
-	SW_LINEIN_INSERT             = 0x0d
-	SW_MUTE_DEVICE               = 0x0e
-	SW_PEN_INSERTED              = 0x0f
-	SW_MAX                       = 0x0f
-	MSC_SERIAL                   = 0x00
-	MSC_PULSELED                 = 0x01
-	MSC_GESTURE                  = 0x02
-	MSC_RAW                      = 0x03
-	MSC_SCAN                     = 0x04
-	MSC_TIMESTAMP                = 0x05
-	MSC_MAX                      = 0x07
-	LED_NUML                     = 0x00
-	LED_CAPSL                    = 0x01
-	LED_SCROLLL                  = 0x02
-	LED_COMPOSE                  = 0x03
-	LED_KANA                     = 0x04
-	LED_SLEEP                    = 0x05
-	LED_SUSPEND                  = 0x06
-	LED_MUTE                     = 0x07
-	LED_MISC                     = 0x08
-	LED_MAIL                     = 0x09
-	LED_CHARGING                 = 0x0a
-	LED_MAX                      = 0x0f
-	REP_DELAY                    = 0x00
-	REP_PERIOD                   = 0x01
-	REP_MAX                      = 0x01
-	SND_CLICK                    = 0x00
-	SND_BELL                     = 0x01
-	SND_TONE                     = 0x02
-	SND_MAX                      = 0x07
+	EV_VERSION                             = 0x010001
+	ID_BUS                                 = 0
+	ID_VENDOR                              = 1
+	ID_PRODUCT                             = 2
+	ID_VERSION                             = 3
+	BUS_PCI                                = 0x01
+	BUS_ISAPNP                             = 0x02
+	BUS_USB                                = 0x03
+	BUS_HIL                                = 0x04
+	BUS_BLUETOOTH                          = 0x05
+	BUS_VIRTUAL                            = 0x06
+	BUS_ISA                                = 0x10
+	BUS_I8042                              = 0x11
+	BUS_XTKBD                              = 0x12
+	BUS_RS232                              = 0x13
+	BUS_GAMEPORT                           = 0x14
+	BUS_PARPORT                            = 0x15
+	BUS_AMIGA                              = 0x16
+	BUS_ADB                                = 0x17
+	BUS_I2C                                = 0x18
+	BUS_HOST                               = 0x19
+	BUS_GSC                                = 0x1A
+	BUS_ATARI                              = 0x1B
+	BUS_SPI                                = 0x1C
+	BUS_RMI                                = 0x1D
+	BUS_CEC                                = 0x1E
+	BUS_INTEL_ISHTP                        = 0x1F
+	BUS_AMD_SFH                            = 0x20
+	MT_TOOL_FINGER                         = 0x00
+	MT_TOOL_PEN                            = 0x01
+	MT_TOOL_PALM                           = 0x02
+	MT_TOOL_DIAL                           = 0x0a
+	MT_TOOL_MAX                            = 0x0f
+	FF_STATUS_STOPPED                      = 0x00
+	FF_STATUS_PLAYING                      = 0x01
+	FF_STATUS_MAX                          = 0x01
+	FF_RUMBLE                              = 0x50
+	FF_PERIODIC                            = 0x51
+	FF_CONSTANT                            = 0x52
+	FF_SPRING                              = 0x53
+	FF_FRICTION                            = 0x54
+	FF_DAMPER                              = 0x55
+	FF_INERTIA                             = 0x56
+	FF_RAMP                                = 0x57
+	FF_EFFECT_MIN                          = FF_RUMBLE
+	FF_EFFECT_MAX                          = FF_RAMP
+	FF_SQUARE                              = 0x58
+	FF_TRIANGLE                            = 0x59
+	FF_SINE                                = 0x5a
+	FF_SAW_UP                              = 0x5b
+	FF_SAW_DOWN                            = 0x5c
+	FF_CUSTOM                              = 0x5d
+	FF_WAVEFORM_MIN                        = FF_SQUARE
+	FF_WAVEFORM_MAX                        = FF_CUSTOM
+	FF_GAIN                                = 0x60
+	FF_AUTOCENTER                          = 0x61
+	FF_MAX_EFFECTS                         = FF_GAIN
+	FF_MAX                                 = 0x7f
+	FF_CNT                                 = FF_MAX + 1
+	INPUT_PROP_POINTER                     = 0x00
+	INPUT_PROP_DIRECT                      = 0x01
+	INPUT_PROP_BUTTONPAD                   = 0x02
+	INPUT_PROP_SEMI_MT                     = 0x03
+	INPUT_PROP_TOPBUTTONPAD                = 0x04
+	INPUT_PROP_POINTING_STICK              = 0x05
+	INPUT_PROP_ACCELEROMETER               = 0x06
+	INPUT_PROP_MAX                         = 0x1f
+	INPUT_PROP_CNT                         = INPUT_PROP_MAX + 1
+	EV_SYN                       EventType = 0x00
+	EV_KEY                       EventType = 0x01
+	EV_REL                       EventType = 0x02
+	EV_ABS                       EventType = 0x03
+	EV_MSC                       EventType = 0x04
+	EV_SW                        EventType = 0x05
+	EV_LED                       EventType = 0x11
+	EV_SND                       EventType = 0x12
+	EV_REP                       EventType = 0x14
+	EV_FF                        EventType = 0x15
+	EV_PWR                       EventType = 0x16
+	EV_FF_STATUS                 EventType = 0x17
+	EV_MAX                       EventType = 0x1f
+	EV_CNT                       EventType = EV_MAX + 1
+	SYN_REPORT                             = 0
+	SYN_CONFIG                             = 1
+	SYN_MT_REPORT                          = 2
+	SYN_DROPPED                            = 3
+	SYN_MAX                                = 0xf
+	SYN_CNT                                = SYN_MAX + 1
+	KEY_RESERVED                           = 0
+	KEY_ESC                                = 1
+	KEY_1                                  = 2
+	KEY_2                                  = 3
+	KEY_3                                  = 4
+	KEY_4                                  = 5
+	KEY_5                                  = 6
+	KEY_6                                  = 7
+	KEY_7                                  = 8
+	KEY_8                                  = 9
+	KEY_9                                  = 10
+	KEY_0                                  = 11
+	KEY_MINUS                              = 12
+	KEY_EQUAL                              = 13
+	KEY_BACKSPACE                          = 14
+	KEY_TAB                                = 15
+	KEY_Q                                  = 16
+	KEY_W                                  = 17
+	KEY_E                                  = 18
+	KEY_R                                  = 19
+	KEY_T                                  = 20
+	KEY_Y                                  = 21
+	KEY_U                                  = 22
+	KEY_I                                  = 23
+	KEY_O                                  = 24
+	KEY_P                                  = 25
+	KEY_LEFTBRACE                          = 26
+	KEY_RIGHTBRACE                         = 27
+	KEY_ENTER                              = 28
+	KEY_LEFTCTRL                           = 29
+	KEY_A                                  = 30
+	KEY_S                                  = 31
+	KEY_D                                  = 32
+	KEY_F                                  = 33
+	KEY_G                                  = 34
+	KEY_H                                  = 35
+	KEY_J                                  = 36
+	KEY_K                                  = 37
+	KEY_L                                  = 38
+	KEY_SEMICOLON                          = 39
+	KEY_APOSTROPHE                         = 40
+	KEY_GRAVE                              = 41
+	KEY_LEFTSHIFT                          = 42
+	KEY_BACKSLASH                          = 43
+	KEY_Z                                  = 44
+	KEY_X                                  = 45
+	KEY_C                                  = 46
+	KEY_V                                  = 47
+	KEY_B                                  = 48
+	KEY_N                                  = 49
+	KEY_M                                  = 50
+	KEY_COMMA                              = 51
+	KEY_DOT                                = 52
+	KEY_SLASH                              = 53
+	KEY_RIGHTSHIFT                         = 54
+	KEY_KPASTERISK                         = 55
+	KEY_LEFTALT                            = 56
+	KEY_SPACE                              = 57
+	KEY_CAPSLOCK                           = 58
+	KEY_F1                                 = 59
+	KEY_F2                                 = 60
+	KEY_F3                                 = 61
+	KEY_F4                                 = 62
+	KEY_F5                                 = 63
+	KEY_F6                                 = 64
+	KEY_F7                                 = 65
+	KEY_F8                                 = 66
+	KEY_F9                                 = 67
+	KEY_F10                                = 68
+	KEY_NUMLOCK                            = 69
+	KEY_SCROLLLOCK                         = 70
+	KEY_KP7                                = 71
+	KEY_KP8                                = 72
+	KEY_KP9                                = 73
+	KEY_KPMINUS                            = 74
+	KEY_KP4                                = 75
+	KEY_KP5                                = 76
+	KEY_KP6                                = 77
+	KEY_KPPLUS                             = 78
+	KEY_KP1                                = 79
+	KEY_KP2                                = 80
+	KEY_KP3                                = 81
+	KEY_KP0                                = 82
+	KEY_KPDOT                              = 83
+	KEY_ZENKAKUHANKAKU                     = 85
+	KEY_102ND                              = 86
+	KEY_F11                                = 87
+	KEY_F12                                = 88
+	KEY_RO                                 = 89
+	KEY_KATAKANA                           = 90
+	KEY_HIRAGANA                           = 91
+	KEY_HENKAN                             = 92
+	KEY_KATAKANAHIRAGANA                   = 93
+	KEY_MUHENKAN                           = 94
+	KEY_KPJPCOMMA                          = 95
+	KEY_KPENTER                            = 96
+	KEY_RIGHTCTRL                          = 97
+	KEY_KPSLASH                            = 98
+	KEY_SYSRQ                              = 99
+	KEY_RIGHTALT                           = 100
+	KEY_LINEFEED                           = 101
+	KEY_HOME                               = 102
+	KEY_UP                                 = 103
+	KEY_PAGEUP                             = 104
+	KEY_LEFT                               = 105
+	KEY_RIGHT                              = 106
+	KEY_END                                = 107
+	KEY_DOWN                               = 108
+	KEY_PAGEDOWN                           = 109
+	KEY_INSERT                             = 110
+	KEY_DELETE                             = 111
+	KEY_MACRO                              = 112
+	KEY_MUTE                               = 113
+	KEY_VOLUMEDOWN                         = 114
+	KEY_VOLUMEUP                           = 115
+	KEY_POWER                              = 116
+	KEY_KPEQUAL                            = 117
+	KEY_KPPLUSMINUS                        = 118
+	KEY_PAUSE                              = 119
+	KEY_SCALE                              = 120
+	KEY_KPCOMMA                            = 121
+	KEY_HANGEUL                            = 122
+	KEY_HANGUEL                            = KEY_HANGEUL
+	KEY_HANJA                              = 123
+	KEY_YEN                                = 124
+	KEY_LEFTMETA                           = 125
+	KEY_RIGHTMETA                          = 126
+	KEY_COMPOSE                            = 127
+	KEY_STOP                               = 128
+	KEY_AGAIN                              = 129
+	KEY_PROPS                              = 130
+	KEY_UNDO                               = 131
+	KEY_FRONT                              = 132
+	KEY_COPY                               = 133
+	KEY_OPEN                               = 134
+	KEY_PASTE                              = 135
+	KEY_FIND                               = 136
+	KEY_CUT                                = 137
+	KEY_HELP                               = 138
+	KEY_MENU                               = 139
+	KEY_CALC                               = 140
+	KEY_SETUP                              = 141
+	KEY_SLEEP                              = 142
+	KEY_WAKEUP                             = 143
+	KEY_FILE                               = 144
+	KEY_SENDFILE                           = 145
+	KEY_DELETEFILE                         = 146
+	KEY_XFER                               = 147
+	KEY_PROG1                              = 148
+	KEY_PROG2                              = 149
+	KEY_WWW                                = 150
+	KEY_MSDOS                              = 151
+	KEY_COFFEE                             = 152
+	KEY_SCREENLOCK                         = KEY_COFFEE
+	KEY_ROTATE_DISPLAY                     = 153
+	KEY_DIRECTION                          = KEY_ROTATE_DISPLAY
+	KEY_CYCLEWINDOWS                       = 154
+	KEY_MAIL                               = 155
+	KEY_BOOKMARKS                          = 156
+	KEY_COMPUTER                           = 157
+	KEY_BACK                               = 158
+	KEY_FORWARD                            = 159
+	KEY_CLOSECD                            = 160
+	KEY_EJECTCD                            = 161
+	KEY_EJECTCLOSECD                       = 162
+	KEY_NEXTSONG                           = 163
+	KEY_PLAYPAUSE                          = 164
+	KEY_PREVIOUSSONG                       = 165
+	KEY_STOPCD                             = 166
+	KEY_RECORD                             = 167
+	KEY_REWIND                             = 168
+	KEY_PHONE                              = 169
+	KEY_ISO                                = 170
+	KEY_CONFIG                             = 171
+	KEY_HOMEPAGE                           = 172
+	KEY_REFRESH                            = 173
+	KEY_EXIT                               = 174
+	KEY_MOVE                               = 175
+	KEY_EDIT                               = 176
+	KEY_SCROLLUP                           = 177
+	KEY_SCROLLDOWN                         = 178
+	KEY_KPLEFTPAREN                        = 179
+	KEY_KPRIGHTPAREN                       = 180
+	KEY_NEW                                = 181
+	KEY_REDO                               = 182
+	KEY_F13                                = 183
+	KEY_F14                                = 184
+	KEY_F15                                = 185
+	KEY_F16                                = 186
+	KEY_F17                                = 187
+	KEY_F18                                = 188
+	KEY_F19                                = 189
+	KEY_F20                                = 190
+	KEY_F21                                = 191
+	KEY_F22                                = 192
+	KEY_F23                                = 193
+	KEY_F24                                = 194
+	KEY_PLAYCD                             = 200
+	KEY_PAUSECD                            = 201
+	KEY_PROG3                              = 202
+	KEY_PROG4                              = 203
+	KEY_ALL_APPLICATIONS                   = 204
+	KEY_DASHBOARD                          = KEY_ALL_APPLICATIONS
+	KEY_SUSPEND                            = 205
+	KEY_CLOSE                              = 206
+	KEY_PLAY                               = 207
+	KEY_FASTFORWARD                        = 208
+	KEY_BASSBOOST                          = 209
+	KEY_PRINT                              = 210
+	KEY_HP                                 = 211
+	KEY_CAMERA                             = 212
+	KEY_SOUND                              = 213
+	KEY_QUESTION                           = 214
+	KEY_EMAIL                              = 215
+	KEY_CHAT                               = 216
+	KEY_SEARCH                             = 217
+	KEY_CONNECT                            = 218
+	KEY_FINANCE                            = 219
+	KEY_SPORT                              = 220
+	KEY_SHOP                               = 221
+	KEY_ALTERASE                           = 222
+	KEY_CANCEL                             = 223
+	KEY_BRIGHTNESSDOWN                     = 224
+	KEY_BRIGHTNESSUP                       = 225
+	KEY_MEDIA                              = 226
+	KEY_SWITCHVIDEOMODE                    = 227
+	KEY_KBDILLUMTOGGLE                     = 228
+	KEY_KBDILLUMDOWN                       = 229
+	KEY_KBDILLUMUP                         = 230
+	KEY_SEND                               = 231
+	KEY_REPLY                              = 232
+	KEY_FORWARDMAIL                        = 233
+	KEY_SAVE                               = 234
+	KEY_DOCUMENTS                          = 235
+	KEY_BATTERY                            = 236
+	KEY_BLUETOOTH                          = 237
+	KEY_WLAN                               = 238
+	KEY_UWB                                = 239
+	KEY_UNKNOWN                            = 240
+	KEY_VIDEO_NEXT                         = 241
+	KEY_VIDEO_PREV                         = 242
+	KEY_BRIGHTNESS_CYCLE                   = 243
+	KEY_BRIGHTNESS_AUTO                    = 244
+	KEY_BRIGHTNESS_ZERO                    = KEY_BRIGHTNESS_AUTO
+	KEY_DISPLAY_OFF                        = 245
+	KEY_WWAN                               = 246
+	KEY_WIMAX                              = KEY_WWAN
+	KEY_RFKILL                             = 247
+	KEY_MICMUTE                            = 248
+	BTN_MISC                               = 0x100
+	BTN_0                                  = 0x100
+	BTN_1                                  = 0x101
+	BTN_2                                  = 0x102
+	BTN_3                                  = 0x103
+	BTN_4                                  = 0x104
+	BTN_5                                  = 0x105
+	BTN_6                                  = 0x106
+	BTN_7                                  = 0x107
+	BTN_8                                  = 0x108
+	BTN_9                                  = 0x109
+	BTN_MOUSE                              = 0x110
+	BTN_LEFT                               = 0x110
+	BTN_RIGHT                              = 0x111
+	BTN_MIDDLE                             = 0x112
+	BTN_SIDE                               = 0x113
+	BTN_EXTRA                              = 0x114
+	BTN_FORWARD                            = 0x115
+	BTN_BACK                               = 0x116
+	BTN_TASK                               = 0x117
+	BTN_JOYSTICK                           = 0x120
+	BTN_TRIGGER                            = 0x120
+	BTN_THUMB                              = 0x121
+	BTN_THUMB2                             = 0x122
+	BTN_TOP                                = 0x123
+	BTN_TOP2                               = 0x124
+	BTN_PINKIE                             = 0x125
+	BTN_BASE                               = 0x126
+	BTN_BASE2                              = 0x127
+	BTN_BASE3                              = 0x128
+	BTN_BASE4                              = 0x129
+	BTN_BASE5                              = 0x12a
+	BTN_BASE6                              = 0x12b
+	BTN_DEAD                               = 0x12f
+	BTN_GAMEPAD                            = 0x130
+	BTN_SOUTH                              = 0x130
+	BTN_A                                  = BTN_SOUTH
+	BTN_EAST                               = 0x131
+	BTN_B                                  = BTN_EAST
+	BTN_C                                  = 0x132
+	BTN_NORTH                              = 0x133
+	BTN_X                                  = BTN_NORTH
+	BTN_WEST                               = 0x134
+	BTN_Y                                  = BTN_WEST
+	BTN_Z                                  = 0x135
+	BTN_TL                                 = 0x136
+	BTN_TR                                 = 0x137
+	BTN_TL2                                = 0x138
+	BTN_TR2                                = 0x139
+	BTN_SELECT                             = 0x13a
+	BTN_START                              = 0x13b
+	BTN_MODE                               = 0x13c
+	BTN_THUMBL                             = 0x13d
+	BTN_THUMBR                             = 0x13e
+	BTN_DIGI                               = 0x140
+	BTN_TOOL_PEN                           = 0x140
+	BTN_TOOL_RUBBER                        = 0x141
+	BTN_TOOL_BRUSH                         = 0x142
+	BTN_TOOL_PENCIL                        = 0x143
+	BTN_TOOL_AIRBRUSH                      = 0x144
+	BTN_TOOL_FINGER                        = 0x145
+	BTN_TOOL_MOUSE                         = 0x146
+	BTN_TOOL_LENS                          = 0x147
+	BTN_TOOL_QUINTTAP                      = 0x148
+	BTN_STYLUS3                            = 0x149
+	BTN_TOUCH                              = 0x14a
+	BTN_STYLUS                             = 0x14b
+	BTN_STYLUS2                            = 0x14c
+	BTN_TOOL_DOUBLETAP                     = 0x14d
+	BTN_TOOL_TRIPLETAP                     = 0x14e
+	BTN_TOOL_QUADTAP                       = 0x14f
+	BTN_WHEEL                              = 0x150
+	BTN_GEAR_DOWN                          = 0x150
+	BTN_GEAR_UP                            = 0x151
+	KEY_OK                                 = 0x160
+	KEY_SELECT                             = 0x161
+	KEY_GOTO                               = 0x162
+	KEY_CLEAR                              = 0x163
+	KEY_POWER2                             = 0x164
+	KEY_OPTION                             = 0x165
+	KEY_INFO                               = 0x166
+	KEY_TIME                               = 0x167
+	KEY_VENDOR                             = 0x168
+	KEY_ARCHIVE                            = 0x169
+	KEY_PROGRAM                            = 0x16a
+	KEY_CHANNEL                            = 0x16b
+	KEY_FAVORITES                          = 0x16c
+	KEY_EPG                                = 0x16d
+	KEY_PVR                                = 0x16e
+	KEY_MHP                                = 0x16f
+	KEY_LANGUAGE                           = 0x170
+	KEY_TITLE                              = 0x171
+	KEY_SUBTITLE                           = 0x172
+	KEY_ANGLE                              = 0x173
+	KEY_FULL_SCREEN                        = 0x174
+	KEY_ZOOM                               = KEY_FULL_SCREEN
+	KEY_MODE                               = 0x175
+	KEY_KEYBOARD                           = 0x176
+	KEY_ASPECT_RATIO                       = 0x177
+	KEY_SCREEN                             = KEY_ASPECT_RATIO
+	KEY_PC                                 = 0x178
+	KEY_TV                                 = 0x179
+	KEY_TV2                                = 0x17a
+	KEY_VCR                                = 0x17b
+	KEY_VCR2                               = 0x17c
+	KEY_SAT                                = 0x17d
+	KEY_SAT2                               = 0x17e
+	KEY_CD                                 = 0x17f
+	KEY_TAPE                               = 0x180
+	KEY_RADIO                              = 0x181
+	KEY_TUNER                              = 0x182
+	KEY_PLAYER                             = 0x183
+	KEY_TEXT                               = 0x184
+	KEY_DVD                                = 0x185
+	KEY_AUX                                = 0x186
+	KEY_MP3                                = 0x187
+	KEY_AUDIO                              = 0x188
+	KEY_VIDEO                              = 0x189
+	KEY_DIRECTORY                          = 0x18a
+	KEY_LIST                               = 0x18b
+	KEY_MEMO                               = 0x18c
+	KEY_CALENDAR                           = 0x18d
+	KEY_RED                                = 0x18e
+	KEY_GREEN                              = 0x18f
+	KEY_YELLOW                             = 0x190
+	KEY_BLUE                               = 0x191
+	KEY_CHANNELUP                          = 0x192
+	KEY_CHANNELDOWN                        = 0x193
+	KEY_FIRST                              = 0x194
+	KEY_LAST                               = 0x195
+	KEY_AB                                 = 0x196
+	KEY_NEXT                               = 0x197
+	KEY_RESTART                            = 0x198
+	KEY_SLOW                               = 0x199
+	KEY_SHUFFLE                            = 0x19a
+	KEY_BREAK                              = 0x19b
+	KEY_PREVIOUS                           = 0x19c
+	KEY_DIGITS                             = 0x19d
+	KEY_TEEN                               = 0x19e
+	KEY_TWEN                               = 0x19f
+	KEY_VIDEOPHONE                         = 0x1a0
+	KEY_GAMES                              = 0x1a1
+	KEY_ZOOMIN                             = 0x1a2
+	KEY_ZOOMOUT                            = 0x1a3
+	KEY_ZOOMRESET                          = 0x1a4
+	KEY_WORDPROCESSOR                      = 0x1a5
+	KEY_EDITOR                             = 0x1a6
+	KEY_SPREADSHEET                        = 0x1a7
+	KEY_GRAPHICSEDITOR                     = 0x1a8
+	KEY_PRESENTATION                       = 0x1a9
+	KEY_DATABASE                           = 0x1aa
+	KEY_NEWS                               = 0x1ab
+	KEY_VOICEMAIL                          = 0x1ac
+	KEY_ADDRESSBOOK                        = 0x1ad
+	KEY_MESSENGER                          = 0x1ae
+	KEY_DISPLAYTOGGLE                      = 0x1af
+	KEY_BRIGHTNESS_TOGGLE                  = KEY_DISPLAYTOGGLE
+	KEY_SPELLCHECK                         = 0x1b0
+	KEY_LOGOFF                             = 0x1b1
+	KEY_DOLLAR                             = 0x1b2
+	KEY_EURO                               = 0x1b3
+	KEY_FRAMEBACK                          = 0x1b4
+	KEY_FRAMEFORWARD                       = 0x1b5
+	KEY_CONTEXT_MENU                       = 0x1b6
+	KEY_MEDIA_REPEAT                       = 0x1b7
+	KEY_10CHANNELSUP                       = 0x1b8
+	KEY_10CHANNELSDOWN                     = 0x1b9
+	KEY_IMAGES                             = 0x1ba
+	KEY_NOTIFICATION_CENTER                = 0x1bc
+	KEY_PICKUP_PHONE                       = 0x1bd
+	KEY_HANGUP_PHONE                       = 0x1be
+	KEY_LINK_PHONE                         = 0x1bf
+	KEY_DEL_EOL                            = 0x1c0
+	KEY_DEL_EOS                            = 0x1c1
+	KEY_INS_LINE                           = 0x1c2
+	KEY_DEL_LINE                           = 0x1c3
+	KEY_FN                                 = 0x1d0
+	KEY_FN_ESC                             = 0x1d1
+	KEY_FN_F1                              = 0x1d2
+	KEY_FN_F2                              = 0x1d3
+	KEY_FN_F3                              = 0x1d4
+	KEY_FN_F4                              = 0x1d5
+	KEY_FN_F5                              = 0x1d6
+	KEY_FN_F6                              = 0x1d7
+	KEY_FN_F7                              = 0x1d8
+	KEY_FN_F8                              = 0x1d9
+	KEY_FN_F9                              = 0x1da
+	KEY_FN_F10                             = 0x1db
+	KEY_FN_F11                             = 0x1dc
+	KEY_FN_F12                             = 0x1dd
+	KEY_FN_1                               = 0x1de
+	KEY_FN_2                               = 0x1df
+	KEY_FN_D                               = 0x1e0
+	KEY_FN_E                               = 0x1e1
+	KEY_FN_F                               = 0x1e2
+	KEY_FN_S                               = 0x1e3
+	KEY_FN_B                               = 0x1e4
+	KEY_FN_RIGHT_SHIFT                     = 0x1e5
+	KEY_BRL_DOT1                           = 0x1f1
+	KEY_BRL_DOT2                           = 0x1f2
+	KEY_BRL_DOT3                           = 0x1f3
+	KEY_BRL_DOT4                           = 0x1f4
+	KEY_BRL_DOT5                           = 0x1f5
+	KEY_BRL_DOT6                           = 0x1f6
+	KEY_BRL_DOT7                           = 0x1f7
+	KEY_BRL_DOT8                           = 0x1f8
+	KEY_BRL_DOT9                           = 0x1f9
+	KEY_BRL_DOT10                          = 0x1fa
+	KEY_NUMERIC_0                          = 0x200
+	KEY_NUMERIC_1                          = 0x201
+	KEY_NUMERIC_2                          = 0x202
+	KEY_NUMERIC_3                          = 0x203
+	KEY_NUMERIC_4                          = 0x204
+	KEY_NUMERIC_5                          = 0x205
+	KEY_NUMERIC_6                          = 0x206
+	KEY_NUMERIC_7                          = 0x207
+	KEY_NUMERIC_8                          = 0x208
+	KEY_NUMERIC_9                          = 0x209
+	KEY_NUMERIC_STAR                       = 0x20a
+	KEY_NUMERIC_POUND                      = 0x20b
+	KEY_NUMERIC_A                          = 0x20c
+	KEY_NUMERIC_B                          = 0x20d
+	KEY_NUMERIC_C                          = 0x20e
+	KEY_NUMERIC_D                          = 0x20f
+	KEY_CAMERA_FOCUS                       = 0x210
+	KEY_WPS_BUTTON                         = 0x211
+	KEY_TOUCHPAD_TOGGLE                    = 0x212
+	KEY_TOUCHPAD_ON                        = 0x213
+	KEY_TOUCHPAD_OFF                       = 0x214
+	KEY_CAMERA_ZOOMIN                      = 0x215
+	KEY_CAMERA_ZOOMOUT                     = 0x216
+	KEY_CAMERA_UP                          = 0x217
+	KEY_CAMERA_DOWN                        = 0x218
+	KEY_CAMERA_LEFT                        = 0x219
+	KEY_CAMERA_RIGHT                       = 0x21a
+	KEY_ATTENDANT_ON                       = 0x21b
+	KEY_ATTENDANT_OFF                      = 0x21c
+	KEY_ATTENDANT_TOGGLE                   = 0x21d
+	KEY_LIGHTS_TOGGLE                      = 0x21e
+	BTN_DPAD_UP                            = 0x220
+	BTN_DPAD_DOWN                          = 0x221
+	BTN_DPAD_LEFT                          = 0x222
+	BTN_DPAD_RIGHT                         = 0x223
+	KEY_ALS_TOGGLE                         = 0x230
+	KEY_ROTATE_LOCK_TOGGLE                 = 0x231
+	KEY_REFRESH_RATE_TOGGLE                = 0x232
+	KEY_BUTTONCONFIG                       = 0x240
+	KEY_TASKMANAGER                        = 0x241
+	KEY_JOURNAL                            = 0x242
+	KEY_CONTROLPANEL                       = 0x243
+	KEY_APPSELECT                          = 0x244
+	KEY_SCREENSAVER                        = 0x245
+	KEY_VOICECOMMAND                       = 0x246
+	KEY_ASSISTANT                          = 0x247
+	KEY_KBD_LAYOUT_NEXT                    = 0x248
+	KEY_EMOJI_PICKER                       = 0x249
+	KEY_DICTATE                            = 0x24a
+	KEY_BRIGHTNESS_MIN                     = 0x250
+	KEY_BRIGHTNESS_MAX                     = 0x251
+	KEY_KBDINPUTASSIST_PREV                = 0x260
+	KEY_KBDINPUTASSIST_NEXT                = 0x261
+	KEY_KBDINPUTASSIST_PREVGROUP           = 0x262
+	KEY_KBDINPUTASSIST_NEXTGROUP           = 0x263
+	KEY_KBDINPUTASSIST_ACCEPT              = 0x264
+	KEY_KBDINPUTASSIST_CANCEL              = 0x265
+	KEY_RIGHT_UP                           = 0x266
+	KEY_RIGHT_DOWN                         = 0x267
+	KEY_LEFT_UP                            = 0x268
+	KEY_LEFT_DOWN                          = 0x269
+	KEY_ROOT_MENU                          = 0x26a
+	KEY_MEDIA_TOP_MENU                     = 0x26b
+	KEY_NUMERIC_11                         = 0x26c
+	KEY_NUMERIC_12                         = 0x26d
+	KEY_AUDIO_DESC                         = 0x26e
+	KEY_3D_MODE                            = 0x26f
+	KEY_NEXT_FAVORITE                      = 0x270
+	KEY_STOP_RECORD                        = 0x271
+	KEY_PAUSE_RECORD                       = 0x272
+	KEY_VOD                                = 0x273
+	KEY_UNMUTE                             = 0x274
+	KEY_FASTREVERSE                        = 0x275
+	KEY_SLOWREVERSE                        = 0x276
+	KEY_DATA                               = 0x277
+	KEY_ONSCREEN_KEYBOARD                  = 0x278
+	KEY_PRIVACY_SCREEN_TOGGLE              = 0x279
+	KEY_SELECTIVE_SCREENSHOT               = 0x27a
+	KEY_NEXT_ELEMENT                       = 0x27b
+	KEY_PREVIOUS_ELEMENT                   = 0x27c
+	KEY_AUTOPILOT_ENGAGE_TOGGLE            = 0x27d
+	KEY_MARK_WAYPOINT                      = 0x27e
+	KEY_SOS                                = 0x27f
+	KEY_NAV_CHART                          = 0x280
+	KEY_FISHING_CHART                      = 0x281
+	KEY_SINGLE_RANGE_RADAR                 = 0x282
+	KEY_DUAL_RANGE_RADAR                   = 0x283
+	KEY_RADAR_OVERLAY                      = 0x284
+	KEY_TRADITIONAL_SONAR                  = 0x285
+	KEY_CLEARVU_SONAR                      = 0x286
+	KEY_SIDEVU_SONAR                       = 0x287
+	KEY_NAV_INFO                           = 0x288
+	KEY_BRIGHTNESS_MENU                    = 0x289
+	KEY_MACRO1                             = 0x290
+	KEY_MACRO2                             = 0x291
+	KEY_MACRO3                             = 0x292
+	KEY_MACRO4                             = 0x293
+	KEY_MACRO5                             = 0x294
+	KEY_MACRO6                             = 0x295
+	KEY_MACRO7                             = 0x296
+	KEY_MACRO8                             = 0x297
+	KEY_MACRO9                             = 0x298
+	KEY_MACRO10                            = 0x299
+	KEY_MACRO11                            = 0x29a
+	KEY_MACRO12                            = 0x29b
+	KEY_MACRO13                            = 0x29c
+	KEY_MACRO14                            = 0x29d
+	KEY_MACRO15                            = 0x29e
+	KEY_MACRO16                            = 0x29f
+	KEY_MACRO17                            = 0x2a0
+	KEY_MACRO18                            = 0x2a1
+	KEY_MACRO19                            = 0x2a2
+	KEY_MACRO20                            = 0x2a3
+	KEY_MACRO21                            = 0x2a4
+	KEY_MACRO22                            = 0x2a5
+	KEY_MACRO23                            = 0x2a6
+	KEY_MACRO24                            = 0x2a7
+	KEY_MACRO25                            = 0x2a8
+	KEY_MACRO26                            = 0x2a9
+	KEY_MACRO27                            = 0x2aa
+	KEY_MACRO28                            = 0x2ab
+	KEY_MACRO29                            = 0x2ac
+	KEY_MACRO30                            = 0x2ad
+	KEY_MACRO_RECORD_START                 = 0x2b0
+	KEY_MACRO_RECORD_STOP                  = 0x2b1
+	KEY_MACRO_PRESET_CYCLE                 = 0x2b2
+	KEY_MACRO_PRESET1                      = 0x2b3
+	KEY_MACRO_PRESET2                      = 0x2b4
+	KEY_MACRO_PRESET3                      = 0x2b5
+	KEY_KBD_LCD_MENU1                      = 0x2b8
+	KEY_KBD_LCD_MENU2                      = 0x2b9
+	KEY_KBD_LCD_MENU3                      = 0x2ba
+	KEY_KBD_LCD_MENU4                      = 0x2bb
+	KEY_KBD_LCD_MENU5                      = 0x2bc
+	BTN_TRIGGER_HAPPY                      = 0x2c0
+	BTN_TRIGGER_HAPPY1                     = 0x2c0
+	BTN_TRIGGER_HAPPY2                     = 0x2c1
+	BTN_TRIGGER_HAPPY3                     = 0x2c2
+	BTN_TRIGGER_HAPPY4                     = 0x2c3
+	BTN_TRIGGER_HAPPY5                     = 0x2c4
+	BTN_TRIGGER_HAPPY6                     = 0x2c5
+	BTN_TRIGGER_HAPPY7                     = 0x2c6
+	BTN_TRIGGER_HAPPY8                     = 0x2c7
+	BTN_TRIGGER_HAPPY9                     = 0x2c8
+	BTN_TRIGGER_HAPPY10                    = 0x2c9
+	BTN_TRIGGER_HAPPY11                    = 0x2ca
+	BTN_TRIGGER_HAPPY12                    = 0x2cb
+	BTN_TRIGGER_HAPPY13                    = 0x2cc
+	BTN_TRIGGER_HAPPY14                    = 0x2cd
+	BTN_TRIGGER_HAPPY15                    = 0x2ce
+	BTN_TRIGGER_HAPPY16                    = 0x2cf
+	BTN_TRIGGER_HAPPY17                    = 0x2d0
+	BTN_TRIGGER_HAPPY18                    = 0x2d1
+	BTN_TRIGGER_HAPPY19                    = 0x2d2
+	BTN_TRIGGER_HAPPY20                    = 0x2d3
+	BTN_TRIGGER_HAPPY21                    = 0x2d4
+	BTN_TRIGGER_HAPPY22                    = 0x2d5
+	BTN_TRIGGER_HAPPY23                    = 0x2d6
+	BTN_TRIGGER_HAPPY24                    = 0x2d7
+	BTN_TRIGGER_HAPPY25                    = 0x2d8
+	BTN_TRIGGER_HAPPY26                    = 0x2d9
+	BTN_TRIGGER_HAPPY27                    = 0x2da
+	BTN_TRIGGER_HAPPY28                    = 0x2db
+	BTN_TRIGGER_HAPPY29                    = 0x2dc
+	BTN_TRIGGER_HAPPY30                    = 0x2dd
+	BTN_TRIGGER_HAPPY31                    = 0x2de
+	BTN_TRIGGER_HAPPY32                    = 0x2df
+	BTN_TRIGGER_HAPPY33                    = 0x2e0
+	BTN_TRIGGER_HAPPY34                    = 0x2e1
+	BTN_TRIGGER_HAPPY35                    = 0x2e2
+	BTN_TRIGGER_HAPPY36                    = 0x2e3
+	BTN_TRIGGER_HAPPY37                    = 0x2e4
+	BTN_TRIGGER_HAPPY38                    = 0x2e5
+	BTN_TRIGGER_HAPPY39                    = 0x2e6
+	BTN_TRIGGER_HAPPY40                    = 0x2e7
+	KEY_MIN_INTERESTING                    = KEY_MUTE
+	KEY_MAX                                = 0x2ff
+	KEY_CNT                                = KEY_MAX + 1
+	REL_X                                  = 0x00
+	REL_Y                                  = 0x01
+	REL_Z                                  = 0x02
+	REL_RX                                 = 0x03
+	REL_RY                                 = 0x04
+	REL_RZ                                 = 0x05
+	REL_HWHEEL                             = 0x06
+	REL_DIAL                               = 0x07
+	REL_WHEEL                              = 0x08
+	REL_MISC                               = 0x09
+	REL_RESERVED                           = 0x0a
+	REL_WHEEL_HI_RES                       = 0x0b
+	REL_HWHEEL_HI_RES                      = 0x0c
+	REL_MAX                                = 0x0f
+	REL_CNT                                = REL_MAX + 1
+	ABS_X                                  = 0x00
+	ABS_Y                                  = 0x01
+	ABS_Z                                  = 0x02
+	ABS_RX                                 = 0x03
+	ABS_RY                                 = 0x04
+	ABS_RZ                                 = 0x05
+	ABS_THROTTLE                           = 0x06
+	ABS_RUDDER                             = 0x07
+	ABS_WHEEL                              = 0x08
+	ABS_GAS                                = 0x09
+	ABS_BRAKE                              = 0x0a
+	ABS_HAT0X                              = 0x10
+	ABS_HAT0Y                              = 0x11
+	ABS_HAT1X                              = 0x12
+	ABS_HAT1Y                              = 0x13
+	ABS_HAT2X                              = 0x14
+	ABS_HAT2Y                              = 0x15
+	ABS_HAT3X                              = 0x16
+	ABS_HAT3Y                              = 0x17
+	ABS_PRESSURE                           = 0x18
+	ABS_DISTANCE                           = 0x19
+	ABS_TILT_X                             = 0x1a
+	ABS_TILT_Y                             = 0x1b
+	ABS_TOOL_WIDTH                         = 0x1c
+	ABS_VOLUME                             = 0x20
+	ABS_PROFILE                            = 0x21
+	ABS_MISC                               = 0x28
+	ABS_RESERVED                           = 0x2e
+	ABS_MT_SLOT                            = 0x2f
+	ABS_MT_TOUCH_MAJOR                     = 0x30
+	ABS_MT_TOUCH_MINOR                     = 0x31
+	ABS_MT_WIDTH_MAJOR                     = 0x32
+	ABS_MT_WIDTH_MINOR                     = 0x33
+	ABS_MT_ORIENTATION                     = 0x34
+	ABS_MT_POSITION_X                      = 0x35
+	ABS_MT_POSITION_Y                      = 0x36
+	ABS_MT_TOOL_TYPE                       = 0x37
+	ABS_MT_BLOB_ID                         = 0x38
+	ABS_MT_TRACKING_ID                     = 0x39
+	ABS_MT_PRESSURE                        = 0x3a
+	ABS_MT_DISTANCE                        = 0x3b
+	ABS_MT_TOOL_X                          = 0x3c
+	ABS_MT_TOOL_Y                          = 0x3d
+	ABS_MAX                                = 0x3f
+	ABS_CNT                                = ABS_MAX + 1
+	SW_LID                                 = 0x00
+	SW_TABLET_MODE                         = 0x01
+	SW_HEADPHONE_INSERT                    = 0x02
+	SW_RFKILL_ALL                          = 0x03
+	SW_RADIO                               = SW_RFKILL_ALL
+	SW_MICROPHONE_INSERT                   = 0x04
+	SW_DOCK                                = 0x05
+	SW_LINEOUT_INSERT                      = 0x06
+	SW_JACK_PHYSICAL_INSERT                = 0x07
+	SW_VIDEOOUT_INSERT                     = 0x08
+	SW_CAMERA_LENS_COVER                   = 0x09
+	SW_KEYPAD_SLIDE                        = 0x0a
+	SW_FRONT_PROXIMITY                     = 0x0b
+	SW_ROTATE_LOCK                         = 0x0c
+	SW_LINEIN_INSERT                       = 0x0d
+	SW_MUTE_DEVICE                         = 0x0e
+	SW_PEN_INSERTED                        = 0x0f
+	SW_MACHINE_COVER                       = 0x10
+	SW_MAX                                 = 0x10
+	SW_CNT                                 = SW_MAX + 1
+	MSC_SERIAL                             = 0x00
+	MSC_PULSELED                           = 0x01
+	MSC_GESTURE                            = 0x02
+	MSC_RAW                                = 0x03
+	MSC_SCAN                               = 0x04
+	MSC_TIMESTAMP                          = 0x05
+	MSC_MAX                                = 0x07
+	MSC_CNT                                = MSC_MAX + 1
+	LED_NUML                               = 0x00
+	LED_CAPSL                              = 0x01
+	LED_SCROLLL                            = 0x02
+	LED_COMPOSE                            = 0x03
+	LED_KANA                               = 0x04
+	LED_SLEEP                              = 0x05
+	LED_SUSPEND                            = 0x06
+	LED_MUTE                               = 0x07
+	LED_MISC                               = 0x08
+	LED_MAIL                               = 0x09
+	LED_CHARGING                           = 0x0a
+	LED_MAX                                = 0x0f
+	LED_CNT                                = LED_MAX + 1
+	REP_DELAY                              = 0x00
+	REP_PERIOD                             = 0x01
+	REP_MAX                                = 0x01
+	REP_CNT                                = REP_MAX + 1
+	SND_CLICK                              = 0x00
+	SND_BELL                               = 0x01
+	SND_TONE                               = 0x02
+	SND_MAX                                = 0x07
+	SND_CNT                                = SND_MAX + 1
 )
 
-var KEYMap = map[uint16]string{0: "KEY_RESERVED", 1: "KEY_ESC", 2: "KEY_1", 3: "KEY_2", 4: "KEY_3", 5: "KEY_4", 6: "KEY_5", 7: "KEY_6", 8: "KEY_7", 9: "KEY_8", 10: "KEY_9", 11: "KEY_0", 12: "KEY_MINUS", 13: "KEY_EQUAL", 14: "KEY_BACKSPACE", 15: "KEY_TAB", 16: "KEY_Q", 17: "KEY_W", 18: "KEY_E", 19: "KEY_R", 20: "KEY_T", 21: "KEY_Y", 22: "KEY_U", 23: "KEY_I", 24: "KEY_O", 25: "KEY_P", 26: "KEY_LEFTBRACE", 27: "KEY_RIGHTBRACE", 28: "KEY_ENTER", 29: "KEY_LEFTCTRL", 30: "KEY_A", 31: "KEY_S", 32: "KEY_D", 33: "KEY_F", 34: "KEY_G", 35: "KEY_H", 36: "KEY_J", 37: "KEY_K", 38: "KEY_L", 39: "KEY_SEMICOLON", 40: "KEY_APOSTROPHE", 41: "KEY_GRAVE", 42: "KEY_LEFTSHIFT", 43: "KEY_BACKSLASH", 44: "KEY_Z", 45: "KEY_X", 46: "KEY_C", 47: "KEY_V", 48: "KEY_B", 49: "KEY_N", 50: "KEY_M", 51: "KEY_COMMA", 52: "KEY_DOT", 53: "KEY_SLASH", 54: "KEY_RIGHTSHIFT", 55: "KEY_KPASTERISK", 56: "KEY_LEFTALT", 57: "KEY_SPACE", 58: "KEY_CAPSLOCK", 59: "KEY_F1", 60: "KEY_F2", 61: "KEY_F3", 62: "KEY_F4", 63: "KEY_F5", 64: "KEY_F6", 65: "KEY_F7", 66: "KEY_F8", 67: "KEY_F9", 68: "KEY_F10", 69: "KEY_NUMLOCK", 70: "KEY_SCROLLLOCK", 71: "KEY_KP7", 72: "KEY_KP8", 73: "KEY_KP9", 74: "KEY_KPMINUS", 75: "KEY_KP4", 76: "KEY_KP5", 77: "KEY_KP6", 78: "KEY_KPPLUS", 79: "KEY_KP1", 80: "KEY_KP2", 81: "KEY_KP3", 82: "KEY_KP0", 83: "KEY_KPDOT", 85: "KEY_ZENKAKUHANKAKU", 86: "KEY_102ND", 87: "KEY_F11", 88: "KEY_F12", 89: "KEY_RO", 90: "KEY_KATAKANA", 91: "KEY_HIRAGANA", 92: "KEY_HENKAN", 93: "KEY_KATAKANAHIRAGANA", 94: "KEY_MUHENKAN", 95: "KEY_KPJPCOMMA", 96: "KEY_KPENTER", 97: "KEY_RIGHTCTRL", 98: "KEY_KPSLASH", 99: "KEY_SYSRQ", 100: "KEY_RIGHTALT", 101: "KEY_LINEFEED", 102: "KEY_HOME", 103: "KEY_UP", 104: "KEY_PAGEUP", 105: "KEY_LEFT", 106: "KEY_RIGHT", 107: "KEY_END", 108: "KEY_DOWN", 109: "KEY_PAGEDOWN", 110: "KEY_INSERT", 111: "KEY_DELETE", 112: "KEY_MACRO", 113: "KEY_MUTE", 114: "KEY_VOLUMEDOWN", 115: "KEY_VOLUMEUP", 116: "KEY_POWER", 117: "KEY_KPEQUAL", 118: "KEY_KPPLUSMINUS", 119: "KEY_PAUSE", 120: "KEY_SCALE", 121: "KEY_KPCOMMA", 122: "KEY_HANGEUL", 123: "KEY_HANJA", 124: "KEY_YEN", 125: "KEY_LEFTMETA", 126: "KEY_RIGHTMETA", 127: "KEY_COMPOSE", 128: "KEY_STOP", 129: "KEY_AGAIN", 130: "KEY_PROPS", 131: "KEY_UNDO", 132: "KEY_FRONT", 133: "KEY_COPY", 134: "KEY_OPEN", 135: "KEY_PASTE", 136: "KEY_FIND", 137: "KEY_CUT", 138: "KEY_HELP", 139: "KEY_MENU", 140: "KEY_CALC", 141: "KEY_SETUP", 142: "KEY_SLEEP", 143: "KEY_WAKEUP", 144: "KEY_FILE", 145: "KEY_SENDFILE", 146: "KEY_DELETEFILE", 147: "KEY_XFER", 148: "KEY_PROG1", 149: "KEY_PROG2", 150: "KEY_WWW", 151: "KEY_MSDOS", 152: "KEY_COFFEE", 153: "KEY_ROTATE_DISPLAY", 154: "KEY_CYCLEWINDOWS", 155: "KEY_MAIL", 156: "KEY_BOOKMARKS", 157: "KEY_COMPUTER", 158: "KEY_BACK", 159: "KEY_FORWARD", 160: "KEY_CLOSECD", 161: "KEY_EJECTCD", 162: "KEY_EJECTCLOSECD", 163: "KEY_NEXTSONG", 164: "KEY_PLAYPAUSE", 165: "KEY_PREVIOUSSONG", 166: "KEY_STOPCD", 167: "KEY_RECORD", 168: "KEY_REWIND", 169: "KEY_PHONE", 170: "KEY_ISO", 171: "KEY_CONFIG", 172: "KEY_HOMEPAGE", 173: "KEY_REFRESH", 174: "KEY_EXIT", 175: "KEY_MOVE", 176: "KEY_EDIT", 177: "KEY_SCROLLUP", 178: "KEY_SCROLLDOWN", 179: "KEY_KPLEFTPAREN", 180: "KEY_KPRIGHTPAREN", 181: "KEY_NEW", 182: "KEY_REDO", 183: "KEY_F13", 184: "KEY_F14", 185: "KEY_F15", 186: "KEY_F16", 187: "KEY_F17", 188: "KEY_F18", 189: "KEY_F19", 190: "KEY_F20", 191: "KEY_F21", 192: "KEY_F22", 193: "KEY_F23", 194: "KEY_F24", 200: "KEY_PLAYCD", 201: "KEY_PAUSECD", 202: "KEY_PROG3", 203: "KEY_PROG4", 204: "KEY_DASHBOARD", 205: "KEY_SUSPEND", 206: "KEY_CLOSE", 207: "KEY_PLAY", 208: "KEY_FASTFORWARD", 209: "KEY_BASSBOOST", 210: "KEY_PRINT", 211: "KEY_HP", 212: "KEY_CAMERA", 213: "KEY_SOUND", 214: "KEY_QUESTION", 215: "KEY_EMAIL", 216: "KEY_CHAT", 217: "KEY_SEARCH", 218: "KEY_CONNECT", 219: "KEY_FINANCE", 220: "KEY_SPORT", 221: "KEY_SHOP", 222: "KEY_ALTERASE", 223: "KEY_CANCEL", 224: "KEY_BRIGHTNESSDOWN", 225: "KEY_BRIGHTNESSUP", 226: "KEY_MEDIA", 227: "KEY_SWITCHVIDEOMODE", 228: "KEY_KBDILLUMTOGGLE", 229: "KEY_KBDILLUMDOWN", 230: "KEY_KBDILLUMUP", 231: "KEY_SEND", 232: "KEY_REPLY", 233: "KEY_FORWARDMAIL", 234: "KEY_SAVE", 235: "KEY_DOCUMENTS", 236: "KEY_BATTERY", 237: "KEY_BLUETOOTH", 238: "KEY_WLAN", 239: "KEY_UWB", 240: "KEY_UNKNOWN", 241: "KEY_VIDEO_NEXT", 242: "KEY_VIDEO_PREV", 243: "KEY_BRIGHTNESS_CYCLE", 244: "KEY_BRIGHTNESS_AUTO", 245: "KEY_DISPLAY_OFF", 246: "KEY_WWAN", 247: "KEY_RFKILL", 248: "KEY_MICMUTE", 0x160: "KEY_OK", 0x161: "KEY_SELECT", 0x162: "KEY_GOTO", 0x163: "KEY_CLEAR", 0x164: "KEY_POWER2", 0x165: "KEY_OPTION", 0x166: "KEY_INFO", 0x167: "KEY_TIME", 0x168: "KEY_VENDOR", 0x169: "KEY_ARCHIVE", 0x16a: "KEY_PROGRAM", 0x16b: "KEY_CHANNEL", 0x16c: "KEY_FAVORITES", 0x16d: "KEY_EPG", 0x16e: "KEY_PVR", 0x16f: "KEY_MHP", 0x170: "KEY_LANGUAGE", 0x171: "KEY_TITLE", 0x172: "KEY_SUBTITLE", 0x173: "KEY_ANGLE", 0x174: "KEY_ZOOM", 0x175: "KEY_MODE", 0x176: "KEY_KEYBOARD", 0x177: "KEY_SCREEN", 0x178: "KEY_PC", 0x179: "KEY_TV", 0x17a: "KEY_TV2", 0x17b: "KEY_VCR", 0x17c: "KEY_VCR2", 0x17d: "KEY_SAT", 0x17e: "KEY_SAT2", 0x17f: "KEY_CD", 0x180: "KEY_TAPE", 0x181: "KEY_RADIO", 0x182: "KEY_TUNER", 0x183: "KEY_PLAYER", 0x184: "KEY_TEXT", 0x185: "KEY_DVD", 0x186: "KEY_AUX", 0x187: "KEY_MP3", 0x188: "KEY_AUDIO", 0x189: "KEY_VIDEO", 0x18a: "KEY_DIRECTORY", 0x18b: "KEY_LIST", 0x18c: "KEY_MEMO", 0x18d: "KEY_CALENDAR", 0x18e: "KEY_RED", 0x18f: "KEY_GREEN", 0x190: "KEY_YELLOW", 0x191: "KEY_BLUE", 0x192: "KEY_CHANNELUP", 0x193: "KEY_CHANNELDOWN", 0x194: "KEY_FIRST", 0x195: "KEY_LAST", 0x196: "KEY_AB", 0x197: "KEY_NEXT", 0x198: "KEY_RESTART", 0x199: "KEY_SLOW", 0x19a: "KEY_SHUFFLE", 0x19b: "KEY_BREAK", 0x19c: "KEY_PREVIOUS", 0x19d: "KEY_DIGITS", 0x19e: "KEY_TEEN", 0x19f: "KEY_TWEN", 0x1a0: "KEY_VIDEOPHONE", 0x1a1: "KEY_GAMES", 0x1a2: "KEY_ZOOMIN", 0x1a3: "KEY_ZOOMOUT", 0x1a4: "KEY_ZOOMRESET", 0x1a5: "KEY_WORDPROCESSOR", 0x1a6: "KEY_EDITOR", 0x1a7: "KEY_SPREADSHEET", 0x1a8: "KEY_GRAPHICSEDITOR", 0x1a9: "KEY_PRESENTATION", 0x1aa: "KEY_DATABASE", 0x1ab: "KEY_NEWS", 0x1ac: "KEY_VOICEMAIL", 0x1ad: "KEY_ADDRESSBOOK", 0x1ae: "KEY_MESSENGER", 0x1af: "KEY_DISPLAYTOGGLE", 0x1b0: "KEY_SPELLCHECK", 0x1b1: "KEY_LOGOFF", 0x1b2: "KEY_DOLLAR", 0x1b3: "KEY_EURO", 0x1b4: "KEY_FRAMEBACK", 0x1b5: "KEY_FRAMEFORWARD", 0x1b6: "KEY_CONTEXT_MENU", 0x1b7: "KEY_MEDIA_REPEAT", 0x1b8: "KEY_10CHANNELSUP", 0x1b9: "KEY_10CHANNELSDOWN", 0x1ba: "KEY_IMAGES", 0x1c0: "KEY_DEL_EOL", 0x1c1: "KEY_DEL_EOS", 0x1c2: "KEY_INS_LINE", 0x1c3: "KEY_DEL_LINE", 0x1d0: "KEY_FN", 0x1d1: "KEY_FN_ESC", 0x1d2: "KEY_FN_F1", 0x1d3: "KEY_FN_F2", 0x1d4: "KEY_FN_F3", 0x1d5: "KEY_FN_F4", 0x1d6: "KEY_FN_F5", 0x1d7: "KEY_FN_F6", 0x1d8: "KEY_FN_F7", 0x1d9: "KEY_FN_F8", 0x1da: "KEY_FN_F9", 0x1db: "KEY_FN_F10", 0x1dc: "KEY_FN_F11", 0x1dd: "KEY_FN_F12", 0x1de: "KEY_FN_1", 0x1df: "KEY_FN_2", 0x1e0: "KEY_FN_D", 0x1e1: "KEY_FN_E", 0x1e2: "KEY_FN_F", 0x1e3: "KEY_FN_S", 0x1e4: "KEY_FN_B", 0x1f1: "KEY_BRL_DOT1", 0x1f2: "KEY_BRL_DOT2", 0x1f3: "KEY_BRL_DOT3", 0x1f4: "KEY_BRL_DOT4", 0x1f5: "KEY_BRL_DOT5", 0x1f6: "KEY_BRL_DOT6", 0x1f7: "KEY_BRL_DOT7", 0x1f8: "KEY_BRL_DOT8", 0x1f9: "KEY_BRL_DOT9", 0x1fa: "KEY_BRL_DOT10", 0x200: "KEY_NUMERIC_0", 0x201: "KEY_NUMERIC_1", 0x202: "KEY_NUMERIC_2", 0x203: "KEY_NUMERIC_3", 0x204: "KEY_NUMERIC_4", 0x205: "KEY_NUMERIC_5", 0x206: "KEY_NUMERIC_6", 0x207: "KEY_NUMERIC_7", 0x208: "KEY_NUMERIC_8", 0x209: "KEY_NUMERIC_9", 0x20a: "KEY_NUMERIC_STAR", 0x20b: "KEY_NUMERIC_POUND", 0x20c: "KEY_NUMERIC_A", 0x20d: "KEY_NUMERIC_B", 0x20e: "KEY_NUMERIC_C", 0x20f: "KEY_NUMERIC_D", 0x210: "KEY_CAMERA_FOCUS", 0x211: "KEY_WPS_BUTTON", 0x212: "KEY_TOUCHPAD_TOGGLE", 0x213: "KEY_TOUCHPAD_ON", 0x214: "KEY_TOUCHPAD_OFF", 0x215: "KEY_CAMERA_ZOOMIN", 0x216: "KEY_CAMERA_ZOOMOUT", 0x217: "KEY_CAMERA_UP", 0x218: "KEY_CAMERA_DOWN", 0x219: "KEY_CAMERA_LEFT", 0x21a: "KEY_CAMERA_RIGHT", 0x21b: "KEY_ATTENDANT_ON", 0x21c: "KEY_ATTENDANT_OFF", 0x21d: "KEY_ATTENDANT_TOGGLE", 0x21e: "KEY_LIGHTS_TOGGLE", 0x230: "KEY_ALS_TOGGLE", 0x240: "KEY_BUTTONCONFIG", 0x241: "KEY_TASKMANAGER", 0x242: "KEY_JOURNAL", 0x243: "KEY_CONTROLPANEL", 0x244: "KEY_APPSELECT", 0x245: "KEY_SCREENSAVER", 0x246: "KEY_VOICECOMMAND", 0x247: "KEY_ASSISTANT", 0x250: "KEY_BRIGHTNESS_MIN", 0x260: "KEY_KBDINPUTASSIST_PREV", 0x261: "KEY_KBDINPUTASSIST_NEXT", 0x262: "KEY_KBDINPUTASSIST_PREVGROUP", 0x263: "KEY_KBDINPUTASSIST_NEXTGROUP", 0x264: "KEY_KBDINPUTASSIST_ACCEPT", 0x265: "KEY_KBDINPUTASSIST_CANCEL", 0x266: "KEY_RIGHT_UP", 0x267: "KEY_RIGHT_DOWN", 0x268: "KEY_LEFT_UP", 0x269: "KEY_LEFT_DOWN", 0x26a: "KEY_ROOT_MENU", 0x26b: "KEY_MEDIA_TOP_MENU", 0x26c: "KEY_NUMERIC_11", 0x26d: "KEY_NUMERIC_12", 0x26e: "KEY_AUDIO_DESC", 0x26f: "KEY_3D_MODE", 0x270: "KEY_NEXT_FAVORITE", 0x271: "KEY_STOP_RECORD", 0x272: "KEY_PAUSE_RECORD", 0x273: "KEY_VOD", 0x274: "KEY_UNMUTE", 0x275: "KEY_FASTREVERSE", 0x276: "KEY_SLOWREVERSE", 0x277: "KEY_DATA", 0x278: "KEY_ONSCREEN_KEYBOARD"}
-var ABSMap = map[uint16]string{0x00: "ABS_X", 0x01: "ABS_Y", 0x02: "ABS_Z", 0x03: "ABS_RX", 0x04: "ABS_RY", 0x05: "ABS_RZ", 0x06: "ABS_THROTTLE", 0x07: "ABS_RUDDER", 0x08: "ABS_WHEEL", 0x09: "ABS_GAS", 0x0a: "ABS_BRAKE", 0x10: "ABS_HAT0X", 0x11: "ABS_HAT0Y", 0x12: "ABS_HAT1X", 0x13: "ABS_HAT1Y", 0x14: "ABS_HAT2X", 0x15: "ABS_HAT2Y", 0x16: "ABS_HAT3X", 0x17: "ABS_HAT3Y", 0x18: "ABS_PRESSURE", 0x19: "ABS_DISTANCE", 0x1a: "ABS_TILT_X", 0x1b: "ABS_TILT_Y", 0x1c: "ABS_TOOL_WIDTH", 0x20: "ABS_VOLUME", 0x28: "ABS_MISC", 0x2e: "ABS_RESERVED", 0x2f: "ABS_MT_SLOT", 0x30: "ABS_MT_TOUCH_MAJOR", 0x31: "ABS_MT_TOUCH_MINOR", 0x32: "ABS_MT_WIDTH_MAJOR", 0x33: "ABS_MT_WIDTH_MINOR", 0x34: "ABS_MT_ORIENTATION", 0x35: "ABS_MT_POSITION_X", 0x36: "ABS_MT_POSITION_Y", 0x37: "ABS_MT_TOOL_TYPE", 0x38: "ABS_MT_BLOB_ID", 0x39: "ABS_MT_TRACKING_ID", 0x3a: "ABS_MT_PRESSURE", 0x3b: "ABS_MT_DISTANCE", 0x3c: "ABS_MT_TOOL_X", 0x3d: "ABS_MT_TOOL_Y"}
-var RELMap = map[uint16]string{0x00: "REL_X", 0x01: "REL_Y", 0x02: "REL_Z", 0x03: "REL_RX", 0x04: "REL_RY", 0x05: "REL_RZ", 0x06: "REL_HWHEEL", 0x07: "REL_DIAL", 0x08: "REL_WHEEL", 0x09: "REL_MISC"}
-var SWMap = map[uint16]string{0x00: "SW_LID", 0x01: "SW_TABLET_MODE", 0x02: "SW_HEADPHONE_INSERT", 0x03: "SW_RFKILL_ALL", 0x04: "SW_MICROPHONE_INSERT", 0x05: "SW_DOCK", 0x06: "SW_LINEOUT_INSERT", 0x07: "SW_JACK_PHYSICAL_INSERT", 0x08: "SW_VIDEOOUT_INSERT", 0x09: "SW_CAMERA_LENS_COVER", 0x0a: "SW_KEYPAD_SLIDE", 0x0b: "SW_FRONT_PROXIMITY", 0x0c: "SW_ROTATE_LOCK", 0x0d: "SW_LINEIN_INSERT", 0x0e: "SW_MUTE_DEVICE", 0x0f: "SW_PEN_INSERTED"}
+var KEYMap = map[uint16]string{0: "KEY_RESERVED", 1: "KEY_ESC", 2: "KEY_1", 3: "KEY_2", 4: "KEY_3", 5: "KEY_4", 6: "KEY_5", 7: "KEY_6", 8: "KEY_7", 9: "KEY_8", 10: "KEY_9", 11: "KEY_0", 12: "KEY_MINUS", 13: "KEY_EQUAL", 14: "KEY_BACKSPACE", 15: "KEY_TAB", 16: "KEY_Q", 17: "KEY_W", 18: "KEY_E", 19: "KEY_R", 20: "KEY_T", 21: "KEY_Y", 22: "KEY_U", 23: "KEY_I", 24: "KEY_O", 25: "KEY_P", 26: "KEY_LEFTBRACE", 27: "KEY_RIGHTBRACE", 28: "KEY_ENTER", 29: "KEY_LEFTCTRL", 30: "KEY_A", 31: "KEY_S", 32: "KEY_D", 33: "KEY_F", 34: "KEY_G", 35: "KEY_H", 36: "KEY_J", 37: "KEY_K", 38: "KEY_L", 39: "KEY_SEMICOLON", 40: "KEY_APOSTROPHE", 41: "KEY_GRAVE", 42: "KEY_LEFTSHIFT", 43: "KEY_BACKSLASH", 44: "KEY_Z", 45: "KEY_X", 46: "KEY_C", 47: "KEY_V", 48: "KEY_B", 49: "KEY_N", 50: "KEY_M", 51: "KEY_COMMA", 52: "KEY_DOT", 53: "KEY_SLASH", 54: "KEY_RIGHTSHIFT", 55: "KEY_KPASTERISK", 56: "KEY_LEFTALT", 57: "KEY_SPACE", 58: "KEY_CAPSLOCK", 59: "KEY_F1", 60: "KEY_F2", 61: "KEY_F3", 62: "KEY_F4", 63: "KEY_F5", 64: "KEY_F6", 65: "KEY_F7", 66: "KEY_F8", 67: "KEY_F9", 68: "KEY_F10", 69: "KEY_NUMLOCK", 70: "KEY_SCROLLLOCK", 71: "KEY_KP7", 72: "KEY_KP8", 73: "KEY_KP9", 74: "KEY_KPMINUS", 75: "KEY_KP4", 76: "KEY_KP5", 77: "KEY_KP6", 78: "KEY_KPPLUS", 79: "KEY_KP1", 80: "KEY_KP2", 81: "KEY_KP3", 82: "KEY_KP0", 83: "KEY_KPDOT", 85: "KEY_ZENKAKUHANKAKU", 86: "KEY_102ND", 87: "KEY_F11", 88: "KEY_F12", 89: "KEY_RO", 90: "KEY_KATAKANA", 91: "KEY_HIRAGANA", 92: "KEY_HENKAN", 93: "KEY_KATAKANAHIRAGANA", 94: "KEY_MUHENKAN", 95: "KEY_KPJPCOMMA", 96: "KEY_KPENTER", 97: "KEY_RIGHTCTRL", 98: "KEY_KPSLASH", 99: "KEY_SYSRQ", 100: "KEY_RIGHTALT", 101: "KEY_LINEFEED", 102: "KEY_HOME", 103: "KEY_UP", 104: "KEY_PAGEUP", 105: "KEY_LEFT", 106: "KEY_RIGHT", 107: "KEY_END", 108: "KEY_DOWN", 109: "KEY_PAGEDOWN", 110: "KEY_INSERT", 111: "KEY_DELETE", 112: "KEY_MACRO", 113: "KEY_MUTE", 114: "KEY_VOLUMEDOWN", 115: "KEY_VOLUMEUP", 116: "KEY_POWER", 117: "KEY_KPEQUAL", 118: "KEY_KPPLUSMINUS", 119: "KEY_PAUSE", 120: "KEY_SCALE", 121: "KEY_KPCOMMA", 122: "KEY_HANGEUL", 123: "KEY_HANJA", 124: "KEY_YEN", 125: "KEY_LEFTMETA", 126: "KEY_RIGHTMETA", 127: "KEY_COMPOSE", 128: "KEY_STOP", 129: "KEY_AGAIN", 130: "KEY_PROPS", 131: "KEY_UNDO", 132: "KEY_FRONT", 133: "KEY_COPY", 134: "KEY_OPEN", 135: "KEY_PASTE", 136: "KEY_FIND", 137: "KEY_CUT", 138: "KEY_HELP", 139: "KEY_MENU", 140: "KEY_CALC", 141: "KEY_SETUP", 142: "KEY_SLEEP", 143: "KEY_WAKEUP", 144: "KEY_FILE", 145: "KEY_SENDFILE", 146: "KEY_DELETEFILE", 147: "KEY_XFER", 148: "KEY_PROG1", 149: "KEY_PROG2", 150: "KEY_WWW", 151: "KEY_MSDOS", 152: "KEY_COFFEE", 153: "KEY_ROTATE_DISPLAY", 154: "KEY_CYCLEWINDOWS", 155: "KEY_MAIL", 156: "KEY_BOOKMARKS", 157: "KEY_COMPUTER", 158: "KEY_BACK", 159: "KEY_FORWARD", 160: "KEY_CLOSECD", 161: "KEY_EJECTCD", 162: "KEY_EJECTCLOSECD", 163: "KEY_NEXTSONG", 164: "KEY_PLAYPAUSE", 165: "KEY_PREVIOUSSONG", 166: "KEY_STOPCD", 167: "KEY_RECORD", 168: "KEY_REWIND", 169: "KEY_PHONE", 170: "KEY_ISO", 171: "KEY_CONFIG", 172: "KEY_HOMEPAGE", 173: "KEY_REFRESH", 174: "KEY_EXIT", 175: "KEY_MOVE", 176: "KEY_EDIT", 177: "KEY_SCROLLUP", 178: "KEY_SCROLLDOWN", 179: "KEY_KPLEFTPAREN", 180: "KEY_KPRIGHTPAREN", 181: "KEY_NEW", 182: "KEY_REDO", 183: "KEY_F13", 184: "KEY_F14", 185: "KEY_F15", 186: "KEY_F16", 187: "KEY_F17", 188: "KEY_F18", 189: "KEY_F19", 190: "KEY_F20", 191: "KEY_F21", 192: "KEY_F22", 193: "KEY_F23", 194: "KEY_F24", 200: "KEY_PLAYCD", 201: "KEY_PAUSECD", 202: "KEY_PROG3", 203: "KEY_PROG4", 204: "KEY_ALL_APPLICATIONS", 205: "KEY_SUSPEND", 206: "KEY_CLOSE", 207: "KEY_PLAY", 208: "KEY_FASTFORWARD", 209: "KEY_BASSBOOST", 210: "KEY_PRINT", 211: "KEY_HP", 212: "KEY_CAMERA", 213: "KEY_SOUND", 214: "KEY_QUESTION", 215: "KEY_EMAIL", 216: "KEY_CHAT", 217: "KEY_SEARCH", 218: "KEY_CONNECT", 219: "KEY_FINANCE", 220: "KEY_SPORT", 221: "KEY_SHOP", 222: "KEY_ALTERASE", 223: "KEY_CANCEL", 224: "KEY_BRIGHTNESSDOWN", 225: "KEY_BRIGHTNESSUP", 226: "KEY_MEDIA", 227: "KEY_SWITCHVIDEOMODE", 228: "KEY_KBDILLUMTOGGLE", 229: "KEY_KBDILLUMDOWN", 230: "KEY_KBDILLUMUP", 231: "KEY_SEND", 232: "KEY_REPLY", 233: "KEY_FORWARDMAIL", 234: "KEY_SAVE", 235: "KEY_DOCUMENTS", 236: "KEY_BATTERY", 237: "KEY_BLUETOOTH", 238: "KEY_WLAN", 239: "KEY_UWB", 240: "KEY_UNKNOWN", 241: "KEY_VIDEO_NEXT", 242: "KEY_VIDEO_PREV", 243: "KEY_BRIGHTNESS_CYCLE", 244: "KEY_BRIGHTNESS_AUTO", 245: "KEY_DISPLAY_OFF", 246: "KEY_WWAN", 247: "KEY_RFKILL", 248: "KEY_MICMUTE", 0x160: "KEY_OK", 0x161: "KEY_SELECT", 0x162: "KEY_GOTO", 0x163: "KEY_CLEAR", 0x164: "KEY_POWER2", 0x165: "KEY_OPTION", 0x166: "KEY_INFO", 0x167: "KEY_TIME", 0x168: "KEY_VENDOR", 0x169: "KEY_ARCHIVE", 0x16a: "KEY_PROGRAM", 0x16b: "KEY_CHANNEL", 0x16c: "KEY_FAVORITES", 0x16d: "KEY_EPG", 0x16e: "KEY_PVR", 0x16f: "KEY_MHP", 0x170: "KEY_LANGUAGE", 0x171: "KEY_TITLE", 0x172: "KEY_SUBTITLE", 0x173: "KEY_ANGLE", 0x174: "KEY_FULL_SCREEN", 0x175: "KEY_MODE", 0x176: "KEY_KEYBOARD", 0x177: "KEY_ASPECT_RATIO", 0x178: "KEY_PC", 0x179: "KEY_TV", 0x17a: "KEY_TV2", 0x17b: "KEY_VCR", 0x17c: "KEY_VCR2", 0x17d: "KEY_SAT", 0x17e: "KEY_SAT2", 0x17f: "KEY_CD", 0x180: "KEY_TAPE", 0x181: "KEY_RADIO", 0x182: "KEY_TUNER", 0x183: "KEY_PLAYER", 0x184: "KEY_TEXT", 0x185: "KEY_DVD", 0x186: "KEY_AUX", 0x187: "KEY_MP3", 0x188: "KEY_AUDIO", 0x189: "KEY_VIDEO", 0x18a: "KEY_DIRECTORY", 0x18b: "KEY_LIST", 0x18c: "KEY_MEMO", 0x18d: "KEY_CALENDAR", 0x18e: "KEY_RED", 0x18f: "KEY_GREEN", 0x190: "KEY_YELLOW", 0x191: "KEY_BLUE", 0x192: "KEY_CHANNELUP", 0x193: "KEY_CHANNELDOWN", 0x194: "KEY_FIRST", 0x195: "KEY_LAST", 0x196: "KEY_AB", 0x197: "KEY_NEXT", 0x198: "KEY_RESTART", 0x199: "KEY_SLOW", 0x19a: "KEY_SHUFFLE", 0x19b: "KEY_BREAK", 0x19c: "KEY_PREVIOUS", 0x19d: "KEY_DIGITS", 0x19e: "KEY_TEEN", 0x19f: "KEY_TWEN", 0x1a0: "KEY_VIDEOPHONE", 0x1a1: "KEY_GAMES", 0x1a2: "KEY_ZOOMIN", 0x1a3: "KEY_ZOOMOUT", 0x1a4: "KEY_ZOOMRESET", 0x1a5: "KEY_WORDPROCESSOR", 0x1a6: "KEY_EDITOR", 0x1a7: "KEY_SPREADSHEET", 0x1a8: "KEY_GRAPHICSEDITOR", 0x1a9: "KEY_PRESENTATION", 0x1aa: "KEY_DATABASE", 0x1ab: "KEY_NEWS", 0x1ac: "KEY_VOICEMAIL", 0x1ad: "KEY_ADDRESSBOOK", 0x1ae: "KEY_MESSENGER", 0x1af: "KEY_DISPLAYTOGGLE", 0x1b0: "KEY_SPELLCHECK", 0x1b1: "KEY_LOGOFF", 0x1b2: "KEY_DOLLAR", 0x1b3: "KEY_EURO", 0x1b4: "KEY_FRAMEBACK", 0x1b5: "KEY_FRAMEFORWARD", 0x1b6: "KEY_CONTEXT_MENU", 0x1b7: "KEY_MEDIA_REPEAT", 0x1b8: "KEY_10CHANNELSUP", 0x1b9: "KEY_10CHANNELSDOWN", 0x1ba: "KEY_IMAGES", 0x1bc: "KEY_NOTIFICATION_CENTER", 0x1bd: "KEY_PICKUP_PHONE", 0x1be: "KEY_HANGUP_PHONE", 0x1bf: "KEY_LINK_PHONE", 0x1c0: "KEY_DEL_EOL", 0x1c1: "KEY_DEL_EOS", 0x1c2: "KEY_INS_LINE", 0x1c3: "KEY_DEL_LINE", 0x1d0: "KEY_FN", 0x1d1: "KEY_FN_ESC", 0x1d2: "KEY_FN_F1", 0x1d3: "KEY_FN_F2", 0x1d4: "KEY_FN_F3", 0x1d5: "KEY_FN_F4", 0x1d6: "KEY_FN_F5", 0x1d7: "KEY_FN_F6", 0x1d8: "KEY_FN_F7", 0x1d9: "KEY_FN_F8", 0x1da: "KEY_FN_F9", 0x1db: "KEY_FN_F10", 0x1dc: "KEY_FN_F11", 0x1dd: "KEY_FN_F12", 0x1de: "KEY_FN_1", 0x1df: "KEY_FN_2", 0x1e0: "KEY_FN_D", 0x1e1: "KEY_FN_E", 0x1e2: "KEY_FN_F", 0x1e3: "KEY_FN_S", 0x1e4: "KEY_FN_B", 0x1e5: "KEY_FN_RIGHT_SHIFT", 0x1f1: "KEY_BRL_DOT1", 0x1f2: "KEY_BRL_DOT2", 0x1f3: "KEY_BRL_DOT3", 0x1f4: "KEY_BRL_DOT4", 0x1f5: "KEY_BRL_DOT5", 0x1f6: "KEY_BRL_DOT6", 0x1f7: "KEY_BRL_DOT7", 0x1f8: "KEY_BRL_DOT8", 0x1f9: "KEY_BRL_DOT9", 0x1fa: "KEY_BRL_DOT10", 0x200: "KEY_NUMERIC_0", 0x201: "KEY_NUMERIC_1", 0x202: "KEY_NUMERIC_2", 0x203: "KEY_NUMERIC_3", 0x204: "KEY_NUMERIC_4", 0x205: "KEY_NUMERIC_5", 0x206: "KEY_NUMERIC_6", 0x207: "KEY_NUMERIC_7", 0x208: "KEY_NUMERIC_8", 0x209: "KEY_NUMERIC_9", 0x20a: "KEY_NUMERIC_STAR", 0x20b: "KEY_NUMERIC_POUND", 0x20c: "KEY_NUMERIC_A", 0x20d: "KEY_NUMERIC_B", 0x20e: "KEY_NUMERIC_C", 0x20f: "KEY_NUMERIC_D", 0x210: "KEY_CAMERA_FOCUS", 0x211: "KEY_WPS_BUTTON", 0x212: "KEY_TOUCHPAD_TOGGLE", 0x213: "KEY_TOUCHPAD_ON", 0x214: "KEY_TOUCHPAD_OFF", 0x215: "KEY_CAMERA_ZOOMIN", 0x216: "KEY_CAMERA_ZOOMOUT", 0x217: "KEY_CAMERA_UP", 0x218: "KEY_CAMERA_DOWN", 0x219: "KEY_CAMERA_LEFT", 0x21a: "KEY_CAMERA_RIGHT", 0x21b: "KEY_ATTENDANT_ON", 0x21c: "KEY_ATTENDANT_OFF", 0x21d: "KEY_ATTENDANT_TOGGLE", 0x21e: "KEY_LIGHTS_TOGGLE", 0x230: "KEY_ALS_TOGGLE", 0x231: "KEY_ROTATE_LOCK_TOGGLE", 0x232: "KEY_REFRESH_RATE_TOGGLE", 0x240: "KEY_BUTTONCONFIG", 0x241: "KEY_TASKMANAGER", 0x242: "KEY_JOURNAL", 0x243: "KEY_CONTROLPANEL", 0x244: "KEY_APPSELECT", 0x245: "KEY_SCREENSAVER", 0x246: "KEY_VOICECOMMAND", 0x247: "KEY_ASSISTANT", 0x248: "KEY_KBD_LAYOUT_NEXT", 0x249: "KEY_EMOJI_PICKER", 0x24a: "KEY_DICTATE", 0x250: "KEY_BRIGHTNESS_MIN", 0x260: "KEY_KBDINPUTASSIST_PREV", 0x261: "KEY_KBDINPUTASSIST_NEXT", 0x262: "KEY_KBDINPUTASSIST_PREVGROUP", 0x263: "KEY_KBDINPUTASSIST_NEXTGROUP", 0x264: "KEY_KBDINPUTASSIST_ACCEPT", 0x265: "KEY_KBDINPUTASSIST_CANCEL", 0x266: "KEY_RIGHT_UP", 0x267: "KEY_RIGHT_DOWN", 0x268: "KEY_LEFT_UP", 0x269: "KEY_LEFT_DOWN", 0x26a: "KEY_ROOT_MENU", 0x26b: "KEY_MEDIA_TOP_MENU", 0x26c: "KEY_NUMERIC_11", 0x26d: "KEY_NUMERIC_12", 0x26e: "KEY_AUDIO_DESC", 0x26f: "KEY_3D_MODE", 0x270: "KEY_NEXT_FAVORITE", 0x271: "KEY_STOP_RECORD", 0x272: "KEY_PAUSE_RECORD", 0x273: "KEY_VOD", 0x274: "KEY_UNMUTE", 0x275: "KEY_FASTREVERSE", 0x276: "KEY_SLOWREVERSE", 0x277: "KEY_DATA", 0x278: "KEY_ONSCREEN_KEYBOARD", 0x279: "KEY_PRIVACY_SCREEN_TOGGLE", 0x27a: "KEY_SELECTIVE_SCREENSHOT", 0x27b: "KEY_NEXT_ELEMENT", 0x27c: "KEY_PREVIOUS_ELEMENT", 0x27d: "KEY_AUTOPILOT_ENGAGE_TOGGLE", 0x27e: "KEY_MARK_WAYPOINT", 0x27f: "KEY_SOS", 0x280: "KEY_NAV_CHART", 0x281: "KEY_FISHING_CHART", 0x282: "KEY_SINGLE_RANGE_RADAR", 0x283: "KEY_DUAL_RANGE_RADAR", 0x284: "KEY_RADAR_OVERLAY", 0x285: "KEY_TRADITIONAL_SONAR", 0x286: "KEY_CLEARVU_SONAR", 0x287: "KEY_SIDEVU_SONAR", 0x288: "KEY_NAV_INFO", 0x289: "KEY_BRIGHTNESS_MENU", 0x290: "KEY_MACRO1", 0x291: "KEY_MACRO2", 0x292: "KEY_MACRO3", 0x293: "KEY_MACRO4", 0x294: "KEY_MACRO5", 0x295: "KEY_MACRO6", 0x296: "KEY_MACRO7", 0x297: "KEY_MACRO8", 0x298: "KEY_MACRO9", 0x299: "KEY_MACRO10", 0x29a: "KEY_MACRO11", 0x29b: "KEY_MACRO12", 0x29c: "KEY_MACRO13", 0x29d: "KEY_MACRO14", 0x29e: "KEY_MACRO15", 0x29f: "KEY_MACRO16", 0x2a0: "KEY_MACRO17", 0x2a1: "KEY_MACRO18", 0x2a2: "KEY_MACRO19", 0x2a3: "KEY_MACRO20", 0x2a4: "KEY_MACRO21", 0x2a5: "KEY_MACRO22", 0x2a6: "KEY_MACRO23", 0x2a7: "KEY_MACRO24", 0x2a8: "KEY_MACRO25", 0x2a9: "KEY_MACRO26", 0x2aa: "KEY_MACRO27", 0x2ab: "KEY_MACRO28", 0x2ac: "KEY_MACRO29", 0x2ad: "KEY_MACRO30", 0x2b0: "KEY_MACRO_RECORD_START", 0x2b1: "KEY_MACRO_RECORD_STOP", 0x2b2: "KEY_MACRO_PRESET_CYCLE", 0x2b3: "KEY_MACRO_PRESET1", 0x2b4: "KEY_MACRO_PRESET2", 0x2b5: "KEY_MACRO_PRESET3", 0x2b8: "KEY_KBD_LCD_MENU1", 0x2b9: "KEY_KBD_LCD_MENU2", 0x2ba: "KEY_KBD_LCD_MENU3", 0x2bb: "KEY_KBD_LCD_MENU4", 0x2bc: "KEY_KBD_LCD_MENU5"}
+var ABSMap = map[uint16]string{0x00: "ABS_X", 0x01: "ABS_Y", 0x02: "ABS_Z", 0x03: "ABS_RX", 0x04: "ABS_RY", 0x05: "ABS_RZ", 0x06: "ABS_THROTTLE", 0x07: "ABS_RUDDER", 0x08: "ABS_WHEEL", 0x09: "ABS_GAS", 0x0a: "ABS_BRAKE", 0x10: "ABS_HAT0X", 0x11: "ABS_HAT0Y", 0x12: "ABS_HAT1X", 0x13: "ABS_HAT1Y", 0x14: "ABS_HAT2X", 0x15: "ABS_HAT2Y", 0x16: "ABS_HAT3X", 0x17: "ABS_HAT3Y", 0x18: "ABS_PRESSURE", 0x19: "ABS_DISTANCE", 0x1a: "ABS_TILT_X", 0x1b: "ABS_TILT_Y", 0x1c: "ABS_TOOL_WIDTH", 0x20: "ABS_VOLUME", 0x21: "ABS_PROFILE", 0x28: "ABS_MISC", 0x2e: "ABS_RESERVED", 0x2f: "ABS_MT_SLOT", 0x30: "ABS_MT_TOUCH_MAJOR", 0x31: "ABS_MT_TOUCH_MINOR", 0x32: "ABS_MT_WIDTH_MAJOR", 0x33: "ABS_MT_WIDTH_MINOR", 0x34: "ABS_MT_ORIENTATION", 0x35: "ABS_MT_POSITION_X", 0x36: "ABS_MT_POSITION_Y", 0x37: "ABS_MT_TOOL_TYPE", 0x38: "ABS_MT_BLOB_ID", 0x39: "ABS_MT_TRACKING_ID", 0x3a: "ABS_MT_PRESSURE", 0x3b: "ABS_MT_DISTANCE", 0x3c: "ABS_MT_TOOL_X", 0x3d: "ABS_MT_TOOL_Y"}
+var RELMap = map[uint16]string{0x00: "REL_X", 0x01: "REL_Y", 0x02: "REL_Z", 0x03: "REL_RX", 0x04: "REL_RY", 0x05: "REL_RZ", 0x06: "REL_HWHEEL", 0x07: "REL_DIAL", 0x08: "REL_WHEEL", 0x09: "REL_MISC", 0x0a: "REL_RESERVED", 0x0b: "REL_WHEEL_HI_RES", 0x0c: "REL_HWHEEL_HI_RES"}
+var SWMap = map[uint16]string{0x00: "SW_LID", 0x01: "SW_TABLET_MODE", 0x02: "SW_HEADPHONE_INSERT", 0x03: "SW_RFKILL_ALL", 0x04: "SW_MICROPHONE_INSERT", 0x05: "SW_DOCK", 0x06: "SW_LINEOUT_INSERT", 0x07: "SW_JACK_PHYSICAL_INSERT", 0x08: "SW_VIDEOOUT_INSERT", 0x09: "SW_CAMERA_LENS_COVER", 0x0a: "SW_KEYPAD_SLIDE", 0x0b: "SW_FRONT_PROXIMITY", 0x0c: "SW_ROTATE_LOCK", 0x0d: "SW_LINEIN_INSERT", 0x0e: "SW_MUTE_DEVICE", 0x0f: "SW_PEN_INSERTED", 0x10: "SW_MACHINE_COVER"}
 var MSCMap = map[uint16]string{0x00: "MSC_SERIAL", 0x01: "MSC_PULSELED", 0x02: "MSC_GESTURE", 0x03: "MSC_RAW", 0x04: "MSC_SCAN", 0x05: "MSC_TIMESTAMP"}
 var LEDMap = map[uint16]string{0x00: "LED_NUML", 0x01: "LED_CAPSL", 0x02: "LED_SCROLLL", 0x03: "LED_COMPOSE", 0x04: "LED_KANA", 0x05: "LED_SLEEP", 0x06: "LED_SUSPEND", 0x07: "LED_MUTE", 0x08: "LED_MISC", 0x09: "LED_MAIL", 0x0a: "LED_CHARGING"}
 var BTNMap = map[uint16]string{0x100: "BTN_0", 0x101: "BTN_1", 0x102: "BTN_2", 0x103: "BTN_3", 0x104: "BTN_4", 0x105: "BTN_5", 0x106: "BTN_6", 0x107: "BTN_7", 0x108: "BTN_8", 0x109: "BTN_9", 0x110: "BTN_LEFT", 0x111: "BTN_RIGHT", 0x112: "BTN_MIDDLE", 0x113: "BTN_SIDE", 0x114: "BTN_EXTRA", 0x115: "BTN_FORWARD", 0x116: "BTN_BACK", 0x117: "BTN_TASK", 0x120: "BTN_JOYSTICK", 0x121: "BTN_THUMB", 0x122: "BTN_THUMB2", 0x123: "BTN_TOP", 0x124: "BTN_TOP2", 0x125: "BTN_PINKIE", 0x126: "BTN_BASE", 0x127: "BTN_BASE2", 0x128: "BTN_BASE3", 0x129: "BTN_BASE4", 0x12a: "BTN_BASE5", 0x12b: "BTN_BASE6", 0x12f: "BTN_DEAD", 0x130: "BTN_GAMEPAD", 0x131: "BTN_EAST", 0x132: "BTN_C", 0x133: "BTN_NORTH", 0x134: "BTN_WEST", 0x135: "BTN_Z", 0x136: "BTN_TL", 0x137: "BTN_TR", 0x138: "BTN_TL2", 0x139: "BTN_TR2", 0x13a: "BTN_SELECT", 0x13b: "BTN_START", 0x13c: "BTN_MODE", 0x13d: "BTN_THUMBL", 0x13e: "BTN_THUMBR", 0x140: "BTN_TOOL_PEN", 0x141: "BTN_TOOL_RUBBER", 0x142: "BTN_TOOL_BRUSH", 0x143: "BTN_TOOL_PENCIL", 0x144: "BTN_TOOL_AIRBRUSH", 0x145: "BTN_TOOL_FINGER", 0x146: "BTN_TOOL_MOUSE", 0x147: "BTN_TOOL_LENS", 0x148: "BTN_TOOL_QUINTTAP", 0x149: "BTN_STYLUS3", 0x14a: "BTN_TOUCH", 0x14b: "BTN_STYLUS", 0x14c: "BTN_STYLUS2", 0x14d: "BTN_TOOL_DOUBLETAP", 0x14e: "BTN_TOOL_TRIPLETAP", 0x14f: "BTN_TOOL_QUADTAP", 0x150: "BTN_GEAR_DOWN", 0x151: "BTN_GEAR_UP", 0x220: "BTN_DPAD_UP", 0x221: "BTN_DPAD_DOWN", 0x222: "BTN_DPAD_LEFT", 0x223: "BTN_DPAD_RIGHT", 0x2c0: "BTN_TRIGGER_HAPPY1", 0x2c1: "BTN_TRIGGER_HAPPY2", 0x2c2: "BTN_TRIGGER_HAPPY3", 0x2c3: "BTN_TRIGGER_HAPPY4", 0x2c4: "BTN_TRIGGER_HAPPY5", 0x2c5: "BTN_TRIGGER_HAPPY6", 0x2c6: "BTN_TRIGGER_HAPPY7", 0x2c7: "BTN_TRIGGER_HAPPY8", 0x2c8: "BTN_TRIGGER_HAPPY9", 0x2c9: "BTN_TRIGGER_HAPPY10", 0x2ca: "BTN_TRIGGER_HAPPY11", 0x2cb: "BTN_TRIGGER_HAPPY12", 0x2cc: "BTN_TRIGGER_HAPPY13", 0x2cd: "BTN_TRIGGER_HAPPY14", 0x2ce: "BTN_TRIGGER_HAPPY15", 0x2cf: "BTN_TRIGGER_HAPPY16", 0x2d0: "BTN_TRIGGER_HAPPY17", 0x2d1: "BTN_TRIGGER_HAPPY18", 0x2d2: "BTN_TRIGGER_HAPPY19", 0x2d3: "BTN_TRIGGER_HAPPY20", 0x2d4: "BTN_TRIGGER_HAPPY21", 0x2d5: "BTN_TRIGGER_HAPPY22", 0x2d6: "BTN_TRIGGER_HAPPY23", 0x2d7: "BTN_TRIGGER_HAPPY24", 0x2d8: "BTN_TRIGGER_HAPPY25", 0x2d9: "BTN_TRIGGER_HAPPY26", 0x2da: "BTN_TRIGGER_HAPPY27", 0x2db: "BTN_TRIGGER_HAPPY28", 0x2dc: "BTN_TRIGGER_HAPPY29", 0x2dd: "BTN_TRIGGER_HAPPY30", 0x2de: "BTN_TRIGGER_HAPPY31", 0x2df: "BTN_TRIGGER_HAPPY32", 0x2e0: "BTN_TRIGGER_HAPPY33", 0x2e1: "BTN_TRIGGER_HAPPY34", 0x2e2: "BTN_TRIGGER_HAPPY35", 0x2e3: "BTN_TRIGGER_HAPPY36", 0x2e4: "BTN_TRIGGER_HAPPY37", 0x2e5: "BTN_TRIGGER_HAPPY38", 0x2e6: "BTN_TRIGGER_HAPPY39", 0x2e7: "BTN_TRIGGER_HAPPY40"}
 var REPMap = map[uint16]string{0x00: "REP_DELAY", 0x01: "REP_PERIOD"}
 var SNDMap = map[uint16]string{0x00: "SND_CLICK", 0x01: "SND_BELL", 0x02: "SND_TONE"}
 var IDMap = map[uint16]string{0: "ID_BUS", 1: "ID_VENDOR", 2: "ID_PRODUCT", 3: "ID_VERSION"}
-var EVMap = map[uint16]string{0x00: "EV_SYN", 0x01: "EV_KEY", 0x02: "EV_REL", 0x03: "EV_ABS", 0x04: "EV_MSC", 0x05: "EV_SW", 0x11: "EV_LED", 0x12: "EV_SND", 0x14: "EV_REP", 0x15: "EV_FF", 0x16: "EV_PWR", 0x17: "EV_FF_STATUS"}
-var BUSMap = map[uint16]string{0x01: "BUS_PCI", 0x02: "BUS_ISAPNP", 0x03: "BUS_USB", 0x04: "BUS_HIL", 0x05: "BUS_BLUETOOTH", 0x06: "BUS_VIRTUAL", 0x10: "BUS_ISA", 0x11: "BUS_I8042", 0x12: "BUS_XTKBD", 0x13: "BUS_RS232", 0x14: "BUS_GAMEPORT", 0x15: "BUS_PARPORT", 0x16: "BUS_AMIGA", 0x17: "BUS_ADB", 0x18: "BUS_I2C", 0x19: "BUS_HOST", 0x1A: "BUS_GSC", 0x1B: "BUS_ATARI", 0x1C: "BUS_SPI", 0x1D: "BUS_RMI", 0x1E: "BUS_CEC", 0x1F: "BUS_INTEL_ISHTP"}
+var EVMap = map[EventType]string{0x00: "EV_SYN", 0x01: "EV_KEY", 0x02: "EV_REL", 0x03: "EV_ABS", 0x04: "EV_MSC", 0x05: "EV_SW", 0x11: "EV_LED", 0x12: "EV_SND", 0x14: "EV_REP", 0x15: "EV_FF", 0x16: "EV_PWR", 0x17: "EV_FF_STATUS"}
+var BUSMap = map[uint16]string{0x01: "BUS_PCI", 0x02: "BUS_ISAPNP", 0x03: "BUS_USB", 0x04: "BUS_HIL", 0x05: "BUS_BLUETOOTH", 0x06: "BUS_VIRTUAL", 0x10: "BUS_ISA", 0x11: "BUS_I8042", 0x12: "BUS_XTKBD", 0x13: "BUS_RS232", 0x14: "BUS_GAMEPORT", 0x15: "BUS_PARPORT", 0x16: "BUS_AMIGA", 0x17: "BUS_ADB", 0x18: "BUS_I2C", 0x19: "BUS_HOST", 0x1A: "BUS_GSC", 0x1B: "BUS_ATARI", 0x1C: "BUS_SPI", 0x1D: "BUS_RMI", 0x1E: "BUS_CEC", 0x1F: "BUS_INTEL_ISHTP", 0x20: "BUS_AMD_SFH"}
 var SYNMap = map[uint16]string{0: "SYN_REPORT", 1: "SYN_CONFIG", 2: "SYN_MT_REPORT", 3: "SYN_DROPPED"}
 var FFMap = map[uint16]string{0x00: "FF_STATUS_STOPPED", 0x01: "FF_STATUS_PLAYING", 0x50: "FF_RUMBLE", 0x51: "FF_PERIODIC", 0x52: "FF_CONSTANT", 0x53: "FF_SPRING", 0x54: "FF_FRICTION", 0x55: "FF_DAMPER", 0x56: "FF_INERTIA", 0x57: "FF_RAMP", 0x58: "FF_SQUARE", 0x59: "FF_TRIANGLE", 0x5a: "FF_SINE", 0x5b: "FF_SAW_UP", 0x5c: "FF_SAW_DOWN", 0x5d: "FF_CUSTOM", 0x60: "FF_GAIN", 0x61: "FF_AUTOCENTER"}
+var PROPMap = map[uint16]string{0x00: "INPUT_PROP_POINTER", 0x01: "INPUT_PROP_DIRECT", 0x02: "INPUT_PROP_BUTTONPAD", 0x03: "INPUT_PROP_SEMI_MT", 0x04: "INPUT_PROP_TOPBUTTONPAD", 0x05: "INPUT_PROP_POINTING_STICK", 0x06: "INPUT_PROP_ACCELEROMETER"}
+var MTToolMap = map[uint16]string{0x00: "MT_TOOL_FINGER", 0x01: "MT_TOOL_PEN", 0x02: "MT_TOOL_PALM", 0x0a: "MT_TOOL_DIAL"}
 
-func CodeString(etype uint16, code uint16) string {
-	var stype = EVMap[etype]
-	switch stype {
-	case "EV_SYN":
+// CodeString returns the name of a code for the given event type. It
+// returns an empty string if the code has no name.
+func CodeString(etype EventType, code uint16) string {
+	switch etype {
+	case EV_SYN:
 		return SYNMap[code]
-	case "EV_KEY":
-		return KEYMap[code]
-	case "EV_ABS":
+	case EV_KEY:
+		if name, ok := KEYMap[code]; ok {
+			return name
+		}
+		return BTNMap[code]
+	case EV_ABS:
 		return ABSMap[code]
-	case "EV_REL":
+	case EV_REL:
 		return RELMap[code]
-	case "EV_SW":
+	case EV_SW:
 		return SWMap[code]
-	case "EV_MSC":
+	case EV_MSC:
 		return MSCMap[code]
-	case "EV_LED":
+	case EV_LED:
 		return LEDMap[code]
-	case "EV_SND":
+	case EV_SND:
 		return SNDMap[code]
-	case "EV_REP":
+	case EV_REP:
 		return REPMap[code]
-	case "EV_FF":
+	case EV_FF:
 		return FFMap[code]
 	default:
 		return ""
 	}
 }
+
+// eventCodeValues maps each event type to the names of its codes, including
+// aliases, and their values. It is used to parse names.
+var eventCodeValues = map[EventType]map[string]uint16{EV_SYN: {"SYN_REPORT": SYN_REPORT, "SYN_CONFIG": SYN_CONFIG, "SYN_MT_REPORT": SYN_MT_REPORT, "SYN_DROPPED": SYN_DROPPED}, EV_KEY: {"KEY_RESERVED": KEY_RESERVED, "KEY_ESC": KEY_ESC, "KEY_1": KEY_1, "KEY_2": KEY_2, "KEY_3": KEY_3, "KEY_4": KEY_4, "KEY_5": KEY_5, "KEY_6": KEY_6, "KEY_7": KEY_7, "KEY_8": KEY_8, "KEY_9": KEY_9, "KEY_0": KEY_0, "KEY_MINUS": KEY_MINUS, "KEY_EQUAL": KEY_EQUAL, "KEY_BACKSPACE": KEY_BACKSPACE, "KEY_TAB": KEY_TAB, "KEY_Q": KEY_Q, "KEY_W": KEY_W, "KEY_E": KEY_E, "KEY_R": KEY_R, "KEY_T": KEY_T, "KEY_Y": KEY_Y, "KEY_U": KEY_U, "KEY_I": KEY_I, "KEY_O": KEY_O, "KEY_P": KEY_P, "KEY_LEFTBRACE": KEY_LEFTBRACE, "KEY_RIGHTBRACE": KEY_RIGHTBRACE, "KEY_ENTER": KEY_ENTER, "KEY_LEFTCTRL": KEY_LEFTCTRL, "KEY_A": KEY_A, "KEY_S": KEY_S, "KEY_D": KEY_D, "KEY_F": KEY_F, "KEY_G": KEY_G, "KEY_H": KEY_H, "KEY_J": KEY_J, "KEY_K": KEY_K, "KEY_L": KEY_L, "KEY_SEMICOLON": KEY_SEMICOLON, "KEY_APOSTROPHE": KEY_APOSTROPHE, "KEY_GRAVE": KEY_GRAVE, "KEY_LEFTSHIFT": KEY_LEFTSHIFT, "KEY_BACKSLASH": KEY_BACKSLASH, "KEY_Z": KEY_Z, "KEY_X": KEY_X, "KEY_C": KEY_C, "KEY_V": KEY_V, "KEY_B": KEY_B, "KEY_N": KEY_N, "KEY_M": KEY_M, "KEY_COMMA": KEY_COMMA, "KEY_DOT": KEY_DOT, "KEY_SLASH": KEY_SLASH, "KEY_RIGHTSHIFT": KEY_RIGHTSHIFT, "KEY_KPASTERISK": KEY_KPASTERISK, "KEY_LEFTALT": KEY_LEFTALT, "KEY_SPACE": KEY_SPACE, "KEY_CAPSLOCK": KEY_CAPSLOCK, "KEY_F1": KEY_F1, "KEY_F2": KEY_F2, "KEY_F3": KEY_F3, "KEY_F4": KEY_F4, "KEY_F5": KEY_F5, "KEY_F6": KEY_F6, "KEY_F7": KEY_F7, "KEY_F8": KEY_F8, "KEY_F9": KEY_F9, "KEY_F10": KEY_F10, "KEY_NUMLOCK": KEY_NUMLOCK, "KEY_SCROLLLOCK": KEY_SCROLLLOCK, "KEY_KP7": KEY_KP7, "KEY_KP8": KEY_KP8, "KEY_KP9": KEY_KP9, "KEY_KPMINUS": KEY_KPMINUS, "KEY_KP4": KEY_KP4, "KEY_KP5": KEY_KP5, "KEY_KP6": KEY_KP6, "KEY_KPPLUS": KEY_KPPLUS, "KEY_KP1": KEY_KP1, "KEY_KP2": KEY_KP2, "KEY_KP3": KEY_KP3, "KEY_KP0": KEY_KP0, "KEY_KPDOT": KEY_KPDOT, "KEY_ZENKAKUHANKAKU": KEY_ZENKAKUHANKAKU, "KEY_102ND": KEY_102ND, "KEY_F11": KEY_F11, "KEY_F12": KEY_F12, "KEY_RO": KEY_RO, "KEY_KATAKANA": KEY_KATAKANA, "KEY_HIRAGANA": KEY_HIRAGANA, "KEY_HENKAN": KEY_HENKAN, "KEY_KATAKANAHIRAGANA": KEY_KATAKANAHIRAGANA, "KEY_MUHENKAN": KEY_MUHENKAN, "KEY_KPJPCOMMA": KEY_KPJPCOMMA, "KEY_KPENTER": KEY_KPENTER, "KEY_RIGHTCTRL": KEY_RIGHTCTRL, "KEY_KPSLASH": KEY_KPSLASH, "KEY_SYSRQ": KEY_SYSRQ, "KEY_RIGHTALT": KEY_RIGHTALT, "KEY_LINEFEED": KEY_LINEFEED, "KEY_HOME": KEY_HOME, "KEY_UP": KEY_UP, "KEY_PAGEUP": KEY_PAGEUP, "KEY_LEFT": KEY_LEFT, "KEY_RIGHT": KEY_RIGHT, "KEY_END": KEY_END, "KEY_DOWN": KEY_DOWN, "KEY_PAGEDOWN": KEY_PAGEDOWN, "KEY_INSERT": KEY_INSERT, "KEY_DELETE": KEY_DELETE, "KEY_MACRO": KEY_MACRO, "KEY_MUTE": KEY_MUTE, "KEY_VOLUMEDOWN": KEY_VOLUMEDOWN, "KEY_VOLUMEUP": KEY_VOLUMEUP, "KEY_POWER": KEY_POWER, "KEY_KPEQUAL": KEY_KPEQUAL, "KEY_KPPLUSMINUS": KEY_KPPLUSMINUS, "KEY_PAUSE": KEY_PAUSE, "KEY_SCALE": KEY_SCALE, "KEY_KPCOMMA": KEY_KPCOMMA, "KEY_HANGEUL": KEY_HANGEUL, "KEY_HANGUEL": KEY_HANGUEL, "KEY_HANJA": KEY_HANJA, "KEY_YEN": KEY_YEN, "KEY_LEFTMETA": KEY_LEFTMETA, "KEY_RIGHTMETA": KEY_RIGHTMETA, "KEY_COMPOSE": KEY_COMPOSE, "KEY_STOP": KEY_STOP, "KEY_AGAIN": KEY_AGAIN, "KEY_PROPS": KEY_PROPS, "KEY_UNDO": KEY_UNDO, "KEY_FRONT": KEY_FRONT, "KEY_COPY": KEY_COPY, "KEY_OPEN": KEY_OPEN, "KEY_PASTE": KEY_PASTE, "KEY_FIND": KEY_FIND, "KEY_CUT": KEY_CUT, "KEY_HELP": KEY_HELP, "KEY_MENU": KEY_MENU, "KEY_CALC": KEY_CALC, "KEY_SETUP": KEY_SETUP, "KEY_SLEEP": KEY_SLEEP, "KEY_WAKEUP": KEY_WAKEUP, "KEY_FILE": KEY_FILE, "KEY_SENDFILE": KEY_SENDFILE, "KEY_DELETEFILE": KEY_DELETEFILE, "KEY_XFER": KEY_XFER, "KEY_PROG1": KEY_PROG1, "KEY_PROG2": KEY_PROG2, "KEY_WWW": KEY_WWW, "KEY_MSDOS": KEY_MSDOS, "KEY_COFFEE": KEY_COFFEE, "KEY_SCREENLOCK": KEY_SCREENLOCK, "KEY_ROTATE_DISPLAY": KEY_ROTATE_DISPLAY, "KEY_DIRECTION": KEY_DIRECTION, "KEY_CYCLEWINDOWS": KEY_CYCLEWINDOWS, "KEY_MAIL": KEY_MAIL, "KEY_BOOKMARKS": KEY_BOOKMARKS, "KEY_COMPUTER": KEY_COMPUTER, "KEY_BACK": KEY_BACK, "KEY_FORWARD": KEY_FORWARD, "KEY_CLOSECD": KEY_CLOSECD, "KEY_EJECTCD": KEY_EJECTCD, "KEY_EJECTCLOSECD": KEY_EJECTCLOSECD, "KEY_NEXTSONG": KEY_NEXTSONG, "KEY_PLAYPAUSE": KEY_PLAYPAUSE, "KEY_PREVIOUSSONG": KEY_PREVIOUSSONG, "KEY_STOPCD": KEY_STOPCD, "KEY_RECORD": KEY_RECORD, "KEY_REWIND": KEY_REWIND, "KEY_PHONE": KEY_PHONE, "KEY_ISO": KEY_ISO, "KEY_CONFIG": KEY_CONFIG, "KEY_HOMEPAGE": KEY_HOMEPAGE, "KEY_REFRESH": KEY_REFRESH, "KEY_EXIT": KEY_EXIT, "KEY_MOVE": KEY_MOVE, "KEY_EDIT": KEY_EDIT, "KEY_SCROLLUP": KEY_SCROLLUP, "KEY_SCROLLDOWN": KEY_SCROLLDOWN, "KEY_KPLEFTPAREN": KEY_KPLEFTPAREN, "KEY_KPRIGHTPAREN": KEY_KPRIGHTPAREN, "KEY_NEW": KEY_NEW, "KEY_REDO": KEY_REDO, "KEY_F13": KEY_F13, "KEY_F14": KEY_F14, "KEY_F15": KEY_F15, "KEY_F16": KEY_F16, "KEY_F17": KEY_F17, "KEY_F18": KEY_F18, "KEY_F19": KEY_F19, "KEY_F20": KEY_F20, "KEY_F21": KEY_F21, "KEY_F22": KEY_F22, "KEY_F23": KEY_F23, "KEY_F24": KEY_F24, "KEY_PLAYCD": KEY_PLAYCD, "KEY_PAUSECD": KEY_PAUSECD, "KEY_PROG3": KEY_PROG3, "KEY_PROG4": KEY_PROG4, "KEY_ALL_APPLICATIONS": KEY_ALL_APPLICATIONS, "KEY_DASHBOARD": KEY_DASHBOARD, "KEY_SUSPEND": KEY_SUSPEND, "KEY_CLOSE": KEY_CLOSE, "KEY_PLAY": KEY_PLAY, "KEY_FASTFORWARD": KEY_FASTFORWARD, "KEY_BASSBOOST": KEY_BASSBOOST, "KEY_PRINT": KEY_PRINT, "KEY_HP": KEY_HP, "KEY_CAMERA": KEY_CAMERA, "KEY_SOUND": KEY_SOUND, "KEY_QUESTION": KEY_QUESTION, "KEY_EMAIL": KEY_EMAIL, "KEY_CHAT": KEY_CHAT, "KEY_SEARCH": KEY_SEARCH, "KEY_CONNECT": KEY_CONNECT, "KEY_FINANCE": KEY_FINANCE, "KEY_SPORT": KEY_SPORT, "KEY_SHOP": KEY_SHOP, "KEY_ALTERASE": KEY_ALTERASE, "KEY_CANCEL": KEY_CANCEL, "KEY_BRIGHTNESSDOWN": KEY_BRIGHTNESSDOWN, "KEY_BRIGHTNESSUP": KEY_BRIGHTNESSUP, "KEY_MEDIA": KEY_MEDIA, "KEY_SWITCHVIDEOMODE": KEY_SWITCHVIDEOMODE, "KEY_KBDILLUMTOGGLE": KEY_KBDILLUMTOGGLE, "KEY_KBDILLUMDOWN": KEY_KBDILLUMDOWN, "KEY_KBDILLUMUP": KEY_KBDILLUMUP, "KEY_SEND": KEY_SEND, "KEY_REPLY": KEY_REPLY, "KEY_FORWARDMAIL": KEY_FORWARDMAIL, "KEY_SAVE": KEY_SAVE, "KEY_DOCUMENTS": KEY_DOCUMENTS, "KEY_BATTERY": KEY_BATTERY, "KEY_BLUETOOTH": KEY_BLUETOOTH, "KEY_WLAN": KEY_WLAN, "KEY_UWB": KEY_UWB, "KEY_UNKNOWN": KEY_UNKNOWN, "KEY_VIDEO_NEXT": KEY_VIDEO_NEXT, "KEY_VIDEO_PREV": KEY_VIDEO_PREV, "KEY_BRIGHTNESS_CYCLE": KEY_BRIGHTNESS_CYCLE, "KEY_BRIGHTNESS_AUTO": KEY_BRIGHTNESS_AUTO, "KEY_BRIGHTNESS_ZERO": KEY_BRIGHTNESS_ZERO, "KEY_DISPLAY_OFF": KEY_DISPLAY_OFF, "KEY_WWAN": KEY_WWAN, "KEY_WIMAX": KEY_WIMAX, "KEY_RFKILL": KEY_RFKILL, "KEY_MICMUTE": KEY_MICMUTE, "BTN_MISC": BTN_MISC, "BTN_0": BTN_0, "BTN_1": BTN_1, "BTN_2": BTN_2, "BTN_3": BTN_3, "BTN_4": BTN_4, "BTN_5": BTN_5, "BTN_6": BTN_6, "BTN_7": BTN_7, "BTN_8": BTN_8, "BTN_9": BTN_9, "BTN_MOUSE": BTN_MOUSE, "BTN_LEFT": BTN_LEFT, "BTN_RIGHT": BTN_RIGHT, "BTN_MIDDLE": BTN_MIDDLE, "BTN_SIDE": BTN_SIDE, "BTN_EXTRA": BTN_EXTRA, "BTN_FORWARD": BTN_FORWARD, "BTN_BACK": BTN_BACK, "BTN_TASK": BTN_TASK, "BTN_JOYSTICK": BTN_JOYSTICK, "BTN_TRIGGER": BTN_TRIGGER, "BTN_THUMB": BTN_THUMB, "BTN_THUMB2": BTN_THUMB2, "BTN_TOP": BTN_TOP, "BTN_TOP2": BTN_TOP2, "BTN_PINKIE": BTN_PINKIE, "BTN_BASE": BTN_BASE, "BTN_BASE2": BTN_BASE2, "BTN_BASE3": BTN_BASE3, "BTN_BASE4": BTN_BASE4, "BTN_BASE5": BTN_BASE5, "BTN_BASE6": BTN_BASE6, "BTN_DEAD": BTN_DEAD, "BTN_GAMEPAD": BTN_GAMEPAD, "BTN_SOUTH": BTN_SOUTH, "BTN_A": BTN_A, "BTN_EAST": BTN_EAST, "BTN_B": BTN_B, "BTN_C": BTN_C, "BTN_NORTH": BTN_NORTH, "BTN_X": BTN_X, "BTN_WEST": BTN_WEST, "BTN_Y": BTN_Y, "BTN_Z": BTN_Z, "BTN_TL": BTN_TL, "BTN_TR": BTN_TR, "BTN_TL2": BTN_TL2, "BTN_TR2": BTN_TR2, "BTN_SELECT": BTN_SELECT, "BTN_START": BTN_START, "BTN_MODE": BTN_MODE, "BTN_THUMBL": BTN_THUMBL, "BTN_THUMBR": BTN_THUMBR, "BTN_DIGI": BTN_DIGI, "BTN_TOOL_PEN": BTN_TOOL_PEN, "BTN_TOOL_RUBBER": BTN_TOOL_RUBBER, "BTN_TOOL_BRUSH": BTN_TOOL_BRUSH, "BTN_TOOL_PENCIL": BTN_TOOL_PENCIL, "BTN_TOOL_AIRBRUSH": BTN_TOOL_AIRBRUSH, "BTN_TOOL_FINGER": BTN_TOOL_FINGER, "BTN_TOOL_MOUSE": BTN_TOOL_MOUSE, "BTN_TOOL_LENS": BTN_TOOL_LENS, "BTN_TOOL_QUINTTAP": BTN_TOOL_QUINTTAP, "BTN_STYLUS3": BTN_STYLUS3, "BTN_TOUCH": BTN_TOUCH, "BTN_STYLUS": BTN_STYLUS, "BTN_STYLUS2": BTN_STYLUS2, "BTN_TOOL_DOUBLETAP": BTN_TOOL_DOUBLETAP, "BTN_TOOL_TRIPLETAP": BTN_TOOL_TRIPLETAP, "BTN_TOOL_QUADTAP": BTN_TOOL_QUADTAP, "BTN_WHEEL": BTN_WHEEL, "BTN_GEAR_DOWN": BTN_GEAR_DOWN, "BTN_GEAR_UP": BTN_GEAR_UP, "KEY_OK": KEY_OK, "KEY_SELECT": KEY_SELECT, "KEY_GOTO": KEY_GOTO, "KEY_CLEAR": KEY_CLEAR, "KEY_POWER2": KEY_POWER2, "KEY_OPTION": KEY_OPTION, "KEY_INFO": KEY_INFO, "KEY_TIME": KEY_TIME, "KEY_VENDOR": KEY_VENDOR, "KEY_ARCHIVE": KEY_ARCHIVE, "KEY_PROGRAM": KEY_PROGRAM, "KEY_CHANNEL": KEY_CHANNEL, "KEY_FAVORITES": KEY_FAVORITES, "KEY_EPG": KEY_EPG, "KEY_PVR": KEY_PVR, "KEY_MHP": KEY_MHP, "KEY_LANGUAGE": KEY_LANGUAGE, "KEY_TITLE": KEY_TITLE, "KEY_SUBTITLE": KEY_SUBTITLE, "KEY_ANGLE": KEY_ANGLE, "KEY_FULL_SCREEN": KEY_FULL_SCREEN, "KEY_ZOOM": KEY_ZOOM, "KEY_MODE": KEY_MODE, "KEY_KEYBOARD": KEY_KEYBOARD, "KEY_ASPECT_RATIO": KEY_ASPECT_RATIO, "KEY_SCREEN": KEY_SCREEN, "KEY_PC": KEY_PC, "KEY_TV": KEY_TV, "KEY_TV2": KEY_TV2, "KEY_VCR": KEY_VCR, "KEY_VCR2": KEY_VCR2, "KEY_SAT": KEY_SAT, "KEY_SAT2": KEY_SAT2, "KEY_CD": KEY_CD, "KEY_TAPE": KEY_TAPE, "KEY_RADIO": KEY_RADIO, "KEY_TUNER": KEY_TUNER, "KEY_PLAYER": KEY_PLAYER, "KEY_TEXT": KEY_TEXT, "KEY_DVD": KEY_DVD, "KEY_AUX": KEY_AUX, "KEY_MP3": KEY_MP3, "KEY_AUDIO": KEY_AUDIO, "KEY_VIDEO": KEY_VIDEO, "KEY_DIRECTORY": KEY_DIRECTORY, "KEY_LIST": KEY_LIST, "KEY_MEMO": KEY_MEMO, "KEY_CALENDAR": KEY_CALENDAR, "KEY_RED": KEY_RED, "KEY_GREEN": KEY_GREEN, "KEY_YELLOW": KEY_YELLOW, "KEY_BLUE": KEY_BLUE, "KEY_CHANNELUP": KEY_CHANNELUP, "KEY_CHANNELDOWN": KEY_CHANNELDOWN, "KEY_FIRST": KEY_FIRST, "KEY_LAST": KEY_LAST, "KEY_AB": KEY_AB, "KEY_NEXT": KEY_NEXT, "KEY_RESTART": KEY_RESTART, "KEY_SLOW": KEY_SLOW, "KEY_SHUFFLE": KEY_SHUFFLE, "KEY_BREAK": KEY_BREAK, "KEY_PREVIOUS": KEY_PREVIOUS, "KEY_DIGITS": KEY_DIGITS, "KEY_TEEN": KEY_TEEN, "KEY_TWEN": KEY_TWEN, "KEY_VIDEOPHONE": KEY_VIDEOPHONE, "KEY_GAMES": KEY_GAMES, "KEY_ZOOMIN": KEY_ZOOMIN, "KEY_ZOOMOUT": KEY_ZOOMOUT, "KEY_ZOOMRESET": KEY_ZOOMRESET, "KEY_WORDPROCESSOR": KEY_WORDPROCESSOR, "KEY_EDITOR": KEY_EDITOR, "KEY_SPREADSHEET": KEY_SPREADSHEET, "KEY_GRAPHICSEDITOR": KEY_GRAPHICSEDITOR, "KEY_PRESENTATION": KEY_PRESENTATION, "KEY_DATABASE": KEY_DATABASE, "KEY_NEWS": KEY_NEWS, "KEY_VOICEMAIL": KEY_VOICEMAIL, "KEY_ADDRESSBOOK": KEY_ADDRESSBOOK, "KEY_MESSENGER": KEY_MESSENGER, "KEY_DISPLAYTOGGLE": KEY_DISPLAYTOGGLE, "KEY_BRIGHTNESS_TOGGLE": KEY_BRIGHTNESS_TOGGLE, "KEY_SPELLCHECK": KEY_SPELLCHECK, "KEY_LOGOFF": KEY_LOGOFF, "KEY_DOLLAR": KEY_DOLLAR, "KEY_EURO": KEY_EURO, "KEY_FRAMEBACK": KEY_FRAMEBACK, "KEY_FRAMEFORWARD": KEY_FRAMEFORWARD, "KEY_CONTEXT_MENU": KEY_CONTEXT_MENU, "KEY_MEDIA_REPEAT": KEY_MEDIA_REPEAT, "KEY_10CHANNELSUP": KEY_10CHANNELSUP, "KEY_10CHANNELSDOWN": KEY_10CHANNELSDOWN, "KEY_IMAGES": KEY_IMAGES, "KEY_NOTIFICATION_CENTER": KEY_NOTIFICATION_CENTER, "KEY_PICKUP_PHONE": KEY_PICKUP_PHONE, "KEY_HANGUP_PHONE": KEY_HANGUP_PHONE, "KEY_LINK_PHONE": KEY_LINK_PHONE, "KEY_DEL_EOL": KEY_DEL_EOL, "KEY_DEL_EOS": KEY_DEL_EOS, "KEY_INS_LINE": KEY_INS_LINE, "KEY_DEL_LINE": KEY_DEL_LINE, "KEY_FN": KEY_FN, "KEY_FN_ESC": KEY_FN_ESC, "KEY_FN_F1": KEY_FN_F1, "KEY_FN_F2": KEY_FN_F2, "KEY_FN_F3": KEY_FN_F3, "KEY_FN_F4": KEY_FN_F4, "KEY_FN_F5": KEY_FN_F5, "KEY_FN_F6": KEY_FN_F6, "KEY_FN_F7": KEY_FN_F7, "KEY_FN_F8": KEY_FN_F8, "KEY_FN_F9": KEY_FN_F9, "KEY_FN_F10": KEY_FN_F10, "KEY_FN_F11": KEY_FN_F11, "KEY_FN_F12": KEY_FN_F12, "KEY_FN_1": KEY_FN_1, "KEY_FN_2": KEY_FN_2, "KEY_FN_D": KEY_FN_D, "KEY_FN_E": KEY_FN_E, "KEY_FN_F": KEY_FN_F, "KEY_FN_S": KEY_FN_S, "KEY_FN_B": KEY_FN_B, "KEY_FN_RIGHT_SHIFT": KEY_FN_RIGHT_SHIFT, "KEY_BRL_DOT1": KEY_BRL_DOT1, "KEY_BRL_DOT2": KEY_BRL_DOT2, "KEY_BRL_DOT3": KEY_BRL_DOT3, "KEY_BRL_DOT4": KEY_BRL_DOT4, "KEY_BRL_DOT5": KEY_BRL_DOT5, "KEY_BRL_DOT6": KEY_BRL_DOT6, "KEY_BRL_DOT7": KEY_BRL_DOT7, "KEY_BRL_DOT8": KEY_BRL_DOT8, "KEY_BRL_DOT9": KEY_BRL_DOT9, "KEY_BRL_DOT10": KEY_BRL_DOT10, "KEY_NUMERIC_0": KEY_NUMERIC_0, "KEY_NUMERIC_1": KEY_NUMERIC_1, "KEY_NUMERIC_2": KEY_NUMERIC_2, "KEY_NUMERIC_3": KEY_NUMERIC_3, "KEY_NUMERIC_4": KEY_NUMERIC_4, "KEY_NUMERIC_5": KEY_NUMERIC_5, "KEY_NUMERIC_6": KEY_NUMERIC_6, "KEY_NUMERIC_7": KEY_NUMERIC_7, "KEY_NUMERIC_8": KEY_NUMERIC_8, "KEY_NUMERIC_9": KEY_NUMERIC_9, "KEY_NUMERIC_STAR": KEY_NUMERIC_STAR, "KEY_NUMERIC_POUND": KEY_NUMERIC_POUND, "KEY_NUMERIC_A": KEY_NUMERIC_A, "KEY_NUMERIC_B": KEY_NUMERIC_B, "KEY_NUMERIC_C": KEY_NUMERIC_C, "KEY_NUMERIC_D": KEY_NUMERIC_D, "KEY_CAMERA_FOCUS": KEY_CAMERA_FOCUS, "KEY_WPS_BUTTON": KEY_WPS_BUTTON, "KEY_TOUCHPAD_TOGGLE": KEY_TOUCHPAD_TOGGLE, "KEY_TOUCHPAD_ON": KEY_TOUCHPAD_ON, "KEY_TOUCHPAD_OFF": KEY_TOUCHPAD_OFF, "KEY_CAMERA_ZOOMIN": KEY_CAMERA_ZOOMIN, "KEY_CAMERA_ZOOMOUT": KEY_CAMERA_ZOOMOUT, "KEY_CAMERA_UP": KEY_CAMERA_UP, "KEY_CAMERA_DOWN": KEY_CAMERA_DOWN, "KEY_CAMERA_LEFT": KEY_CAMERA_LEFT, "KEY_CAMERA_RIGHT": KEY_CAMERA_RIGHT, "KEY_ATTENDANT_ON": KEY_ATTENDANT_ON, "KEY_ATTENDANT_OFF": KEY_ATTENDANT_OFF, "KEY_ATTENDANT_TOGGLE": KEY_ATTENDANT_TOGGLE, "KEY_LIGHTS_TOGGLE": KEY_LIGHTS_TOGGLE, "BTN_DPAD_UP": BTN_DPAD_UP, "BTN_DPAD_DOWN": BTN_DPAD_DOWN, "BTN_DPAD_LEFT": BTN_DPAD_LEFT, "BTN_DPAD_RIGHT": BTN_DPAD_RIGHT, "KEY_ALS_TOGGLE": KEY_ALS_TOGGLE, "KEY_ROTATE_LOCK_TOGGLE": KEY_ROTATE_LOCK_TOGGLE, "KEY_REFRESH_RATE_TOGGLE": KEY_REFRESH_RATE_TOGGLE, "KEY_BUTTONCONFIG": KEY_BUTTONCONFIG, "KEY_TASKMANAGER": KEY_TASKMANAGER, "KEY_JOURNAL": KEY_JOURNAL, "KEY_CONTROLPANEL": KEY_CONTROLPANEL, "KEY_APPSELECT": KEY_APPSELECT, "KEY_SCREENSAVER": KEY_SCREENSAVER, "KEY_VOICECOMMAND": KEY_VOICECOMMAND, "KEY_ASSISTANT": KEY_ASSISTANT, "KEY_KBD_LAYOUT_NEXT": KEY_KBD_LAYOUT_NEXT, "KEY_EMOJI_PICKER": KEY_EMOJI_PICKER, "KEY_DICTATE": KEY_DICTATE, "KEY_BRIGHTNESS_MIN": KEY_BRIGHTNESS_MIN, "KEY_KBDINPUTASSIST_PREV": KEY_KBDINPUTASSIST_PREV, "KEY_KBDINPUTASSIST_NEXT": KEY_KBDINPUTASSIST_NEXT, "KEY_KBDINPUTASSIST_PREVGROUP": KEY_KBDINPUTASSIST_PREVGROUP, "KEY_KBDINPUTASSIST_NEXTGROUP": KEY_KBDINPUTASSIST_NEXTGROUP, "KEY_KBDINPUTASSIST_ACCEPT": KEY_KBDINPUTASSIST_ACCEPT, "KEY_KBDINPUTASSIST_CANCEL": KEY_KBDINPUTASSIST_CANCEL, "KEY_RIGHT_UP": KEY_RIGHT_UP, "KEY_RIGHT_DOWN": KEY_RIGHT_DOWN, "KEY_LEFT_UP": KEY_LEFT_UP, "KEY_LEFT_DOWN": KEY_LEFT_DOWN, "KEY_ROOT_MENU": KEY_ROOT_MENU, "KEY_MEDIA_TOP_MENU": KEY_MEDIA_TOP_MENU, "KEY_NUMERIC_11": KEY_NUMERIC_11, "KEY_NUMERIC_12": KEY_NUMERIC_12, "KEY_AUDIO_DESC": KEY_AUDIO_DESC, "KEY_3D_MODE": KEY_3D_MODE, "KEY_NEXT_FAVORITE": KEY_NEXT_FAVORITE, "KEY_STOP_RECORD": KEY_STOP_RECORD, "KEY_PAUSE_RECORD": KEY_PAUSE_RECORD, "KEY_VOD": KEY_VOD, "KEY_UNMUTE": KEY_UNMUTE, "KEY_FASTREVERSE": KEY_FASTREVERSE, "KEY_SLOWREVERSE": KEY_SLOWREVERSE, "KEY_DATA": KEY_DATA, "KEY_ONSCREEN_KEYBOARD": KEY_ONSCREEN_KEYBOARD, "KEY_PRIVACY_SCREEN_TOGGLE": KEY_PRIVACY_SCREEN_TOGGLE, "KEY_SELECTIVE_SCREENSHOT": KEY_SELECTIVE_SCREENSHOT, "KEY_NEXT_ELEMENT": KEY_NEXT_ELEMENT, "KEY_PREVIOUS_ELEMENT": KEY_PREVIOUS_ELEMENT, "KEY_AUTOPILOT_ENGAGE_TOGGLE": KEY_AUTOPILOT_ENGAGE_TOGGLE, "KEY_MARK_WAYPOINT": KEY_MARK_WAYPOINT, "KEY_SOS": KEY_SOS, "KEY_NAV_CHART": KEY_NAV_CHART, "KEY_FISHING_CHART": KEY_FISHING_CHART, "KEY_SINGLE_RANGE_RADAR": KEY_SINGLE_RANGE_RADAR, "KEY_DUAL_RANGE_RADAR": KEY_DUAL_RANGE_RADAR, "KEY_RADAR_OVERLAY": KEY_RADAR_OVERLAY, "KEY_TRADITIONAL_SONAR": KEY_TRADITIONAL_SONAR, "KEY_CLEARVU_SONAR": KEY_CLEARVU_SONAR, "KEY_SIDEVU_SONAR": KEY_SIDEVU_SONAR, "KEY_NAV_INFO": KEY_NAV_INFO, "KEY_BRIGHTNESS_MENU": KEY_BRIGHTNESS_MENU, "KEY_MACRO1": KEY_MACRO1, "KEY_MACRO2": KEY_MACRO2, "KEY_MACRO3": KEY_MACRO3, "KEY_MACRO4": KEY_MACRO4, "KEY_MACRO5": KEY_MACRO5, "KEY_MACRO6": KEY_MACRO6, "KEY_MACRO7": KEY_MACRO7, "KEY_MACRO8": KEY_MACRO8, "KEY_MACRO9": KEY_MACRO9, "KEY_MACRO10": KEY_MACRO10, "KEY_MACRO11": KEY_MACRO11, "KEY_MACRO12": KEY_MACRO12, "KEY_MACRO13": KEY_MACRO13, "KEY_MACRO14": KEY_MACRO14, "KEY_MACRO15": KEY_MACRO15, "KEY_MACRO16": KEY_MACRO16, "KEY_MACRO17": KEY_MACRO17, "KEY_MACRO18": KEY_MACRO18, "KEY_MACRO19": KEY_MACRO19, "KEY_MACRO20": KEY_MACRO20, "KEY_MACRO21": KEY_MACRO21, "KEY_MACRO22": KEY_MACRO22, "KEY_MACRO23": KEY_MACRO23, "KEY_MACRO24": KEY_MACRO24, "KEY_MACRO25": KEY_MACRO25, "KEY_MACRO26": KEY_MACRO26, "KEY_MACRO27": KEY_MACRO27, "KEY_MACRO28": KEY_MACRO28, "KEY_MACRO29": KEY_MACRO29, "KEY_MACRO30": KEY_MACRO30, "KEY_MACRO_RECORD_START": KEY_MACRO_RECORD_START, "KEY_MACRO_RECORD_STOP": KEY_MACRO_RECORD_STOP, "KEY_MACRO_PRESET_CYCLE": KEY_MACRO_PRESET_CYCLE, "KEY_MACRO_PRESET1": KEY_MACRO_PRESET1, "KEY_MACRO_PRESET2": KEY_MACRO_PRESET2, "KEY_MACRO_PRESET3": KEY_MACRO_PRESET3, "KEY_KBD_LCD_MENU1": KEY_KBD_LCD_MENU1, "KEY_KBD_LCD_MENU2": KEY_KBD_LCD_MENU2, "KEY_KBD_LCD_MENU3": KEY_KBD_LCD_MENU3, "KEY_KBD_LCD_MENU4": KEY_KBD_LCD_MENU4, "KEY_KBD_LCD_MENU5": KEY_KBD_LCD_MENU5, "BTN_TRIGGER_HAPPY": BTN_TRIGGER_HAPPY, "BTN_TRIGGER_HAPPY1": BTN_TRIGGER_HAPPY1, "BTN_TRIGGER_HAPPY2": BTN_TRIGGER_HAPPY2, "BTN_TRIGGER_HAPPY3": BTN_TRIGGER_HAPPY3, "BTN_TRIGGER_HAPPY4": BTN_TRIGGER_HAPPY4, "BTN_TRIGGER_HAPPY5": BTN_TRIGGER_HAPPY5, "BTN_TRIGGER_HAPPY6": BTN_TRIGGER_HAPPY6, "BTN_TRIGGER_HAPPY7": BTN_TRIGGER_HAPPY7, "BTN_TRIGGER_HAPPY8": BTN_TRIGGER_HAPPY8, "BTN_TRIGGER_HAPPY9": BTN_TRIGGER_HAPPY9, "BTN_TRIGGER_HAPPY10": BTN_TRIGGER_HAPPY10, "BTN_TRIGGER_HAPPY11": BTN_TRIGGER_HAPPY11, "BTN_TRIGGER_HAPPY12": BTN_TRIGGER_HAPPY12, "BTN_TRIGGER_HAPPY13": BTN_TRIGGER_HAPPY13, "BTN_TRIGGER_HAPPY14": BTN_TRIGGER_HAPPY14, "BTN_TRIGGER_HAPPY15": BTN_TRIGGER_HAPPY15, "BTN_TRIGGER_HAPPY16": BTN_TRIGGER_HAPPY16, "BTN_TRIGGER_HAPPY17": BTN_TRIGGER_HAPPY17, "BTN_TRIGGER_HAPPY18": BTN_TRIGGER_HAPPY18, "BTN_TRIGGER_HAPPY19": BTN_TRIGGER_HAPPY19, "BTN_TRIGGER_HAPPY20": BTN_TRIGGER_HAPPY20, "BTN_TRIGGER_HAPPY21": BTN_TRIGGER_HAPPY21, "BTN_TRIGGER_HAPPY22": BTN_TRIGGER_HAPPY22, "BTN_TRIGGER_HAPPY23": BTN_TRIGGER_HAPPY23, "BTN_TRIGGER_HAPPY24": BTN_TRIGGER_HAPPY24, "BTN_TRIGGER_HAPPY25": BTN_TRIGGER_HAPPY25, "BTN_TRIGGER_HAPPY26": BTN_TRIGGER_HAPPY26, "BTN_TRIGGER_HAPPY27": BTN_TRIGGER_HAPPY27, "BTN_TRIGGER_HAPPY28": BTN_TRIGGER_HAPPY28, "BTN_TRIGGER_HAPPY29": BTN_TRIGGER_HAPPY29, "BTN_TRIGGER_HAPPY30": BTN_TRIGGER_HAPPY30, "BTN_TRIGGER_HAPPY31": BTN_TRIGGER_HAPPY31, "BTN_TRIGGER_HAPPY32": BTN_TRIGGER_HAPPY32, "BTN_TRIGGER_HAPPY33": BTN_TRIGGER_HAPPY33, "BTN_TRIGGER_HAPPY34": BTN_TRIGGER_HAPPY34, "BTN_TRIGGER_HAPPY35": BTN_TRIGGER_HAPPY35, "BTN_TRIGGER_HAPPY36": BTN_TRIGGER_HAPPY36, "BTN_TRIGGER_HAPPY37": BTN_TRIGGER_HAPPY37, "BTN_TRIGGER_HAPPY38": BTN_TRIGGER_HAPPY38, "BTN_TRIGGER_HAPPY39": BTN_TRIGGER_HAPPY39, "BTN_TRIGGER_HAPPY40": BTN_TRIGGER_HAPPY40, "KEY_MIN_INTERESTING": KEY_MIN_INTERESTING}, EV_REL: {"REL_X": REL_X, "REL_Y": REL_Y, "REL_Z": REL_Z, "REL_RX": REL_RX, "REL_RY": REL_RY, "REL_RZ": REL_RZ, "REL_HWHEEL": REL_HWHEEL, "REL_DIAL": REL_DIAL, "REL_WHEEL": REL_WHEEL, "REL_MISC": REL_MISC, "REL_RESERVED": REL_RESERVED, "REL_WHEEL_HI_RES": REL_WHEEL_HI_RES, "REL_HWHEEL_HI_RES": REL_HWHEEL_HI_RES}, EV_ABS: {"ABS_X": ABS_X, "ABS_Y": ABS_Y, "ABS_Z": ABS_Z, "ABS_RX": ABS_RX, "ABS_RY": ABS_RY, "ABS_RZ": ABS_RZ, "ABS_THROTTLE": ABS_THROTTLE, "ABS_RUDDER": ABS_RUDDER, "ABS_WHEEL": ABS_WHEEL, "ABS_GAS": ABS_GAS, "ABS_BRAKE": ABS_BRAKE, "ABS_HAT0X": ABS_HAT0X, "ABS_HAT0Y": ABS_HAT0Y, "ABS_HAT1X": ABS_HAT1X, "ABS_HAT1Y": ABS_HAT1Y, "ABS_HAT2X": ABS_HAT2X, "ABS_HAT2Y": ABS_HAT2Y, "ABS_HAT3X": ABS_HAT3X, "ABS_HAT3Y": ABS_HAT3Y, "ABS_PRESSURE": ABS_PRESSURE, "ABS_DISTANCE": ABS_DISTANCE, "ABS_TILT_X": ABS_TILT_X, "ABS_TILT_Y": ABS_TILT_Y, "ABS_TOOL_WIDTH": ABS_TOOL_WIDTH, "ABS_VOLUME": ABS_VOLUME, "ABS_PROFILE": ABS_PROFILE, "ABS_MISC": ABS_MISC, "ABS_RESERVED": ABS_RESERVED, "ABS_MT_SLOT": ABS_MT_SLOT, "ABS_MT_TOUCH_MAJOR": ABS_MT_TOUCH_MAJOR, "ABS_MT_TOUCH_MINOR": ABS_MT_TOUCH_MINOR, "ABS_MT_WIDTH_MAJOR": ABS_MT_WIDTH_MAJOR, "ABS_MT_WIDTH_MINOR": ABS_MT_WIDTH_MINOR, "ABS_MT_ORIENTATION": ABS_MT_ORIENTATION, "ABS_MT_POSITION_X": ABS_MT_POSITION_X, "ABS_MT_POSITION_Y": ABS_MT_POSITION_Y, "ABS_MT_TOOL_TYPE": ABS_MT_TOOL_TYPE, "ABS_MT_BLOB_ID": ABS_MT_BLOB_ID, "ABS_MT_TRACKING_ID": ABS_MT_TRACKING_ID, "ABS_MT_PRESSURE": ABS_MT_PRESSURE, "ABS_MT_DISTANCE": ABS_MT_DISTANCE, "ABS_MT_TOOL_X": ABS_MT_TOOL_X, "ABS_MT_TOOL_Y": ABS_MT_TOOL_Y}, EV_MSC: {"MSC_SERIAL": MSC_SERIAL, "MSC_PULSELED": MSC_PULSELED, "MSC_GESTURE": MSC_GESTURE, "MSC_RAW": MSC_RAW, "MSC_SCAN": MSC_SCAN, "MSC_TIMESTAMP": MSC_TIMESTAMP}, EV_SW: {"SW_LID": SW_LID, "SW_TABLET_MODE": SW_TABLET_MODE, "SW_HEADPHONE_INSERT": SW_HEADPHONE_INSERT, "SW_RFKILL_ALL": SW_RFKILL_ALL, "SW_RADIO": SW_RADIO, "SW_MICROPHONE_INSERT": SW_MICROPHONE_INSERT, "SW_DOCK": SW_DOCK, "SW_LINEOUT_INSERT": SW_LINEOUT_INSERT, "SW_JACK_PHYSICAL_INSERT": SW_JACK_PHYSICAL_INSERT, "SW_VIDEOOUT_INSERT": SW_VIDEOOUT_INSERT, "SW_CAMERA_LENS_COVER": SW_CAMERA_LENS_COVER, "SW_KEYPAD_SLIDE": SW_KEYPAD_SLIDE, "SW_FRONT_PROXIMITY": SW_FRONT_PROXIMITY, "SW_ROTATE_LOCK": SW_ROTATE_LOCK, "SW_LINEIN_INSERT": SW_LINEIN_INSERT, "SW_MUTE_DEVICE": SW_MUTE_DEVICE, "SW_PEN_INSERTED": SW_PEN_INSERTED, "SW_MACHINE_COVER": SW_MACHINE_COVER}, EV_LED: {"LED_NUML": LED_NUML, "LED_CAPSL": LED_CAPSL, "LED_SCROLLL": LED_SCROLLL, "LED_COMPOSE": LED_COMPOSE, "LED_KANA": LED_KANA, "LED_SLEEP": LED_SLEEP, "LED_SUSPEND": LED_SUSPEND, "LED_MUTE": LED_MUTE, "LED_MISC": LED_MISC, "LED_MAIL": LED_MAIL, "LED_CHARGING": LED_CHARGING}, EV_SND: {"SND_CLICK": SND_CLICK, "SND_BELL": SND_BELL, "SND_TONE": SND_TONE}, EV_REP: {"REP_DELAY": REP_DELAY, "REP_PERIOD": REP_PERIOD}, EV_FF: {"FF_STATUS_STOPPED": FF_STATUS_STOPPED, "FF_STATUS_PLAYING": FF_STATUS_PLAYING, "FF_RUMBLE": FF_RUMBLE, "FF_PERIODIC": FF_PERIODIC, "FF_CONSTANT": FF_CONSTANT, "FF_SPRING": FF_SPRING, "FF_FRICTION": FF_FRICTION, "FF_DAMPER": FF_DAMPER, "FF_INERTIA": FF_INERTIA, "FF_RAMP": FF_RAMP, "FF_EFFECT_MIN": FF_EFFECT_MIN, "FF_SQUARE": FF_SQUARE, "FF_TRIANGLE": FF_TRIANGLE, "FF_SINE": FF_SINE, "FF_SAW_UP": FF_SAW_UP, "FF_SAW_DOWN": FF_SAW_DOWN, "FF_CUSTOM": FF_CUSTOM, "FF_WAVEFORM_MIN": FF_WAVEFORM_MIN, "FF_GAIN": FF_GAIN, "FF_AUTOCENTER": FF_AUTOCENTER, "FF_MAX_EFFECTS": FF_MAX_EFFECTS}}
@@ -15,57 +15,123 @@
 package remouseable
 
 import (
-	"bytes"
+	"context"
 	"encoding/binary"
 	"io"
+	"sync"
 	"time"
 )
 
-type rawEvent struct {
-	// The time values must be uint32 to work with the tablet build. The default
-	// syscall.Timeval uses uint64 on a 64bit platform so we must adapt here.
-	Sec   uint32
-	Usec  uint32
-	Type  uint16
-	Code  uint16
-	Value int32
+const (
+	// eventSize32 is the size of an event in the EventLayoutTimeval32 layout.
+	// The time values are uint32 seconds and microseconds followed by the
+	// uint16 type, the uint16 code, and the int32 value.
+	eventSize32 = 16
+	// eventSize64 is the size of an event in the EventLayoutTimeval64 layout.
+	// It is the same as eventSize32 except that the time values are uint64.
+	eventSize64 = 24
+	// DefaultEvdevBufferSize is the number of bytes FileEvdevIterator reads
+	// from its source at once. It holds 256 events in the 16 byte layout.
+	DefaultEvdevBufferSize = 4096
+)
+
+// eventSize returns the number of bytes used by each event in a layout.
+func eventSize(layout string) int {
+	if layout == EventLayoutTimeval64 {
+		return eventSize64
+	}
+	return eventSize32
+}
+
+// decodeEvent reads an event from the start of b, which must hold at least
+// eventSize(layout) bytes.
+func decodeEvent(b []byte, layout string) EvdevEvent {
+	var sec, usec int64
+	if layout == EventLayoutTimeval64 {
+		sec = int64(binary.LittleEndian.Uint64(b[0:8]))
+		usec = int64(binary.LittleEndian.Uint64(b[8:16]))
+		b = b[16:eventSize64]
+	} else {
+		sec = int64(binary.LittleEndian.Uint32(b[0:4]))
+		usec = int64(binary.LittleEndian.Uint32(b[4:8]))
+		b = b[8:eventSize32]
+	}
+	return EvdevEvent{
+		Time:  time.Unix(sec, usec*int64(time.Microsecond)),
+		Type:  EventType(binary.LittleEndian.Uint16(b[0:2])),
+		Code:  binary.LittleEndian.Uint16(b[2:4]),
+		Value: int32(binary.LittleEndian.Uint32(b[4:8])),
+	}
 }
 
 // FileEvdevIterator implements the EvdevIterator interface by consuming from
 // an io.ReadCloser.
+//
+// The source is read in chunks of up to BufferSize bytes and events are
+// decoded from the chunk in place so that iterating does not allocate. A read
+// returns whatever the source has ready rather than waiting for the buffer to
+// fill so events are not delayed by the batching.
 type FileEvdevIterator struct {
-	Source  io.ReadCloser
+	Source io.ReadCloser
+	// Layout is one of the EventLayout* values. The default is
+	// EventLayoutTimeval32.
+	Layout string
+	// BufferSize is the number of bytes to read at once. The default is
+	// DefaultEvdevBufferSize. It is raised to the size of one event if it is
+	// smaller.
+	BufferSize int
+	buf        []byte
+	start      int
+	end        int
+	readErr    error
+	// mu guards err so that Close may be called while Next is blocked in a
+	// read, which is how QueuedEvdevIterator stops its reader.
+	mu      sync.Mutex
 	err     error
 	current EvdevEvent
 }
 
-// Next reads an event from the file source.
+// Next decodes the next event from the buffer, reading more from the source
+// when the buffer does not hold a whole event.
 func (it *FileEvdevIterator) Next() bool {
 	if it.err != nil {
 		// Prevent re-entry after an error.
 		return false
 	}
-
-	evt := rawEvent{}
-	size := binary.Size(evt)
-	buf := make([]byte, size)
-
-	if _, err := it.Source.Read(buf); err != nil {
-		it.err = err
-		return false
-	}
-
-	if err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &evt); err != nil {
-		it.err = err
-		return false
+	size := eventSize(it.Layout)
+	if it.buf == nil {
+		n := it.BufferSize
+		if n <= 0 {
+			n = DefaultEvdevBufferSize
+		}
+		if n < size {
+			n = size
+		}
+		it.buf = make([]byte, n)
 	}
-
-	it.current = EvdevEvent{
-		Time:  time.Unix(int64(evt.Sec), int64(evt.Usec)),
-		Type:  evt.Type,
-		Code:  evt.Code,
-		Value: evt.Value,
+	for it.end-it.start < size {
+		if it.readErr != nil {
+			err := it.readErr
+			if err == io.EOF && it.end > it.start {
+				err = io.ErrUnexpectedEOF
+			}
+			it.mu.Lock()
+			it.err = err
+			it.mu.Unlock()
+			return false
+		}
+		if len(it.buf)-it.start < size {
+			// Move the partial event to the front to make room for the rest.
+			it.end = copy(it.buf, it.buf[it.start:it.end])
+			it.start = 0
+		}
+		n, err := it.Source.Read(it.buf[it.end:])
+		it.end += n
+		// Events that arrived with an error are returned before the error.
+		it.readErr = err
 	}
+	it.current = decodeEvent(it.buf[it.start:], it.Layout)
+	it.start += size
 	return true
 }
 
@@ -77,6 +143,8 @@ func (it *FileEvdevIterator) Current() EvdevEvent {
 // Close the underlying source and return any errors.
 func (it *FileEvdevIterator) Close() error {
 	err := it.Source.Close()
+	it.mu.Lock()
+	defer it.mu.Unlock()
 	if it.err == nil {
 		return err
 	}
@@ -87,7 +155,7 @@ func (it *FileEvdevIterator) Close() error {
 // event types.
 type SelectingEvdevIterator struct {
 	Wrapped   EvdevIterator
-	Selection []uint16
+	Selection []EventType
 	current   EvdevEvent
 }
 
@@ -120,7 +188,7 @@ func (it *SelectingEvdevIterator) Close() error {
 // top-level event types.
 type FilteringEvdevIterator struct {
 	Wrapped EvdevIterator
-	Filter  []uint16
+	Filter  []EventType
 	current EvdevEvent
 }
 
@@ -153,3 +221,162 @@ func (it *FilteringEvdevIterator) Current() EvdevEvent {
 func (it *FilteringEvdevIterator) Close() error {
 	return it.Wrapped.Close()
 }
+
+// QueuedEvdevIterator reads from the wrapped iterator on its own goroutine and
+// holds up to Size events until they are taken by Next. This keeps the source,
+// such as the SSH stream from the tablet, moving while the rest of the runtime
+// is busy.
+type QueuedEvdevIterator struct {
+	Wrapped EvdevIterator
+	// Size is the number of events that may wait. The default is
+	// DefaultQueueSize.
+	Size    int
+	events  chan EvdevEvent
+	done    chan struct{}
+	wg      sync.WaitGroup
+	started bool
+	current EvdevEvent
+}
+
+func (it *QueuedEvdevIterator) start() {
+	size := it.Size
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+	it.started = true
+	it.events = make(chan EvdevEvent, size)
+	it.done = make(chan struct{})
+	it.wg.Add(1)
+	go it.read()
+}
+
+// read copies events from the wrapped iterator to the queue. The queue is
+// closed when the wrapped iterator stops.
+func (it *QueuedEvdevIterator) read() {
+	defer it.wg.Done()
+	defer close(it.events)
+	for it.Wrapped.Next() {
+		select {
+		case it.events <- it.Wrapped.Current():
+		case <-it.done:
+			return
+		}
+	}
+}
+
+// Next waits for the next event from the reader goroutine. The goroutine is
+// started by the first call.
+func (it *QueuedEvdevIterator) Next() bool {
+	if !it.started {
+		it.start()
+	}
+	evt, ok := <-it.events
+	if !ok {
+		return false
+	}
+	it.current = evt
+	return true
+}
+
+// Current returns the active element.
+func (it *QueuedEvdevIterator) Current() EvdevEvent {
+	return it.current
+}
+
+// Close the wrapped instance, which ends any read in progress, and wait for
+// the reader goroutine to exit.
+func (it *QueuedEvdevIterator) Close() error {
+	if !it.started {
+		return it.Wrapped.Close()
+	}
+	close(it.done)
+	err := it.Wrapped.Close()
+	it.wg.Wait()
+	return err
+}
+
+// ContextEvdevIterator stops when its context is done. The wrapped iterator is
+// closed as soon as the context is done, rather than on the next call to
+// Close, so that a read that is waiting for the tablet returns right away. The
+// wrapped iterator must allow Close to be called while Next is running, as
+// FileEvdevIterator does.
+type ContextEvdevIterator struct {
+	Wrapped EvdevIterator
+	Context context.Context
+	stop    func() bool
+	once    sync.Once
+	err     error
+}
+
+// closeWrapped closes the wrapped iterator once.
+func (it *ContextEvdevIterator) closeWrapped() {
+	it.once.Do(func() {
+		it.err = it.Wrapped.Close()
+	})
+}
+
+// Next returns false once the context is done.
+func (it *ContextEvdevIterator) Next() bool {
+	if it.stop == nil {
+		it.stop = context.AfterFunc(it.Context, it.closeWrapped)
+	}
+	if it.Context.Err() != nil {
+		return false
+	}
+	return it.Wrapped.Next() && it.Context.Err() == nil
+}
+
+// Current returns the active element.
+func (it *ContextEvdevIterator) Current() EvdevEvent {
+	return it.Wrapped.Current()
+}
+
+// Close the wrapped instance. The error is the error of the context if it
+// ended the iteration because errors from the interrupted read are expected.
+func (it *ContextEvdevIterator) Close() error {
+	if it.stop != nil {
+		it.stop()
+	}
+	it.closeWrapped()
+	if err := it.Context.Err(); err != nil {
+		return err
+	}
+	return it.err
+}
+
+// PacedEvdevIterator delays each event by the time that passed between it and
+// the previous event. It is used to replay recorded events at the speed at
+// which they happened.
+type PacedEvdevIterator struct {
+	Wrapped EvdevIterator
+	// Sleep defaults to time.Sleep and is replaceable for testing.
+	Sleep    func(time.Duration)
+	previous time.Time
+}
+
+// Next waits until the next event is due and then returns it.
+func (it *PacedEvdevIterator) Next() bool {
+	if !it.Wrapped.Next() {
+		return false
+	}
+	current := it.Wrapped.Current().Time
+	if !it.previous.IsZero() && current.After(it.previous) {
+		sleep := it.Sleep
+		if sleep == nil {
+			sleep = time.Sleep
+		}
+		sleep(current.Sub(it.previous))
+	}
+	it.previous = current
+	return true
+}
+
+// Current returns the active element.
+func (it *PacedEvdevIterator) Current() EvdevEvent {
+	return it.Wrapped.Current()
+}
+
+// Close proxies to the wrapped instance.
+func (it *PacedEvdevIterator) Close() error {
+	return it.Wrapped.Close()
+}
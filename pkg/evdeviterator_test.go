@@ -15,8 +15,12 @@
 package remouseable
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"testing"
+	"time"
 
 	gomock "github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
@@ -65,9 +69,9 @@ func TestFileEvdevIterator_Next(t *testing.T) {
 				Source: src,
 			}
 			if tt.wantRead {
-				src.EXPECT().Read(gomock.Any()).Do(func(b []byte) {
-					copy(b, tt.readBytes)
-				}).Return(0, tt.readErr)
+				src.EXPECT().Read(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+					return copy(b, tt.readBytes), tt.readErr
+				})
 			}
 			src.EXPECT().Close().Return(tt.closeErr).AnyTimes()
 			require.Equal(t, tt.want, it.Next())
@@ -79,9 +83,213 @@ func TestFileEvdevIterator_Next(t *testing.T) {
 	}
 }
 
+func TestFileEvdevIterator_NextLayouts(t *testing.T) {
+	tests := []struct {
+		name      string
+		layout    string
+		readBytes []byte
+	}{
+		{
+			name:   "timeval32",
+			layout: EventLayoutTimeval32,
+			readBytes: []byte{
+				1, 0, 0, 0, 2, 0, 0, 0,
+				3, 0, 24, 0, 0xE8, 0x03, 0, 0,
+			},
+		},
+		{
+			name:   "timeval64",
+			layout: EventLayoutTimeval64,
+			readBytes: []byte{
+				1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0,
+				3, 0, 24, 0, 0xE8, 0x03, 0, 0,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			src := NewMockReadCloser(ctrl)
+			it := &FileEvdevIterator{
+				Source: src,
+				Layout: tt.layout,
+			}
+			src.EXPECT().Read(gomock.Len(DefaultEvdevBufferSize)).DoAndReturn(func(b []byte) (int, error) {
+				return copy(b, tt.readBytes), nil
+			})
+			require.True(t, it.Next())
+			evt := it.Current()
+			require.Equal(t, int64(1), evt.Time.Unix())
+			// The second field is microseconds.
+			require.Equal(t, 2000, evt.Time.Nanosecond())
+			require.Equal(t, EV_ABS, evt.Type)
+			require.Equal(t, uint16(ABS_PRESSURE), evt.Code)
+			require.Equal(t, int32(1000), evt.Value)
+		})
+	}
+}
+
+// chunkedReader returns at most chunk bytes from each Read to act like a
+// stream that delivers events in pieces.
+type chunkedReader struct {
+	r     io.Reader
+	chunk int
+}
+
+func (r *chunkedReader) Read(b []byte) (int, error) {
+	if len(b) > r.chunk {
+		b = b[:r.chunk]
+	}
+	return r.r.Read(b)
+}
+
+func (r *chunkedReader) Close() error {
+	return nil
+}
+
+func TestFileEvdevIterator_NextBatches(t *testing.T) {
+	data := make([]byte, 0, 5*eventSize32)
+	for i := 0; i < 5; i++ {
+		data = append(data,
+			byte(i), 0, 0, 0, 0, 0, 0, 0,
+			3, 0, 24, 0, byte(i), 0, 0, 0,
+		)
+	}
+	for _, chunk := range []int{1, 7, 16, 33, 4096} {
+		t.Run(fmt.Sprintf("chunk%d", chunk), func(t *testing.T) {
+			it := &FileEvdevIterator{
+				Source:     &chunkedReader{r: bytes.NewReader(data), chunk: chunk},
+				BufferSize: 40,
+			}
+			for i := 0; i < 5; i++ {
+				require.True(t, it.Next())
+				require.Equal(t, int64(i), it.Current().Time.Unix())
+				require.Equal(t, int32(i), it.Current().Value)
+			}
+			require.False(t, it.Next())
+			require.Equal(t, io.EOF, it.Close())
+		})
+	}
+
+	it := &FileEvdevIterator{Source: &chunkedReader{r: bytes.NewReader(data[:20]), chunk: 20}}
+	require.True(t, it.Next())
+	require.False(t, it.Next())
+	require.Equal(t, io.ErrUnexpectedEOF, it.Close())
+}
+
+// repeatingReader endlessly returns copies of an event.
+type repeatingReader struct {
+	event []byte
+}
+
+func (r *repeatingReader) Read(b []byte) (int, error) {
+	n := 0
+	for len(b)-n >= len(r.event) {
+		n += copy(b[n:], r.event)
+	}
+	return n, nil
+}
+
+func (r *repeatingReader) Close() error {
+	return nil
+}
+
+func BenchmarkFileEvdevIterator_Next(b *testing.B) {
+	for _, layout := range []string{EventLayoutTimeval32, EventLayoutTimeval64} {
+		b.Run(layout, func(b *testing.B) {
+			event := make([]byte, eventSize(layout))
+			event[len(event)-8] = byte(EV_ABS)
+			event[len(event)-6] = ABS_PRESSURE
+			it := &FileEvdevIterator{
+				Source: &repeatingReader{event: event},
+				Layout: layout,
+			}
+			b.ReportAllocs()
+			b.SetBytes(int64(len(event)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if !it.Next() {
+					b.Fatal(it.Close())
+				}
+			}
+		})
+	}
+}
+
+func TestFileEvdevIterator_NextAllocs(t *testing.T) {
+	it := &FileEvdevIterator{Source: &repeatingReader{event: make([]byte, eventSize32)}}
+	it.Next()
+	allocs := testing.AllocsPerRun(1000, func() {
+		it.Next()
+	})
+	require.Equal(t, float64(0), allocs)
+}
+
+func TestQueuedEvdevIterator(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	src := NewMockEvdevIterator(ctrl)
+	gomock.InOrder(
+		src.EXPECT().Next().Return(true),
+		src.EXPECT().Current().Return(EvdevEvent{Code: 1}),
+		src.EXPECT().Next().Return(true),
+		src.EXPECT().Current().Return(EvdevEvent{Code: 2}),
+		src.EXPECT().Next().Return(false),
+	)
+	src.EXPECT().Close().Return(io.EOF)
+	it := &QueuedEvdevIterator{Wrapped: src, Size: 1}
+	require.True(t, it.Next())
+	require.Equal(t, uint16(1), it.Current().Code)
+	require.True(t, it.Next())
+	require.Equal(t, uint16(2), it.Current().Code)
+	require.False(t, it.Next())
+	require.Equal(t, io.EOF, it.Close())
+}
+
+func TestQueuedEvdevIteratorCloseUnblocksRead(t *testing.T) {
+	r, w := io.Pipe()
+	it := &QueuedEvdevIterator{Wrapped: &FileEvdevIterator{Source: r}}
+	go func() {
+		_, _ = w.Write(make([]byte, eventSize32))
+	}()
+	require.True(t, it.Next())
+	// The reader goroutine is now blocked reading the next event. The read
+	// may or may not have failed by the time the error is checked.
+	if err := it.Close(); err != nil {
+		require.Equal(t, io.ErrClosedPipe, err)
+	}
+	require.False(t, it.Next())
+}
+
+func TestContextEvdevIterator(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r, w := io.Pipe()
+	it := &ContextEvdevIterator{Wrapped: &FileEvdevIterator{Source: r}, Context: ctx}
+	go func() {
+		_, _ = w.Write(make([]byte, eventSize32))
+		cancel()
+	}()
+	require.True(t, it.Next())
+	// The second read is waiting for data that never comes until the context
+	// closes the pipe.
+	require.False(t, it.Next())
+	require.Equal(t, context.Canceled, it.Close())
+
+	it = &ContextEvdevIterator{
+		Wrapped: &FileEvdevIterator{Source: io.NopCloser(bytes.NewReader(nil))},
+		Context: context.Background(),
+	}
+	require.False(t, it.Next())
+	require.Equal(t, io.EOF, it.Close())
+}
+
 func TestSelectingEvdevIterator_Next(t *testing.T) {
 	type fields struct {
-		Selection []uint16
+		Selection []EventType
 	}
 	tests := []struct {
 		name     string
@@ -91,19 +299,19 @@ func TestSelectingEvdevIterator_Next(t *testing.T) {
 	}{
 		{
 			name:     "empty source",
-			fields:   fields{Selection: []uint16{0}},
+			fields:   fields{Selection: []EventType{0}},
 			source:   []EvdevEvent{},
 			expected: []EvdevEvent{},
 		},
 		{
 			name:     "full set",
-			fields:   fields{Selection: []uint16{0}},
+			fields:   fields{Selection: []EventType{0}},
 			source:   []EvdevEvent{{}, {}, {}},
 			expected: []EvdevEvent{{}, {}, {}},
 		},
 		{
 			name:     "partial set",
-			fields:   fields{Selection: []uint16{0}},
+			fields:   fields{Selection: []EventType{0}},
 			source:   []EvdevEvent{{Type: 1}, {Type: 1}, {Type: 0}},
 			expected: []EvdevEvent{{Type: 0}},
 		},
@@ -136,7 +344,7 @@ func TestSelectingEvdevIterator_Next(t *testing.T) {
 
 func TestFilteringEvdevIterator_Next(t *testing.T) {
 	type fields struct {
-		Filter []uint16
+		Filter []EventType
 	}
 	tests := []struct {
 		name     string
@@ -146,19 +354,19 @@ func TestFilteringEvdevIterator_Next(t *testing.T) {
 	}{
 		{
 			name:     "empty source",
-			fields:   fields{Filter: []uint16{0}},
+			fields:   fields{Filter: []EventType{0}},
 			source:   []EvdevEvent{},
 			expected: []EvdevEvent{},
 		},
 		{
 			name:     "all filtered",
-			fields:   fields{Filter: []uint16{0}},
+			fields:   fields{Filter: []EventType{0}},
 			source:   []EvdevEvent{{}, {}, {}},
 			expected: []EvdevEvent{},
 		},
 		{
 			name:     "partial filter",
-			fields:   fields{Filter: []uint16{0}},
+			fields:   fields{Filter: []EventType{0}},
 			source:   []EvdevEvent{{Type: 1}, {Type: 1}, {Type: 0}},
 			expected: []EvdevEvent{{Type: 1}, {Type: 1}},
 		},
@@ -188,3 +396,33 @@ func TestFilteringEvdevIterator_Next(t *testing.T) {
 		})
 	}
 }
+
+func TestPacedEvdevIterator_Next(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	start := time.Unix(100, 0)
+	source := []EvdevEvent{
+		{Time: start},
+		{Time: start.Add(10 * time.Millisecond)},
+		{Time: start.Add(10 * time.Millisecond)},
+		{Time: start.Add(25 * time.Millisecond)},
+	}
+	wrapped := NewMockEvdevIterator(ctrl)
+	for _, s := range source {
+		wrapped.EXPECT().Next().Return(true)
+		wrapped.EXPECT().Current().Return(s)
+	}
+	wrapped.EXPECT().Next().Return(false)
+	wrapped.EXPECT().Close().Return(nil)
+
+	var slept []time.Duration
+	it := &PacedEvdevIterator{
+		Wrapped: wrapped,
+		Sleep:   func(d time.Duration) { slept = append(slept, d) },
+	}
+	for it.Next() {
+	}
+	require.Nil(t, it.Close())
+	require.Equal(t, []time.Duration{10 * time.Millisecond, 15 * time.Millisecond}, slept)
+}
@@ -0,0 +1,143 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownEventType is returned when parsing a name that is not an evdev
+// event type.
+var ErrUnknownEventType = errors.New("unknown event type")
+
+// ErrUnknownEventCode is returned when parsing a name that is not a code of
+// the given event type.
+var ErrUnknownEventCode = errors.New("unknown event code")
+
+// EventType is one of the EV_* values that sets the kind of an evdev event.
+type EventType uint16
+
+// String returns the EV_* name of the type. Types without a name are written
+// in hex.
+func (t EventType) String() string {
+	if name, ok := EVMap[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02x", uint16(t))
+}
+
+// MarshalText writes the type as its name.
+func (t EventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText reads a type written by MarshalText or given as a number.
+func (t *EventType) UnmarshalText(text []byte) error {
+	parsed, err := ParseEventType(string(text))
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// ParseEventType converts an EV_* name, or a number, to an EventType.
+func ParseEventType(name string) (EventType, error) {
+	for etype, ename := range EVMap {
+		if ename == name {
+			return etype, nil
+		}
+	}
+	if n, err := strconv.ParseUint(name, 0, 16); err == nil {
+		return EventType(n), nil
+	}
+	return 0, fmt.Errorf("%w %s", ErrUnknownEventType, name)
+}
+
+// EventCode is a code combined with the type it belongs to. Codes only have a
+// meaning within a type. For example, 0x00 is ABS_X for EV_ABS but REL_X for
+// EV_REL.
+type EventCode struct {
+	Type EventType
+	Code uint16
+}
+
+// String returns the type and code names separated by a colon, such as
+// EV_ABS:ABS_X. Codes without a name are written in hex.
+func (c EventCode) String() string {
+	name := CodeString(c.Type, c.Code)
+	if name == "" {
+		name = fmt.Sprintf("0x%02x", c.Code)
+	}
+	return c.Type.String() + ":" + name
+}
+
+// MarshalText writes the code in the form returned by String.
+func (c EventCode) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText reads a code in the form returned by String. The type may be
+// left out, as in ABS_X, when the code name belongs to only one type.
+func (c *EventCode) UnmarshalText(text []byte) error {
+	s := string(text)
+	var parsed EventCode
+	var err error
+	if typeName, codeName, ok := strings.Cut(s, ":"); ok {
+		parsed, err = ParseCode(typeName, codeName)
+	} else {
+		parsed, err = parseCodeName(s)
+	}
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+// ParseCode converts a type and code name, such as EV_KEY and
+// BTN_TOOL_RUBBER, to an EventCode. Both names may also be numbers and all of
+// the aliases in the kernel headers are accepted.
+func ParseCode(typeName string, codeName string) (EventCode, error) {
+	etype, err := ParseEventType(typeName)
+	if err != nil {
+		return EventCode{}, err
+	}
+	if code, ok := eventCodeValues[etype][codeName]; ok {
+		return EventCode{Type: etype, Code: code}, nil
+	}
+	if n, err := strconv.ParseUint(codeName, 0, 16); err == nil {
+		return EventCode{Type: etype, Code: uint16(n)}, nil
+	}
+	return EventCode{}, fmt.Errorf("%w %s for %s", ErrUnknownEventCode, codeName, etype)
+}
+
+// parseCodeName finds the type of a code name that is given without one.
+func parseCodeName(codeName string) (EventCode, error) {
+	for etype, codes := range eventCodeValues {
+		if code, ok := codes[codeName]; ok {
+			return EventCode{Type: etype, Code: code}, nil
+		}
+	}
+	return EventCode{}, fmt.Errorf("%w %s", ErrUnknownEventCode, codeName)
+}
+
+// EventCode returns the type and code of the event.
+func (e EvdevEvent) EventCode() EventCode {
+	return EventCode{Type: e.Type, Code: e.Code}
+}
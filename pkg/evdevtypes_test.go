@@ -0,0 +1,81 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeString(t *testing.T) {
+	require.Equal(t, "ABS_PRESSURE", CodeString(EV_ABS, ABS_PRESSURE))
+	require.Equal(t, "KEY_ESC", CodeString(EV_KEY, KEY_ESC))
+	require.Equal(t, "BTN_TOOL_RUBBER", CodeString(EV_KEY, BTN_TOOL_RUBBER))
+	require.Equal(t, "", CodeString(EV_KEY, 0x2ff))
+}
+
+func TestEventTypeText(t *testing.T) {
+	require.Equal(t, "EV_ABS", EV_ABS.String())
+	require.Equal(t, "0x1e", EventType(0x1e).String())
+
+	var etype EventType
+	require.Nil(t, etype.UnmarshalText([]byte("EV_KEY")))
+	require.Equal(t, EV_KEY, etype)
+	require.Nil(t, etype.UnmarshalText([]byte("3")))
+	require.Equal(t, EV_ABS, etype)
+	err := etype.UnmarshalText([]byte("EV_PEN"))
+	require.True(t, errors.Is(err, ErrUnknownEventType))
+}
+
+func TestParseCode(t *testing.T) {
+	code, err := ParseCode("EV_KEY", "BTN_TOOL_RUBBER")
+	require.Nil(t, err)
+	require.Equal(t, EventCode{Type: EV_KEY, Code: BTN_TOOL_RUBBER}, code)
+	require.Equal(t, "EV_KEY:BTN_TOOL_RUBBER", code.String())
+
+	// Aliases are accepted but the preferred name is written.
+	code, err = ParseCode("EV_KEY", "BTN_DIGI")
+	require.Nil(t, err)
+	require.Equal(t, "EV_KEY:BTN_TOOL_PEN", code.String())
+
+	code, err = ParseCode("EV_ABS", "0x30")
+	require.Nil(t, err)
+	require.Equal(t, "EV_ABS:ABS_MT_TOUCH_MAJOR", code.String())
+
+	_, err = ParseCode("EV_ABS", "BTN_TOUCH")
+	require.True(t, errors.Is(err, ErrUnknownEventCode))
+	_, err = ParseCode("EV_PEN", "ABS_X")
+	require.True(t, errors.Is(err, ErrUnknownEventType))
+}
+
+func TestEventCodeText(t *testing.T) {
+	b, err := json.Marshal([]EventCode{{Type: EV_ABS, Code: ABS_X}, {Type: EV_KEY, Code: 0x2ff}})
+	require.Nil(t, err)
+	require.Equal(t, `["EV_ABS:ABS_X","EV_KEY:0x2ff"]`, string(b))
+
+	var codes []EventCode
+	require.Nil(t, json.Unmarshal([]byte(`["EV_ABS:ABS_X","EV_KEY:0x2ff","BTN_TOUCH"]`), &codes))
+	require.Equal(t, []EventCode{
+		{Type: EV_ABS, Code: ABS_X},
+		{Type: EV_KEY, Code: 0x2ff},
+		{Type: EV_KEY, Code: BTN_TOUCH},
+	}, codes)
+
+	err = json.Unmarshal([]byte(`["PEN_X"]`), &codes)
+	require.True(t, errors.Is(err, ErrUnknownEventCode))
+}
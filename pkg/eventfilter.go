@@ -0,0 +1,270 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidFilter is returned when a filter expression can not be parsed.
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// EventPredicate reports whether an event should be kept.
+type EventPredicate func(EvdevEvent) bool
+
+// EventFilter matches events on their type, code, and value. The zero value
+// matches every event. Filters are usually made with ParseEventFilter but may
+// also be built directly.
+type EventFilter struct {
+	// TypePattern is an EV_* name, such as EV_ABS, or a glob, such as EV_*,
+	// that the name of the event type must match. An empty pattern matches
+	// any type.
+	TypePattern string
+	// CodePattern is a code name, such as ABS_PRESSURE, or a glob, such as
+	// BTN_TOOL_*, that the name of the event code must match. An empty
+	// pattern matches any code.
+	CodePattern string
+	// Min and Max are the inclusive range of values to match. Use
+	// math.MinInt32 and math.MaxInt32 to leave either end open.
+	Min int32
+	Max int32
+	// HasRange must be true for Min and Max to be checked.
+	HasRange bool
+}
+
+// Match reports whether the event matches every part of the filter.
+func (f EventFilter) Match(evt EvdevEvent) bool {
+	if f.HasRange && (evt.Value < f.Min || evt.Value > f.Max) {
+		return false
+	}
+	if f.TypePattern != "" && !matchName(f.TypePattern, evt.Type.String(), uint16(evt.Type)) {
+		return false
+	}
+	if f.CodePattern != "" && !matchName(f.CodePattern, CodeString(evt.Type, evt.Code), evt.Code) {
+		return false
+	}
+	return true
+}
+
+// matchName matches a pattern against a name or, for patterns that are
+// numbers, against the number.
+func matchName(pattern string, name string, number uint16) bool {
+	if n, err := strconv.ParseUint(pattern, 0, 16); err == nil {
+		return uint16(n) == number
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// AnyFilter returns a predicate that keeps events that match at least one of
+// the filters.
+func AnyFilter(filters ...EventFilter) EventPredicate {
+	return func(evt EvdevEvent) bool {
+		for _, f := range filters {
+			if f.Match(evt) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ParseEventFilter reads a filter expression of the form TYPE:CODE followed
+// by an optional value comparison. Either of TYPE or CODE may be left out, and
+// the type of a code name is found automatically. Names may be globs. The
+// comparison is one of >, >=, <, <=, or = followed by a number, or = followed
+// by an inclusive range such as 100..500. For example:
+//
+//	EV_ABS:ABS_PRESSURE>500
+//	BTN_TOOL_*
+//	EV_KEY
+//	ABS_TILT_X=-20..20
+func ParseEventFilter(expr string) (EventFilter, error) {
+	f := EventFilter{}
+	selector := expr
+	if i := strings.IndexAny(expr, "<>="); i >= 0 {
+		selector = expr[:i]
+		if err := f.parseComparison(expr[i:]); err != nil {
+			return f, fmt.Errorf("%w %s: %w", ErrInvalidFilter, expr, err)
+		}
+	}
+	selector = strings.TrimSpace(selector)
+	typePattern, codePattern, hasCode := strings.Cut(selector, ":")
+	if !hasCode && !strings.HasPrefix(selector, "EV_") {
+		typePattern, codePattern = "", selector
+	}
+	f.TypePattern = strings.TrimSpace(typePattern)
+	f.CodePattern = strings.TrimSpace(codePattern)
+	if f.TypePattern == "" && f.CodePattern == "" && !f.HasRange {
+		return f, fmt.Errorf("%w %q: expected a type, a code, or a comparison", ErrInvalidFilter, expr)
+	}
+	if err := f.resolve(); err != nil {
+		return f, fmt.Errorf("%w %s: %w", ErrInvalidFilter, expr, err)
+	}
+	return f, nil
+}
+
+// ParseEventFilters parses each expression with ParseEventFilter.
+func ParseEventFilters(exprs []string) ([]EventFilter, error) {
+	filters := make([]EventFilter, 0, len(exprs))
+	for _, expr := range exprs {
+		f, err := ParseEventFilter(expr)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// resolve checks the patterns. Names that are not globs are converted to
+// numbers so that aliases such as BTN_DIGI match, and the type of a code name
+// is filled in when it is not given.
+func (f *EventFilter) resolve() error {
+	typeGlob := isGlob(f.TypePattern)
+	codeGlob := isGlob(f.CodePattern)
+	for _, p := range []string{f.TypePattern, f.CodePattern} {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	if f.TypePattern != "" && !typeGlob {
+		etype, err := ParseEventType(f.TypePattern)
+		if err != nil {
+			return err
+		}
+		f.TypePattern = strconv.Itoa(int(etype))
+	}
+	if f.CodePattern == "" || codeGlob {
+		return nil
+	}
+	if _, err := strconv.ParseUint(f.CodePattern, 0, 16); err == nil && (f.TypePattern == "" || typeGlob) {
+		// A code number means nothing without a type so there is nothing to
+		// check it against.
+		return nil
+	}
+	var code EventCode
+	var err error
+	switch {
+	case f.TypePattern != "" && !typeGlob:
+		code, err = ParseCode(f.TypePattern, f.CodePattern)
+	default:
+		code, err = parseCodeName(f.CodePattern)
+		if err == nil && f.TypePattern == "" {
+			f.TypePattern = strconv.Itoa(int(code.Type))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	f.CodePattern = strconv.Itoa(int(code.Code))
+	return nil
+}
+
+// parseComparison reads the operator and value of an expression.
+func (f *EventFilter) parseComparison(s string) error {
+	var op string
+	for _, candidate := range []string{">=", "<=", "==", ">", "<", "="} {
+		if strings.HasPrefix(s, candidate) {
+			op = candidate
+			break
+		}
+	}
+	value := strings.TrimSpace(s[len(op):])
+	f.HasRange = true
+	f.Min, f.Max = math.MinInt32, math.MaxInt32
+	if low, high, ok := strings.Cut(value, ".."); ok && (op == "=" || op == "==") {
+		lo, err := parseValue(low)
+		if err != nil {
+			return err
+		}
+		hi, err := parseValue(high)
+		if err != nil {
+			return err
+		}
+		if lo > hi {
+			return fmt.Errorf("range %s is empty", value)
+		}
+		f.Min, f.Max = int32(lo), int32(hi)
+		return nil
+	}
+	v, err := parseValue(value)
+	if err != nil {
+		return err
+	}
+	switch op {
+	case ">":
+		if v == math.MaxInt32 {
+			return fmt.Errorf("no value is greater than %d", v)
+		}
+		f.Min = int32(v + 1)
+	case ">=":
+		f.Min = int32(v)
+	case "<":
+		if v == math.MinInt32 {
+			return fmt.Errorf("no value is less than %d", v)
+		}
+		f.Max = int32(v - 1)
+	case "<=":
+		f.Max = int32(v)
+	default:
+		f.Min, f.Max = int32(v), int32(v)
+	}
+	return nil
+}
+
+func parseValue(s string) (int64, error) {
+	return strconv.ParseInt(strings.TrimSpace(s), 0, 32)
+}
+
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// MatchingEvdevIterator reduces an iterator output to the events that Match
+// keeps.
+type MatchingEvdevIterator struct {
+	Wrapped EvdevIterator
+	Match   EventPredicate
+	current EvdevEvent
+}
+
+// Next continually calls the wrapped Next() until it either returns a value
+// that matches or it returns a false.
+func (it *MatchingEvdevIterator) Next() bool {
+	for it.Wrapped.Next() {
+		c := it.Wrapped.Current()
+		if it.Match(c) {
+			it.current = c
+			return true
+		}
+	}
+	return false
+}
+
+// Current returns the active element.
+func (it *MatchingEvdevIterator) Current() EvdevEvent {
+	return it.current
+}
+
+// Close proxies to the wrapped instance.
+func (it *MatchingEvdevIterator) Close() error {
+	return it.Wrapped.Close()
+}
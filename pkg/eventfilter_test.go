@@ -0,0 +1,142 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEventFilter(t *testing.T) {
+	pressure := func(v int32) EvdevEvent {
+		return EvdevEvent{Type: EV_ABS, Code: ABS_PRESSURE, Value: v}
+	}
+	rubber := EvdevEvent{Type: EV_KEY, Code: BTN_TOOL_RUBBER, Value: 1}
+	pen := EvdevEvent{Type: EV_KEY, Code: BTN_TOOL_PEN, Value: 1}
+	x := EvdevEvent{Type: EV_ABS, Code: ABS_X, Value: -5}
+	tests := []struct {
+		expr    string
+		matches []EvdevEvent
+		misses  []EvdevEvent
+	}{
+		{
+			expr:    "EV_ABS:ABS_PRESSURE>500",
+			matches: []EvdevEvent{pressure(501), pressure(4000)},
+			misses:  []EvdevEvent{pressure(500), x, rubber},
+		},
+		{
+			expr:    "ABS_PRESSURE<=500",
+			matches: []EvdevEvent{pressure(500), pressure(0)},
+			misses:  []EvdevEvent{pressure(501), x},
+		},
+		{
+			expr:    "BTN_TOOL_*",
+			matches: []EvdevEvent{rubber, pen},
+			misses:  []EvdevEvent{x, pressure(1)},
+		},
+		{
+			expr:    "EV_KEY:BTN_DIGI",
+			matches: []EvdevEvent{pen},
+			misses:  []EvdevEvent{rubber},
+		},
+		{
+			expr:    "EV_ABS",
+			matches: []EvdevEvent{x, pressure(1)},
+			misses:  []EvdevEvent{rubber},
+		},
+		{
+			expr:    "EV_ABS:0x00=-10..10",
+			matches: []EvdevEvent{x},
+			misses:  []EvdevEvent{{Type: EV_ABS, Code: ABS_X, Value: 11}, {Type: EV_REL, Code: REL_X, Value: 1}},
+		},
+		{
+			expr:    "EV_*:*_X = 0x10..20",
+			matches: []EvdevEvent{{Type: EV_REL, Code: REL_X, Value: 16}},
+			misses:  []EvdevEvent{x},
+		},
+		{
+			expr:    "=1",
+			matches: []EvdevEvent{rubber, pressure(1)},
+			misses:  []EvdevEvent{x},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := ParseEventFilter(tt.expr)
+			require.Nil(t, err)
+			for _, evt := range tt.matches {
+				require.True(t, f.Match(evt), "%v", evt)
+			}
+			for _, evt := range tt.misses {
+				require.False(t, f.Match(evt), "%v", evt)
+			}
+		})
+	}
+
+	for _, expr := range []string{
+		"",
+		"EV_PEN",
+		"EV_ABS:ABS_PRESURE",
+		"EV_ABS:BTN_TOUCH",
+		"ABS_PRESSURE>",
+		"ABS_PRESSURE>high",
+		"ABS_PRESSURE=10..1",
+		"ABS_PRESSURE>10..20",
+		"ABS_[X",
+	} {
+		_, err := ParseEventFilter(expr)
+		require.True(t, errors.Is(err, ErrInvalidFilter), expr)
+	}
+}
+
+func TestEventFilterBuilder(t *testing.T) {
+	f := EventFilter{CodePattern: "ABS_TILT_?", Min: math.MinInt32, Max: 0, HasRange: true}
+	require.True(t, f.Match(EvdevEvent{Type: EV_ABS, Code: ABS_TILT_Y, Value: -3}))
+	require.False(t, f.Match(EvdevEvent{Type: EV_ABS, Code: ABS_TILT_Y, Value: 3}))
+	require.True(t, EventFilter{}.Match(EvdevEvent{Type: EV_LED}))
+}
+
+func TestMatchingEvdevIterator(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	filters, err := ParseEventFilters([]string{"BTN_TOUCH", "ABS_PRESSURE>500"})
+	require.Nil(t, err)
+	src := NewMockEvdevIterator(ctrl)
+	source := []EvdevEvent{
+		{Type: EV_ABS, Code: ABS_PRESSURE, Value: 100},
+		{Type: EV_KEY, Code: BTN_TOUCH, Value: 1},
+		{Type: EV_ABS, Code: ABS_X, Value: 700},
+		{Type: EV_ABS, Code: ABS_PRESSURE, Value: 700},
+	}
+	for _, evt := range source {
+		src.EXPECT().Next().Return(true)
+		src.EXPECT().Current().Return(evt)
+	}
+	src.EXPECT().Next().Return(false)
+	src.EXPECT().Close().Return(nil)
+
+	it := &MatchingEvdevIterator{Wrapped: src, Match: AnyFilter(filters...)}
+	var got []EvdevEvent
+	for it.Next() {
+		got = append(got, it.Current())
+	}
+	require.Nil(t, it.Close())
+	require.Equal(t, []EvdevEvent{source[1], source[3]}, got)
+}
@@ -0,0 +1,188 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ErrUnknownEventFormat is returned when an event sink is requested for a
+// format that is not one of the EventFormat* values.
+var ErrUnknownEventFormat = errors.New("unknown event format")
+
+const (
+	// EventFormatJSON writes each event as a line of JSON.
+	EventFormatJSON = "jsonl"
+	// EventFormatEvtest writes each event in the same format as the evtest
+	// tool.
+	EventFormatEvtest = "evtest"
+	// EventFormatTable writes a table for each SYN_REPORT frame that shows
+	// how much time passed since the last frame and how much each value
+	// changed.
+	EventFormatTable = "table"
+	// DefaultEventFormat is the format used by the debug command.
+	DefaultEventFormat = EventFormatJSON
+)
+
+// EventFormats are the names of all of the formats in the order they are
+// listed in help text.
+var EventFormats = []string{EventFormatJSON, EventFormatEvtest, EventFormatTable}
+
+// EventSink writes events in a human or machine readable format.
+type EventSink interface {
+	// WriteEvent writes, or holds on to, one event.
+	WriteEvent(evt EvdevEvent) error
+	// Flush writes any events that are being held.
+	Flush() error
+}
+
+// NewEventSink returns the sink for one of the EventFormat* values.
+func NewEventSink(format string, w io.Writer) (EventSink, error) {
+	switch format {
+	case EventFormatJSON:
+		return &jsonEventSink{enc: json.NewEncoder(w)}, nil
+	case EventFormatEvtest:
+		return &evtestEventSink{w: w}, nil
+	case EventFormatTable:
+		return &tableEventSink{w: w, last: make(map[EventCode]int32)}, nil
+	default:
+		return nil, fmt.Errorf("%w %s. Available formats are: %s", ErrUnknownEventFormat, format, strings.Join(EventFormats, ", "))
+	}
+}
+
+// jsonEvent is the line written by jsonEventSink.
+type jsonEvent struct {
+	Time          time.Time `json:"time"`
+	EventType     uint16    `json:"eventType"`
+	EventTypeName string    `json:"eventTypeName"`
+	EventCode     uint16    `json:"eventCode"`
+	EventCodeName string    `json:"eventCodeName"`
+	EventValue    int32     `json:"eventValue"`
+}
+
+type jsonEventSink struct {
+	enc *json.Encoder
+}
+
+func (s *jsonEventSink) WriteEvent(evt EvdevEvent) error {
+	return s.enc.Encode(jsonEvent{
+		Time:          evt.Time.UTC(),
+		EventType:     uint16(evt.Type),
+		EventTypeName: evt.Type.String(),
+		EventCode:     evt.Code,
+		EventCodeName: CodeString(evt.Type, evt.Code),
+		EventValue:    evt.Value,
+	})
+}
+
+func (s *jsonEventSink) Flush() error {
+	return nil
+}
+
+type evtestEventSink struct {
+	w io.Writer
+}
+
+// evtestTime formats a time as seconds and microseconds like evtest.
+func evtestTime(t time.Time) string {
+	return fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/int(time.Microsecond))
+}
+
+func (s *evtestEventSink) WriteEvent(evt EvdevEvent) error {
+	var err error
+	switch {
+	case evt.Type == EV_SYN:
+		name := CodeString(evt.Type, evt.Code)
+		if name == "" {
+			name = fmt.Sprintf("%d", evt.Code)
+		}
+		_, err = fmt.Fprintf(s.w, "Event: time %s, -------------- %s ------------\n", evtestTime(evt.Time), name)
+	case evt.Type == EV_MSC && (evt.Code == MSC_RAW || evt.Code == MSC_SCAN):
+		_, err = fmt.Fprintf(s.w, "Event: time %s, type %d (%s), code %d (%s), value %02x\n",
+			evtestTime(evt.Time), evt.Type, evt.Type, evt.Code, evtestCodeName(evt), evt.Value)
+	default:
+		_, err = fmt.Fprintf(s.w, "Event: time %s, type %d (%s), code %d (%s), value %d\n",
+			evtestTime(evt.Time), evt.Type, evt.Type, evt.Code, evtestCodeName(evt), evt.Value)
+	}
+	return err
+}
+
+func (s *evtestEventSink) Flush() error {
+	return nil
+}
+
+// evtestCodeName returns the code name or ? for codes without a name, which
+// is what evtest prints.
+func evtestCodeName(evt EvdevEvent) string {
+	if name := CodeString(evt.Type, evt.Code); name != "" {
+		return name
+	}
+	return "?"
+}
+
+// tableEventSink holds the events of a frame until its SYN_REPORT arrives.
+type tableEventSink struct {
+	w        io.Writer
+	frame    []EvdevEvent
+	frames   int
+	previous time.Time
+	last     map[EventCode]int32
+}
+
+func (s *tableEventSink) WriteEvent(evt EvdevEvent) error {
+	if evt.Type == EV_SYN && evt.Code == SYN_REPORT {
+		return s.writeFrame(evt.Time)
+	}
+	s.frame = append(s.frame, evt)
+	return nil
+}
+
+// Flush writes the events that arrived after the last SYN_REPORT.
+func (s *tableEventSink) Flush() error {
+	if len(s.frame) == 0 {
+		return nil
+	}
+	return s.writeFrame(s.frame[len(s.frame)-1].Time)
+}
+
+func (s *tableEventSink) writeFrame(at time.Time) error {
+	s.frames++
+	delta := "-"
+	if !s.previous.IsZero() {
+		delta = fmt.Sprintf("+%.3fms", float64(at.Sub(s.previous))/float64(time.Millisecond))
+	}
+	s.previous = at
+	if _, err := fmt.Fprintf(s.w, "frame %d  time %s  %s\n", s.frames, evtestTime(at), delta); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(s.w, 0, 0, 2, ' ', 0)
+	for _, evt := range s.frame {
+		code := EventCode{Type: evt.Type, Code: evt.Code}
+		change := ""
+		if last, ok := s.last[code]; ok {
+			change = fmt.Sprintf("%+d", int64(evt.Value)-int64(last))
+		}
+		s.last[code] = evt.Value
+		fmt.Fprintf(tw, "  %s\t%s\t%d\t%s\n", evt.Type, evtestCodeName(evt), evt.Value, change)
+	}
+	s.frame = s.frame[:0]
+	return tw.Flush()
+}
@@ -0,0 +1,86 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sinkEvents() []EvdevEvent {
+	start := time.Unix(1700000000, 123456000)
+	return []EvdevEvent{
+		{Time: start, Type: EV_ABS, Code: ABS_X, Value: 100},
+		{Time: start, Type: EV_ABS, Code: ABS_PRESSURE, Value: 0},
+		{Time: start, Type: EV_SYN, Code: SYN_REPORT},
+		{Time: start.Add(7500 * time.Microsecond), Type: EV_ABS, Code: ABS_X, Value: 90},
+		{Time: start.Add(7500 * time.Microsecond), Type: EV_MSC, Code: MSC_SCAN, Value: 0xd0042},
+		{Time: start.Add(7500 * time.Microsecond), Type: EV_SYN, Code: SYN_REPORT},
+		{Time: start.Add(9 * time.Millisecond), Type: EV_KEY, Code: BTN_TOUCH, Value: 1},
+	}
+}
+
+func writeSink(t *testing.T, format string) string {
+	var out bytes.Buffer
+	sink, err := NewEventSink(format, &out)
+	require.Nil(t, err)
+	for _, evt := range sinkEvents() {
+		require.Nil(t, sink.WriteEvent(evt))
+	}
+	require.Nil(t, sink.Flush())
+	return out.String()
+}
+
+func TestEventSinkJSON(t *testing.T) {
+	out := writeSink(t, EventFormatJSON)
+	lines := bytes.Split(bytes.TrimSpace([]byte(out)), []byte("\n"))
+	require.Len(t, lines, 7)
+	require.Equal(t,
+		`{"time":"2023-11-14T22:13:20.123456Z","eventType":3,"eventTypeName":"EV_ABS","eventCode":0,"eventCodeName":"ABS_X","eventValue":100}`,
+		string(lines[0]),
+	)
+}
+
+func TestEventSinkEvtest(t *testing.T) {
+	require.Equal(t, `Event: time 1700000000.123456, type 3 (EV_ABS), code 0 (ABS_X), value 100
+Event: time 1700000000.123456, type 3 (EV_ABS), code 24 (ABS_PRESSURE), value 0
+Event: time 1700000000.123456, -------------- SYN_REPORT ------------
+Event: time 1700000000.130956, type 3 (EV_ABS), code 0 (ABS_X), value 90
+Event: time 1700000000.130956, type 4 (EV_MSC), code 4 (MSC_SCAN), value d0042
+Event: time 1700000000.130956, -------------- SYN_REPORT ------------
+Event: time 1700000000.132456, type 1 (EV_KEY), code 330 (BTN_TOUCH), value 1
+`, writeSink(t, EventFormatEvtest))
+}
+
+func TestEventSinkTable(t *testing.T) {
+	require.Equal(t, `frame 1  time 1700000000.123456  -
+  EV_ABS  ABS_X         100  
+  EV_ABS  ABS_PRESSURE  0    
+frame 2  time 1700000000.130956  +7.500ms
+  EV_ABS  ABS_X     90      -10
+  EV_MSC  MSC_SCAN  852034  
+frame 3  time 1700000000.132456  +1.500ms
+  EV_KEY  BTN_TOUCH  1  
+`, writeSink(t, EventFormatTable))
+}
+
+func TestNewEventSinkUnknown(t *testing.T) {
+	_, err := NewEventSink("xml", &bytes.Buffer{})
+	require.True(t, errors.Is(err, ErrUnknownEventFormat))
+}
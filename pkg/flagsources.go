@@ -0,0 +1,114 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"fmt"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+const (
+	// FlagSourceDefault marks a flag that has its default value.
+	FlagSourceDefault = "default"
+	// FlagSourceCommandLine marks a flag that was given on the command line.
+	FlagSourceCommandLine = "command line"
+	// EnvPrefix is the prefix of the environment variables that set flags.
+	EnvPrefix = "REMOUSEABLE_"
+)
+
+// FlagSources records where the value of each flag came from. Values are
+// layered from the highest precedence source to the lowest and each layer
+// only sets flags that no earlier layer set.
+type FlagSources map[string]string
+
+// NewFlagSources records every flag that was set on the command line. It must
+// be called after the flag set is parsed and before any other layer is
+// applied.
+func NewFlagSources(fs *flag.FlagSet) FlagSources {
+	s := make(FlagSources)
+	fs.Visit(func(f *flag.Flag) {
+		s[f.Name] = FlagSourceCommandLine
+	})
+	return s
+}
+
+// Apply sets flags that are not already set and records the source of each.
+func (s FlagSources) Apply(fs *flag.FlagSet, values map[string][]string, source string) error {
+	if err := ApplyFlagValues(fs, values); err != nil {
+		return err
+	}
+	for name := range values {
+		if _, ok := s[name]; !ok {
+			s[name] = source
+		}
+	}
+	return nil
+}
+
+// ApplyEnv sets flags that are not already set from the matching environment
+// variables. See EnvVarName. Variables that are empty are ignored.
+func (s FlagSources) ApplyEnv(fs *flag.FlagSet, lookup func(string) (string, bool)) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		name := EnvVarName(f.Name)
+		v, ok := lookup(name)
+		if !ok || v == "" {
+			return
+		}
+		err = s.Apply(fs, map[string][]string{f.Name: {v}}, "environment variable "+name)
+	})
+	return err
+}
+
+// Source returns where the value of a flag came from.
+func (s FlagSources) Source(name string) string {
+	if source, ok := s[name]; ok {
+		return source
+	}
+	return FlagSourceDefault
+}
+
+// Describe returns a line for each flag that does not have its default value
+// that shows the value and where it came from. The values of hidden flags,
+// such as passwords, are masked.
+func (s FlagSources) Describe(fs *flag.FlagSet, hidden ...string) []string {
+	lines := make([]string, 0, len(s))
+	fs.VisitAll(func(f *flag.Flag) {
+		source, ok := s[f.Name]
+		if !ok {
+			return
+		}
+		value := f.Value.String()
+		for _, h := range hidden {
+			if h == f.Name {
+				value = "********"
+				break
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%s=%s (%s)", f.Name, value, source))
+	})
+	return lines
+}
+
+// EnvVarName returns the environment variable that sets a flag. For example,
+// ssh-password is set by REMOUSEABLE_SSH_PASSWORD.
+func EnvVarName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
@@ -0,0 +1,269 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+var (
+	// ErrHostKeyUnknown is returned when a tablet presents a host key that is
+	// not in any known_hosts file and the user did not choose to trust it.
+	ErrHostKeyUnknown = errors.New("tablet host key is not trusted")
+	// ErrHostKeyChanged is returned when a tablet presents a host key that
+	// does not match the one recorded for it and the user did not choose to
+	// replace it.
+	ErrHostKeyChanged = errors.New("tablet host key has changed")
+)
+
+const (
+	// HostKeyPolicyPrompt asks the user before trusting a new or changed key.
+	HostKeyPolicyPrompt = "prompt"
+	// HostKeyPolicyAcceptNew trusts keys for tablets that have never been seen
+	// before without asking. Changed keys are still rejected.
+	HostKeyPolicyAcceptNew = "accept-new"
+	// HostKeyPolicyStrict only accepts keys that are already known.
+	HostKeyPolicyStrict = "strict"
+)
+
+// DefaultHostKeyAlgorithms is the set of host key algorithms offered to the
+// tablet when there is no known key to prefer.
+var DefaultHostKeyAlgorithms = []string{
+	ssh.KeyAlgoECDSA256,
+	ssh.KeyAlgoECDSA384,
+	ssh.KeyAlgoECDSA521,
+	ssh.KeyAlgoED25519,
+	ssh.KeyAlgoRSASHA256,
+	ssh.KeyAlgoRSASHA512,
+	ssh.KeyAlgoRSA,
+}
+
+// HostKeyPrompt asks the user a yes or no question and returns the answer.
+type HostKeyPrompt func(question string) (bool, error)
+
+// HostKeyVerifier checks the host keys presented by tablets against a set of
+// known_hosts files.
+//
+// Keys that the user chooses to trust are written to TrustFile rather than to
+// any of the other Files so that remouseable never rewrites the user's own
+// known_hosts. TrustFile is always checked before the other Files which allows
+// it to override a stale key, such as after a tablet OS update rotated the
+// host keys.
+type HostKeyVerifier struct {
+	// Files are additional known_hosts files to check. Files that do not exist
+	// are skipped.
+	Files []string
+	// TrustFile is the known_hosts file that remouseable manages. It may be
+	// empty in which case no keys are ever written.
+	TrustFile string
+	// Policy is one of the HostKeyPolicy* values. The default is
+	// HostKeyPolicyPrompt.
+	Policy string
+	// Prompt is used to ask the user whether to trust a key. A nil Prompt
+	// rejects any key that would require one.
+	Prompt HostKeyPrompt
+}
+
+// Callback returns a function suitable for ssh.ClientConfig.HostKeyCallback.
+func (v *HostKeyVerifier) Callback() ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		db, err := v.load()
+		if err != nil {
+			return err
+		}
+		err = db(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if err == nil || !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) == 0 {
+			return v.unknown(hostname, key)
+		}
+		return v.changed(hostname, key, keyErr.Want)
+	}
+}
+
+// HostKeyAlgorithms returns the algorithms to offer when connecting to the
+// given address. The algorithms of any keys already known for the address are
+// listed first. Without this, the tablet may present a key type that was never
+// recorded and it would be reported as a changed key.
+func (v *HostKeyVerifier) HostKeyAlgorithms(address string) []string {
+	db, err := v.load()
+	if err != nil {
+		return DefaultHostKeyAlgorithms
+	}
+	var keyErr *knownhosts.KeyError
+	err = db(address, &net.TCPAddr{IP: net.IPv4zero}, probeKey{})
+	if !errors.As(err, &keyErr) || len(keyErr.Want) == 0 {
+		return DefaultHostKeyAlgorithms
+	}
+	algos := make([]string, 0, len(DefaultHostKeyAlgorithms))
+	for _, want := range keyErr.Want {
+		switch want.Key.Type() {
+		case ssh.KeyAlgoRSA:
+			algos = append(algos, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSA)
+		default:
+			algos = append(algos, want.Key.Type())
+		}
+	}
+	for _, algo := range DefaultHostKeyAlgorithms {
+		found := false
+		for _, existing := range algos {
+			if existing == algo {
+				found = true
+				break
+			}
+		}
+		if !found {
+			algos = append(algos, algo)
+		}
+	}
+	return algos
+}
+
+func (v *HostKeyVerifier) load() (ssh.HostKeyCallback, error) {
+	files := make([]string, 0, len(v.Files)+1)
+	for _, f := range append([]string{v.TrustFile}, v.Files...) {
+		if f == "" {
+			continue
+		}
+		if _, err := os.Stat(f); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return knownhosts.New(files...)
+}
+
+func (v *HostKeyVerifier) unknown(hostname string, key ssh.PublicKey) error {
+	fingerprint := ssh.FingerprintSHA256(key)
+	switch v.Policy {
+	case HostKeyPolicyStrict:
+		return fmt.Errorf("%w: %s presented %s key %s", ErrHostKeyUnknown, hostname, key.Type(), fingerprint)
+	case HostKeyPolicyAcceptNew:
+		return v.trust(hostname, key)
+	}
+	question := fmt.Sprintf(
+		"The authenticity of tablet %s can't be established.\n%s key fingerprint is %s.\nAre you sure you want to continue connecting?",
+		hostname, key.Type(), fingerprint,
+	)
+	ok, err := v.ask(question)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s presented %s key %s", ErrHostKeyUnknown, hostname, key.Type(), fingerprint)
+	}
+	return v.trust(hostname, key)
+}
+
+func (v *HostKeyVerifier) changed(hostname string, key ssh.PublicKey, want []knownhosts.KnownKey) error {
+	known := make([]string, 0, len(want))
+	for _, k := range want {
+		known = append(known, fmt.Sprintf("%s key %s from %s:%d", k.Key.Type(), ssh.FingerprintSHA256(k.Key), k.Filename, k.Line))
+	}
+	description := fmt.Sprintf(
+		"%s presented %s key %s but the recorded keys are: %s",
+		hostname, key.Type(), ssh.FingerprintSHA256(key), strings.Join(known, ", "),
+	)
+	if v.Policy == HostKeyPolicyStrict || v.Policy == HostKeyPolicyAcceptNew {
+		return fmt.Errorf("%w: %s", ErrHostKeyChanged, description)
+	}
+	question := fmt.Sprintf(
+		"WARNING: the host key for tablet %s has changed.\n%s.\n"+
+			"This is expected after a tablet OS update but it could also mean that another device is impersonating the tablet.\n"+
+			"Do you want to replace the recorded key?",
+		hostname, description,
+	)
+	ok, err := v.ask(question)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrHostKeyChanged, description)
+	}
+	return v.trust(hostname, key)
+}
+
+func (v *HostKeyVerifier) ask(question string) (bool, error) {
+	if v.Prompt == nil {
+		return false, nil
+	}
+	return v.Prompt(question)
+}
+
+// trust records the key as the only key for the host in the TrustFile.
+func (v *HostKeyVerifier) trust(hostname string, key ssh.PublicKey) error {
+	if v.TrustFile == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(v.TrustFile), 0o700); err != nil {
+		return err
+	}
+	host := knownhosts.Normalize(hostname)
+	lines := make([]string, 0)
+	existing, err := os.ReadFile(v.TrustFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	scanner := bufio.NewScanner(strings.NewReader(string(existing)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if knownHostsLineMatches(line, host, key.Type()) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, knownhosts.Line([]string{host}, key))
+	return os.WriteFile(v.TrustFile, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+}
+
+// knownHostsLineMatches reports whether a known_hosts line records a key of
+// the given type for exactly the given host.
+func knownHostsLineMatches(line string, host string, keyType string) bool {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "@") {
+		return false
+	}
+	if fields[1] != keyType {
+		return false
+	}
+	for _, h := range strings.Split(fields[0], ",") {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+// probeKey is a key that never matches anything. It is used to discover which
+// keys are known for a host.
+type probeKey struct{}
+
+func (probeKey) Type() string                        { return "remouseable-probe" }
+func (probeKey) Marshal() []byte                     { return []byte("remouseable-probe") }
+func (probeKey) Verify([]byte, *ssh.Signature) error { return errors.New("probe key cannot verify") }
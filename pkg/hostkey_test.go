@@ -0,0 +1,145 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newTestHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	key, err := ssh.NewPublicKey(pub)
+	require.Nil(t, err)
+	return key
+}
+
+var testTabletAddr = &net.TCPAddr{IP: net.ParseIP("10.11.99.1"), Port: 22}
+
+func TestHostKeyVerifierKnownKey(t *testing.T) {
+	dir := t.TempDir()
+	key := newTestHostKey(t)
+	known := filepath.Join(dir, "known_hosts")
+	require.Nil(t, os.WriteFile(known, []byte(knownhosts.Line([]string{"10.11.99.1"}, key)+"\n"), 0o600))
+
+	v := &HostKeyVerifier{Files: []string{known}, TrustFile: filepath.Join(dir, "missing")}
+	require.Nil(t, v.Callback()("10.11.99.1:22", testTabletAddr, key))
+}
+
+func TestHostKeyVerifierUnknownKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    string
+		answer    bool
+		wantErr   error
+		wantTrust bool
+	}{
+		{name: "prompt accepted", policy: HostKeyPolicyPrompt, answer: true, wantTrust: true},
+		{name: "prompt rejected", policy: HostKeyPolicyPrompt, answer: false, wantErr: ErrHostKeyUnknown},
+		{name: "accept new", policy: HostKeyPolicyAcceptNew, wantTrust: true},
+		{name: "strict", policy: HostKeyPolicyStrict, wantErr: ErrHostKeyUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			key := newTestHostKey(t)
+			trust := filepath.Join(dir, "remouseable", "known_hosts")
+			v := &HostKeyVerifier{
+				TrustFile: trust,
+				Policy:    tt.policy,
+				Prompt:    func(string) (bool, error) { return tt.answer, nil },
+			}
+			err := v.Callback()("10.11.99.1:22", testTabletAddr, key)
+			if tt.wantErr != nil {
+				require.True(t, errors.Is(err, tt.wantErr))
+			} else {
+				require.Nil(t, err)
+			}
+			_, statErr := os.Stat(trust)
+			require.Equal(t, tt.wantTrust, statErr == nil)
+			if tt.wantTrust {
+				// The trusted key must now pass without asking again.
+				v.Prompt = nil
+				v.Policy = HostKeyPolicyStrict
+				require.Nil(t, v.Callback()("10.11.99.1:22", testTabletAddr, key))
+			}
+		})
+	}
+}
+
+func TestHostKeyVerifierChangedKey(t *testing.T) {
+	dir := t.TempDir()
+	oldKey := newTestHostKey(t)
+	newKey := newTestHostKey(t)
+	known := filepath.Join(dir, "known_hosts")
+	require.Nil(t, os.WriteFile(known, []byte(knownhosts.Line([]string{"10.11.99.1"}, oldKey)+"\n"), 0o600))
+	trust := filepath.Join(dir, "trusted")
+
+	for _, policy := range []string{HostKeyPolicyAcceptNew, HostKeyPolicyStrict} {
+		v := &HostKeyVerifier{Files: []string{known}, TrustFile: trust, Policy: policy}
+		err := v.Callback()("10.11.99.1:22", testTabletAddr, newKey)
+		require.True(t, errors.Is(err, ErrHostKeyChanged), policy)
+	}
+
+	v := &HostKeyVerifier{
+		Files:     []string{known},
+		TrustFile: trust,
+		Prompt:    func(string) (bool, error) { return false, nil },
+	}
+	require.True(t, errors.Is(v.Callback()("10.11.99.1:22", testTabletAddr, newKey), ErrHostKeyChanged))
+
+	v.Prompt = func(string) (bool, error) { return true, nil }
+	require.Nil(t, v.Callback()("10.11.99.1:22", testTabletAddr, newKey))
+
+	// The replacement is recorded in the trust file and overrides the stale
+	// key from the user's own known_hosts.
+	v.Prompt = nil
+	require.Nil(t, v.Callback()("10.11.99.1:22", testTabletAddr, newKey))
+	original, err := os.ReadFile(known)
+	require.Nil(t, err)
+	require.Equal(t, knownhosts.Line([]string{"10.11.99.1"}, oldKey)+"\n", string(original))
+
+	// Replacing again must not accumulate stale lines in the trust file.
+	newerKey := newTestHostKey(t)
+	v.Prompt = func(string) (bool, error) { return true, nil }
+	require.Nil(t, v.Callback()("10.11.99.1:22", testTabletAddr, newerKey))
+	trusted, err := os.ReadFile(trust)
+	require.Nil(t, err)
+	require.Equal(t, knownhosts.Line([]string{"10.11.99.1"}, newerKey)+"\n", string(trusted))
+}
+
+func TestHostKeyVerifierHostKeyAlgorithms(t *testing.T) {
+	dir := t.TempDir()
+	key := newTestHostKey(t)
+	known := filepath.Join(dir, "known_hosts")
+	require.Nil(t, os.WriteFile(known, []byte(knownhosts.Line([]string{"10.11.99.1"}, key)+"\n"), 0o600))
+
+	v := &HostKeyVerifier{Files: []string{known}}
+	algos := v.HostKeyAlgorithms("10.11.99.1:22")
+	require.Equal(t, ssh.KeyAlgoED25519, algos[0])
+	require.ElementsMatch(t, DefaultHostKeyAlgorithms, algos)
+	require.Equal(t, DefaultHostKeyAlgorithms, v.HostKeyAlgorithms("192.168.1.2:22"))
+}
@@ -1,100 +1,175 @@
-// This file is part of remouseable.
-//
-// remouseable is free software: you can redistribute it and/or modify
-// it under the terms of the GNU General Public License version 3 as published
-// by the Free Software Foundation.
-//
-// remouseable is distributed in the hope that it will be useful,
-// but WITHOUT ANY WARRANTY; without even the implied warranty of
-// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
-// GNU General Public License for more details.
-//
-// You should have received a copy of the GNU General Public License
-// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
-
 // Package main generates a Go source file that contains a mapping of all
 // evdev codes by extracting them from the linux source code files.
+//
+// By default the codes are read from the snapshot of the Linux UAPI headers in
+// the linux directory so that the output is the same on every machine. The
+// kernel version of the snapshot is in linux/VERSION. To update the snapshot,
+// copy input.h and input-event-codes.h from include/uapi/linux of a kernel
+// release, or from /usr/include/linux after installing the kernel headers, and
+// update linux/VERSION to match.
 package main
 
 import (
 	"bufio"
-	"bytes"
+	"embed"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/dave/jennifer/jen"
 	flag "github.com/spf13/pflag"
-	sys "golang.org/x/sys/unix"
 )
 
-var sourceFiles = []string{
-	"/usr/include/linux/input.h",
-	"/usr/include/linux/input-event-codes.h",
+// snapshot is the vendored copy of the Linux headers.
+//
+//go:embed linux/VERSION linux/input.h linux/input-event-codes.h
+var snapshot embed.FS
+
+var snapshotFiles = []string{
+	"linux/input.h",
+	"linux/input-event-codes.h",
+}
+
+// pattern is adapted from github.com/gvalkov/golang-evdev. It matches plain
+// values, aliases of other codes, and the (*_MAX+1) form of the *_CNT codes.
+const pattern = `^#define\s+((?:INPUT_PROP|KEY|ABS|REL|SW|MSC|LED|BTN|REP|SND|ID|EV|BUS|SYN|FF|MT_TOOL)_\w+)\s+(\w+|\(\s*\w+\s*\+\s*\d+\s*\))`
+
+// skipMapNames are codes that share a value with a preferred name. Newer
+// duplicates that are not listed here lose to the name that comes first in
+// the headers.
+var skipMapNames = map[string]bool{
+	// BTN_TRIGGER is a duplicate of BTN_JOYSTICK
+	"BTN_TRIGGER": true,
+	// BTN_SOUTH is a duplicate of BTN_GAMEPAD
+	"BTN_SOUTH": true,
+	// BTN_DIGI is a duplicate of BTN_TOOL_PEN
+	"BTN_DIGI": true,
+	// BTN_WHEEL is a duplicate of BTN_GEAR_DOWN
+	"BTN_WHEEL": true,
+	// BTN_TRIGGER_HAPPY is a duplicate of BTN_TRIGGER_HAPPY1
+	"BTN_TRIGGER_HAPPY": true,
+	// BTN_MISC is a duplicate of BTN_0
+	"BTN_MISC": true,
+	// BTN_MOUSE is a duplicate of BTN_LEFT
+	"BTN_MOUSE": true,
 }
 
-// pattern is copied from github.com/gvalkov/golang-evdev
-const pattern = `#define +((?:KEY|ABS|REL|SW|MSC|LED|BTN|REP|SND|ID|EV|BUS|SYN|FF)_\w+)\s+(\w+)`
+// eventTypes maps the prefix of the code names to the name of the event type
+// that the codes are used with. Prefixes that are not listed, such as ID and
+// BUS, are not event codes.
+var eventTypes = map[string]string{
+	"SYN": "EV_SYN",
+	"KEY": "EV_KEY",
+	"BTN": "EV_KEY",
+	"REL": "EV_REL",
+	"ABS": "EV_ABS",
+	"MSC": "EV_MSC",
+	"SW":  "EV_SW",
+	"LED": "EV_LED",
+	"SND": "EV_SND",
+	"REP": "EV_REP",
+	"FF":  "EV_FF",
+}
+
+// eventTypeOrder is the order in which the event types are written so that
+// the output is reproducible.
+var eventTypeOrder = []string{
+	"EV_SYN", "EV_KEY", "EV_REL", "EV_ABS", "EV_MSC", "EV_SW", "EV_LED", "EV_SND", "EV_REP", "EV_FF",
+}
+
+// source is a header file to read codes from.
+type source struct {
+	name   string
+	reader io.Reader
+}
 
 func main() {
 	fs := flag.NewFlagSet("gencodes", flag.ExitOnError)
-	srcs := fs.StringArray("sources", sourceFiles, "Linux header source files to process.")
+	srcs := fs.StringArray("sources", nil, "Linux header source files to process instead of the vendored snapshot.")
+	version := fs.String("kernel-version", "", "The kernel version of the files given with --sources. It is recorded in the output.")
 	dst := fs.String("destination", "evdevcodes.go", "The destination file path. Use - for stdout.")
 	_ = fs.Parse(os.Args[1:])
 
-	reg, err := regexp.Compile(pattern)
+	sources := make([]source, 0, len(snapshotFiles))
+	if len(*srcs) == 0 {
+		b, err := snapshot.ReadFile("linux/VERSION")
+		if err != nil {
+			panic(err)
+		}
+		*version = strings.TrimSpace(string(b))
+		for _, name := range snapshotFiles {
+			f, err := snapshot.Open(name)
+			if err != nil {
+				panic(err)
+			}
+			defer f.Close()
+			sources = append(sources, source{name: filepath.Base(name), reader: f})
+		}
+	} else {
+		if *version == "" {
+			panic("--kernel-version is required with --sources")
+		}
+		for _, name := range *srcs {
+			f, err := os.Open(name)
+			if err != nil {
+				panic(err)
+			}
+			defer f.Close()
+			sources = append(sources, source{name: filepath.Base(name), reader: f})
+		}
+	}
+
+	f, err := generate(sources, *version)
 	if err != nil {
 		panic(err)
 	}
-
-	codes := make([][]string, 0)
-	for _, src := range *srcs {
-		f, fErr := os.Open(src)
-		if fErr != nil {
-			panic(fErr)
+	if *dst == "-" {
+		if err = f.Render(os.Stdout); err != nil {
+			panic(err)
 		}
-		defer f.Close()
+		return
+	}
+	if err = f.Save(*dst); err != nil {
+		panic(err)
+	}
+}
+
+// generate builds the output file. It depends only on the sources and the
+// version so that the output is reproducible.
+func generate(sources []source, version string) (*jen.File, error) {
+	reg, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
 
-		bf := bufio.NewScanner(f)
+	codes := make([][]string, 0)
+	names := make([]string, 0, len(sources))
+	for _, src := range sources {
+		names = append(names, src.name)
+		bf := bufio.NewScanner(src.reader)
 		for bf.Scan() {
 			line := bf.Text()
 			matches := reg.FindAllStringSubmatch(line, -1)
 			for _, match := range matches {
-				codes = append(codes, match[1:])
+				codes = append(codes, []string{match[1], goValue(match[2])})
 			}
 		}
 		if bf.Err() != nil {
-			panic(bf.Err())
+			return nil, fmt.Errorf("%s: %w", src.name, bf.Err())
 		}
 	}
 
-	un := &sys.Utsname{}
-	if err = sys.Uname(un); err != nil {
-		panic(err)
-	}
-
 	f := jen.NewFile("remouseable")
 	f.Comment("// Code generated DO NOT EDIT").Line()
 	f.Comment(
 		fmt.Sprintf(
-			"// Generated using %s %s %s.",
-			string(bytes.Trim(un.Sysname[:], "\x00")),
-			string(bytes.Trim(un.Release[:], "\x00")),
-			string(bytes.Trim(un.Machine[:], "\x00")),
-		),
-	)
-	f.Comment(
-		fmt.Sprintf(
-			"// Generated at %s.",
-			time.Now().Format(time.RFC3339),
-		),
-	)
-	f.Comment(
-		fmt.Sprintf(
-			"// Generated from %s.",
-			strings.Join(*srcs, ", "),
+			"// Generated from the Linux %s headers %s.",
+			version,
+			strings.Join(names, ", "),
 		),
 	)
 
@@ -112,28 +187,55 @@ func main() {
 	busMaps := make([]jen.Code, 0)
 	synMaps := make([]jen.Code, 0)
 	ffMaps := make([]jen.Code, 0)
+	propMaps := make([]jen.Code, 0)
+	mtToolMaps := make([]jen.Code, 0)
 	defs := make([]jen.Code, 0, len(codes))
+	values := make(map[string][]jen.Code)
+	seen := make(map[string]bool)
 	for _, code := range codes {
 		name := code[0]
 		value := code[1]
-		defs = append(defs, jen.Id(name).Op("=").Id(value))
+		if strings.HasPrefix(name, "EV_") && name != "EV_VERSION" {
+			defs = append(defs, jen.Id(name).Id("EventType").Op("=").Id(value))
+		} else {
+			defs = append(defs, jen.Id(name).Op("=").Id(value))
+		}
 
-		if name == "EV_VERSION" || strings.HasSuffix(name, "_MAX") {
+		if name == "EV_VERSION" || strings.HasSuffix(name, "_MAX") || strings.HasSuffix(name, "_CNT") {
 			// EV_VERSION is not a uint16 value and is also not an event type.
 			// *_MAX are often duplicated by other named values.
+			// *_CNT are sizes rather than codes.
+			continue
+		}
+		prefix := name[:strings.Index(name, "_")]
+		if strings.HasPrefix(name, "INPUT_PROP_") {
+			prefix = "INPUT_PROP"
+		} else if strings.HasPrefix(name, "MT_TOOL_") {
+			prefix = "MT_TOOL"
+		}
+		if etype, ok := eventTypes[prefix]; ok {
+			// Every name, including aliases and duplicates, is accepted when
+			// parsing.
+			values[etype] = append(values[etype], jen.Lit(name).Op(":").Id(name))
+		}
+		if skipMapNames[name] {
 			continue
 		}
-		if name == "BTN_TRIGGER" || name == "BTN_SOUTH" || name == "BTN_DIGI" || name == "BTN_WHEEL" || name == "BTN_TRIGGER_HAPPY" || name == "BTN_MISC" || name == "BTN_MOUSE" {
-			// BTN_TRIGGER is a duplicate of BTN_TASK
-			// BTN_SOUTH is a duplicate of BTN_GAMEPAD
-			// BTN_DIGI is a duplicate of BTN_TOOL_PEN
-			// BTN_WHEEL is a duplicate of BTN_GEAR_DOWN
-			// BTN_TRIGGER_HAPPY is a duplicate of BTN_TRIGGER_HAPPY1
-			// BTN_MISC is a duplicate of BTN_0
-			// BTN_MOUSE is a duplicate of BTN_LEFT
+		number, parseErr := strconv.ParseUint(value, 0, 16)
+		if parseErr != nil {
+			// Aliases of other names are not added to the maps.
 			continue
 		}
+		key := fmt.Sprintf("%s:%d", prefix, number)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
 		switch {
+		case strings.HasPrefix(name, "INPUT_PROP_"):
+			propMaps = append(propMaps, jen.Id(value).Op(":").Lit(name))
+		case strings.HasPrefix(name, "MT_TOOL_"):
+			mtToolMaps = append(mtToolMaps, jen.Id(value).Op(":").Lit(name))
 		case strings.HasPrefix(name, "KEY") && !strings.HasPrefix(value, "KEY"):
 			keyMaps = append(keyMaps, jen.Id(value).Op(":").Lit(name))
 		case strings.HasPrefix(name, "ABS") && !strings.HasPrefix(value, "ABS"):
@@ -175,42 +277,51 @@ func main() {
 	f.Var().Id("REPMap").Op("=").Map(jen.Uint16()).String().Values(repMaps...)
 	f.Var().Id("SNDMap").Op("=").Map(jen.Uint16()).String().Values(sndMaps...)
 	f.Var().Id("IDMap").Op("=").Map(jen.Uint16()).String().Values(idMaps...)
-	f.Var().Id("EVMap").Op("=").Map(jen.Uint16()).String().Values(evMaps...)
+	f.Var().Id("EVMap").Op("=").Map(jen.Id("EventType")).String().Values(evMaps...)
 	f.Var().Id("BUSMap").Op("=").Map(jen.Uint16()).String().Values(busMaps...)
 	f.Var().Id("SYNMap").Op("=").Map(jen.Uint16()).String().Values(synMaps...)
 	f.Var().Id("FFMap").Op("=").Map(jen.Uint16()).String().Values(ffMaps...)
+	f.Var().Id("PROPMap").Op("=").Map(jen.Uint16()).String().Values(propMaps...)
+	f.Var().Id("MTToolMap").Op("=").Map(jen.Uint16()).String().Values(mtToolMaps...)
 
-	f.Func().Id("CodeString").Params(jen.Id("etype").Uint16(), jen.Id("code").Uint16()).String().Block(
-		jen.Var().Id("stype").Op("=").Id("EVMap").Index(jen.Id("etype")),
-		jen.Switch(jen.Id("stype")).Block(
-			jen.Case(jen.Lit("EV_SYN")).Block(
+	f.Comment("CodeString returns the name of a code for the given event type. It")
+	f.Comment("returns an empty string if the code has no name.")
+	f.Func().Id("CodeString").Params(jen.Id("etype").Id("EventType"), jen.Id("code").Uint16()).String().Block(
+		jen.Switch(jen.Id("etype")).Block(
+			jen.Case(jen.Id("EV_SYN")).Block(
 				jen.Return(jen.Id("SYNMap").Index(jen.Id("code"))),
 			),
-			jen.Case(jen.Lit("EV_KEY")).Block(
-				jen.Return(jen.Id("KEYMap").Index(jen.Id("code"))),
+			jen.Case(jen.Id("EV_KEY")).Block(
+				jen.If(
+					jen.List(jen.Id("name"), jen.Id("ok")).Op(":=").Id("KEYMap").Index(jen.Id("code")),
+					jen.Id("ok"),
+				).Block(
+					jen.Return(jen.Id("name")),
+				),
+				jen.Return(jen.Id("BTNMap").Index(jen.Id("code"))),
 			),
-			jen.Case(jen.Lit("EV_ABS")).Block(
+			jen.Case(jen.Id("EV_ABS")).Block(
 				jen.Return(jen.Id("ABSMap").Index(jen.Id("code"))),
 			),
-			jen.Case(jen.Lit("EV_REL")).Block(
+			jen.Case(jen.Id("EV_REL")).Block(
 				jen.Return(jen.Id("RELMap").Index(jen.Id("code"))),
 			),
-			jen.Case(jen.Lit("EV_SW")).Block(
+			jen.Case(jen.Id("EV_SW")).Block(
 				jen.Return(jen.Id("SWMap").Index(jen.Id("code"))),
 			),
-			jen.Case(jen.Lit("EV_MSC")).Block(
+			jen.Case(jen.Id("EV_MSC")).Block(
 				jen.Return(jen.Id("MSCMap").Index(jen.Id("code"))),
 			),
-			jen.Case(jen.Lit("EV_LED")).Block(
+			jen.Case(jen.Id("EV_LED")).Block(
 				jen.Return(jen.Id("LEDMap").Index(jen.Id("code"))),
 			),
-			jen.Case(jen.Lit("EV_SND")).Block(
+			jen.Case(jen.Id("EV_SND")).Block(
 				jen.Return(jen.Id("SNDMap").Index(jen.Id("code"))),
 			),
-			jen.Case(jen.Lit("EV_REP")).Block(
+			jen.Case(jen.Id("EV_REP")).Block(
 				jen.Return(jen.Id("REPMap").Index(jen.Id("code"))),
 			),
-			jen.Case(jen.Lit("EV_FF")).Block(
+			jen.Case(jen.Id("EV_FF")).Block(
 				jen.Return(jen.Id("FFMap").Index(jen.Id("code"))),
 			),
 			jen.Default().Block(
@@ -219,13 +330,24 @@ func main() {
 		),
 	)
 
-	if *dst == "-" {
-		if err = f.Render(os.Stdout); err != nil {
-			panic(err)
-		}
-		return
+	valueMaps := make([]jen.Code, 0, len(eventTypeOrder))
+	for _, etype := range eventTypeOrder {
+		valueMaps = append(valueMaps, jen.Id(etype).Op(":").Values(values[etype]...))
 	}
-	if err = f.Save(*dst); err != nil {
-		panic(err)
+	f.Comment("eventCodeValues maps each event type to the names of its codes, including")
+	f.Comment("aliases, and their values. It is used to parse names.")
+	f.Var().Id("eventCodeValues").Op("=").Map(jen.Id("EventType")).Map(jen.String()).Uint16().Values(valueMaps...)
+
+	return f, nil
+}
+
+// goValue converts a header value to a Go expression. (ABS_MAX+1) becomes
+// ABS_MAX + 1.
+func goValue(value string) string {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "("), ")")
+	parts := strings.Split(value, "+")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
 	}
+	return strings.Join(parts, " + ")
 }
@@ -0,0 +1,68 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	render := func() string {
+		src := []source{{name: "input-event-codes.h", reader: strings.NewReader(`
+#define INPUT_PROP_DIRECT		0x01	/* direct input devices */
+#define INPUT_PROP_MAX			0x1f
+#define INPUT_PROP_CNT			(INPUT_PROP_MAX + 1)
+#define EV_VERSION		0x010001
+#define EV_KEY			0x01
+#define EV_ABS			0x03
+#define EV_MAX			0x1f
+#define EV_CNT			(EV_MAX+1)
+#define ABS_PROFILE		0x21
+#define KEY_ALL_APPLICATIONS	204
+#define KEY_DASHBOARD		KEY_ALL_APPLICATIONS
+#define BTN_GAMEPAD		0x130
+#define BTN_SOUTH		0x130
+#define MT_TOOL_PEN		0x01
+`)}}
+		f, err := generate(src, "1.2.3")
+		require.Nil(t, err)
+		b := &bytes.Buffer{}
+		require.Nil(t, f.Render(b))
+		return b.String()
+	}
+	out := render()
+	require.Equal(t, out, render())
+	require.Contains(t, out, "Generated from the Linux 1.2.3 headers input-event-codes.h.")
+	for _, want := range []string{
+		"INPUT_PROP_CNT                 = INPUT_PROP_MAX + 1",
+		"EV_VERSION                     = 0x010001",
+		"EV_ABS               EventType = 0x03",
+		"EV_CNT               EventType = EV_MAX + 1",
+		`EVMap = map[EventType]string{0x01: "EV_KEY", 0x03: "EV_ABS"}`,
+		`PROPMap = map[uint16]string{0x01: "INPUT_PROP_DIRECT"}`,
+		`ABSMap = map[uint16]string{0x21: "ABS_PROFILE"}`,
+		`KEYMap = map[uint16]string{204: "KEY_ALL_APPLICATIONS"}`,
+		`BTNMap = map[uint16]string{0x130: "BTN_GAMEPAD"}`,
+		`MTToolMap = map[uint16]string{0x01: "MT_TOOL_PEN"}`,
+		`EV_KEY: {"KEY_ALL_APPLICATIONS": KEY_ALL_APPLICATIONS, "KEY_DASHBOARD": KEY_DASHBOARD, "BTN_GAMEPAD": BTN_GAMEPAD, "BTN_SOUTH": BTN_SOUTH}`,
+		"return BTNMap[code]",
+	} {
+		require.Contains(t, out, want)
+	}
+}
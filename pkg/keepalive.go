@@ -0,0 +1,152 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrTabletUnreachable is returned when the connection to the tablet stops
+// responding. This usually means the tablet went to sleep, lost its network
+// connection, or was unplugged.
+var ErrTabletUnreachable = errors.New("tablet is unreachable")
+
+// KeepaliveConn is the portion of an SSH connection that is needed to send
+// keepalive requests. The *ssh.Client type satisfies this interface.
+type KeepaliveConn interface {
+	SendRequest(name string, wantReply bool, payload []byte) (bool, []byte, error)
+	Close() error
+}
+
+// keepaliveRequest is the request name used by OpenSSH for keepalives. Servers
+// that do not recognize the request still reply with a failure which is enough
+// to prove the connection is alive.
+const keepaliveRequest = "keepalive@openssh.com"
+
+// KeepaliveReadCloser wraps a source of data that is streamed over an SSH
+// connection and periodically checks that the connection is still alive.
+//
+// A half-open TCP connection never produces an error on its own so a read from
+// it blocks forever. To detect this, a keepalive request is sent to the remote
+// end every Interval. If neither data nor a keepalive reply has been seen for
+// longer than Timeout then the connection is closed, which unblocks any pending
+// read, and all reads return ErrTabletUnreachable from then on. A zero
+// Interval or Timeout disables the checks.
+type KeepaliveReadCloser struct {
+	Source   io.ReadCloser
+	Conn     KeepaliveConn
+	Interval time.Duration
+	Timeout  time.Duration
+	once     sync.Once
+	done     chan struct{}
+	lock     sync.Mutex
+	lastSeen time.Time
+	pending  bool
+	dead     bool
+	closed   bool
+}
+
+// Read from the source and record the activity.
+func (r *KeepaliveReadCloser) Read(b []byte) (int, error) {
+	r.once.Do(r.start)
+	n, err := r.Source.Read(b)
+	if n > 0 {
+		r.touch()
+	}
+	if err != nil && r.isDead() {
+		return n, fmt.Errorf("%w: %v", ErrTabletUnreachable, err)
+	}
+	return n, err
+}
+
+// Close stops the keepalive checks and closes the source.
+func (r *KeepaliveReadCloser) Close() error {
+	r.once.Do(r.start)
+	r.lock.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.done)
+	}
+	r.lock.Unlock()
+	return r.Source.Close()
+}
+
+func (r *KeepaliveReadCloser) start() {
+	r.done = make(chan struct{})
+	r.lastSeen = time.Now()
+	if r.Interval <= 0 || r.Timeout <= 0 {
+		return
+	}
+	go r.loop()
+}
+
+func (r *KeepaliveReadCloser) loop() {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		}
+		r.lock.Lock()
+		expired := time.Since(r.lastSeen) > r.Timeout
+		if expired {
+			r.dead = true
+		}
+		send := !expired && !r.pending
+		if send {
+			r.pending = true
+		}
+		r.lock.Unlock()
+		if expired {
+			// Closing the connection is the only way to unblock a read that is
+			// waiting on a peer that will never answer.
+			_ = r.Conn.Close()
+			return
+		}
+		if send {
+			go r.ping()
+		}
+	}
+}
+
+// ping sends a single keepalive request. The request blocks until the remote
+// replies so it runs separately from the loop that enforces the timeout.
+func (r *KeepaliveReadCloser) ping() {
+	_, _, err := r.Conn.SendRequest(keepaliveRequest, true, nil)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.pending = false
+	if err == nil {
+		r.lastSeen = time.Now()
+	}
+}
+
+func (r *KeepaliveReadCloser) touch() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.lastSeen = time.Now()
+}
+
+func (r *KeepaliveReadCloser) isDead() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.dead
+}
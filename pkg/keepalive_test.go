@@ -0,0 +1,111 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKeepaliveConn replies to keepalives only when responsive is set. An
+// unresponsive connection blocks requests until it is closed, just like a
+// half-open TCP connection would.
+type fakeKeepaliveConn struct {
+	responsive bool
+	closed     chan struct{}
+	once       sync.Once
+	onClose    func()
+}
+
+func newFakeKeepaliveConn(responsive bool, onClose func()) *fakeKeepaliveConn {
+	return &fakeKeepaliveConn{
+		responsive: responsive,
+		closed:     make(chan struct{}),
+		onClose:    onClose,
+	}
+}
+
+func (c *fakeKeepaliveConn) SendRequest(string, bool, []byte) (bool, []byte, error) {
+	if c.responsive {
+		return false, nil, nil
+	}
+	<-c.closed
+	return false, nil, io.EOF
+}
+
+func (c *fakeKeepaliveConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+		c.onClose()
+	})
+	return nil
+}
+
+func TestKeepaliveReadCloserDetectsDeadConnection(t *testing.T) {
+	pr, pw := io.Pipe()
+	conn := newFakeKeepaliveConn(false, func() { _ = pw.CloseWithError(io.EOF) })
+	r := &KeepaliveReadCloser{
+		Source:   pr,
+		Conn:     conn,
+		Interval: 5 * time.Millisecond,
+		Timeout:  20 * time.Millisecond,
+	}
+	_, err := r.Read(make([]byte, 16))
+	require.True(t, errors.Is(err, ErrTabletUnreachable))
+	require.Nil(t, r.Close())
+}
+
+func TestKeepaliveReadCloserResponsiveConnection(t *testing.T) {
+	pr, pw := io.Pipe()
+	conn := newFakeKeepaliveConn(true, func() {})
+	r := &KeepaliveReadCloser{
+		Source:   pr,
+		Conn:     conn,
+		Interval: 5 * time.Millisecond,
+		Timeout:  20 * time.Millisecond,
+	}
+	go func() {
+		time.Sleep(60 * time.Millisecond)
+		_, _ = pw.Write([]byte{1, 2, 3})
+		_ = pw.Close()
+	}()
+	n, err := r.Read(make([]byte, 16))
+	require.Nil(t, err)
+	require.Equal(t, 3, n)
+	_, err = r.Read(make([]byte, 16))
+	require.Equal(t, io.EOF, err)
+	require.Nil(t, r.Close())
+}
+
+func TestKeepaliveReadCloserDisabled(t *testing.T) {
+	pr, pw := io.Pipe()
+	conn := newFakeKeepaliveConn(false, func() {})
+	r := &KeepaliveReadCloser{
+		Source: pr,
+		Conn:   conn,
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = pw.Close()
+	}()
+	_, err := r.Read(make([]byte, 16))
+	require.Equal(t, io.EOF, err)
+	require.Nil(t, r.Close())
+}
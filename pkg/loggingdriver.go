@@ -0,0 +1,142 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+const (
+	// DefaultScreenWidth is the screen width reported by drivers that are not
+	// attached to a screen when no size is given.
+	DefaultScreenWidth = 1920
+	// DefaultScreenHeight is the screen height reported by drivers that are
+	// not attached to a screen when no size is given.
+	DefaultScreenHeight = 1080
+)
+
+func init() {
+	RegisterDriver(DriverLog, func(opts DriverOptions) (Driver, error) {
+		return &LoggingDriver{Output: opts.Output, Width: opts.ScreenWidth, Height: opts.ScreenHeight}, nil
+	})
+	RegisterDriver(DriverNull, func(opts DriverOptions) (Driver, error) {
+		return &NullDriver{Width: opts.ScreenWidth, Height: opts.ScreenHeight}, nil
+	})
+}
+
+// driverAction is a line of LoggingDriver output.
+type driverAction struct {
+	Action string   `json:"action"`
+	X      *float64 `json:"x,omitempty"`
+	Y      *float64 `json:"y,omitempty"`
+}
+
+// LoggingDriver implements Driver by writing each action as a line of JSON
+// rather than controlling the host. For example:
+//
+//	{"action":"move","x":960.5,"y":540}
+//	{"action":"click"}
+//
+// It needs no display so it is useful for testing and for running on a
+// headless machine.
+type LoggingDriver struct {
+	Output io.Writer
+	// Width and Height are the screen size to report. DefaultScreenWidth and
+	// DefaultScreenHeight are used if they are zero.
+	Width  int
+	Height int
+	lock   sync.Mutex
+}
+
+// GetSize returns the configured screen size.
+func (d *LoggingDriver) GetSize() (int, int, error) {
+	return screenSizeOrDefault(d.Width, d.Height)
+}
+
+// MoveMouse logs a move.
+func (d *LoggingDriver) MoveMouse(x float64, y float64) error {
+	return d.write(driverAction{Action: "move", X: &x, Y: &y})
+}
+
+// DragMouse logs a drag.
+func (d *LoggingDriver) DragMouse(x float64, y float64) error {
+	return d.write(driverAction{Action: "drag", X: &x, Y: &y})
+}
+
+// Click logs a button press.
+func (d *LoggingDriver) Click() error {
+	return d.write(driverAction{Action: "click"})
+}
+
+// Unclick logs a button release.
+func (d *LoggingDriver) Unclick() error {
+	return d.write(driverAction{Action: "unclick"})
+}
+
+func (d *LoggingDriver) write(a driverAction) error {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	_, err = d.Output.Write(append(b, '\n'))
+	return err
+}
+
+// NullDriver implements Driver by doing nothing.
+type NullDriver struct {
+	// Width and Height are the screen size to report. DefaultScreenWidth and
+	// DefaultScreenHeight are used if they are zero.
+	Width  int
+	Height int
+}
+
+// GetSize returns the configured screen size.
+func (d *NullDriver) GetSize() (int, int, error) {
+	return screenSizeOrDefault(d.Width, d.Height)
+}
+
+// MoveMouse does nothing.
+func (*NullDriver) MoveMouse(x float64, y float64) error {
+	return nil
+}
+
+// DragMouse does nothing.
+func (*NullDriver) DragMouse(x float64, y float64) error {
+	return nil
+}
+
+// Click does nothing.
+func (*NullDriver) Click() error {
+	return nil
+}
+
+// Unclick does nothing.
+func (*NullDriver) Unclick() error {
+	return nil
+}
+
+func screenSizeOrDefault(width int, height int) (int, int, error) {
+	if width <= 0 {
+		width = DefaultScreenWidth
+	}
+	if height <= 0 {
+		height = DefaultScreenHeight
+	}
+	return width, height, nil
+}
@@ -0,0 +1,205 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"fmt"
+	"math"
+)
+
+// Matrix is a 3x3 transform of homogeneous 2D coordinates in row major
+// order. A point (x, y) becomes (X/W, Y/W) where
+//
+//	X = m[0][0]*x + m[0][1]*y + m[0][2]
+//	Y = m[1][0]*x + m[1][1]*y + m[1][2]
+//	W = m[2][0]*x + m[2][1]*y + m[2][2]
+//
+// An affine transform has a bottom row of (0, 0, 1).
+type Matrix [3][3]float64
+
+// IdentityMatrix leaves every point unchanged.
+var IdentityMatrix = Matrix{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+
+// Point is a 2D coordinate used to describe corner correspondences.
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Apply transforms a point. Each product is rounded before it is added so
+// that the compiler can not fuse them into multiply-add instructions. This
+// keeps results the same on every platform.
+func (m Matrix) Apply(x float64, y float64) (float64, float64) {
+	tx := float64(m[0][0]*x) + float64(m[0][1]*y) + m[0][2]
+	ty := float64(m[1][0]*x) + float64(m[1][1]*y) + m[1][2]
+	w := float64(m[2][0]*x) + float64(m[2][1]*y) + m[2][2]
+	return tx / w, ty / w
+}
+
+// Multiply returns the transform that applies n and then m.
+func (m Matrix) Multiply(n Matrix) Matrix {
+	var out Matrix
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			for k := 0; k < 3; k++ {
+				out[r][c] += m[r][k] * n[k][c]
+			}
+		}
+	}
+	return out
+}
+
+// RightMatrix is the transform that RightPositionScaler applies.
+func RightMatrix(tabletWidth int, tabletHeight int, screenWidth int, screenHeight int) Matrix {
+	scaleX := float64(screenWidth) / float64(tabletWidth)
+	scaleY := float64(screenHeight) / float64(tabletHeight)
+	return Matrix{
+		{scaleX, 0, 0},
+		{0, scaleY, 0},
+		{0, 0, 1},
+	}
+}
+
+// LeftMatrix is the transform that LeftPositionScaler applies. It is a 180
+// degree rotation of RightMatrix.
+func LeftMatrix(tabletWidth int, tabletHeight int, screenWidth int, screenHeight int) Matrix {
+	scaleX := float64(screenWidth) / float64(tabletWidth)
+	scaleY := float64(screenHeight) / float64(tabletHeight)
+	return Matrix{
+		{-scaleX, 0, scaleX * float64(tabletWidth)},
+		{0, -scaleY, scaleY * float64(tabletHeight)},
+		{0, 0, 1},
+	}
+}
+
+// VerticalMatrix is the transform that VerticalPositionScaler applies. It swaps
+// the axes and mirrors the tablet x axis.
+func VerticalMatrix(tabletWidth int, tabletHeight int, screenWidth int, screenHeight int) Matrix {
+	scaleX := float64(screenWidth) / float64(tabletHeight)
+	scaleY := float64(screenHeight) / float64(tabletWidth)
+	return Matrix{
+		{0, scaleX, 0},
+		{-scaleY, 0, scaleY * float64(tabletWidth)},
+		{0, 0, 1},
+	}
+}
+
+// AffineFromCorners returns the affine transform that maps each of three
+// source points onto the matching destination point. The source points must
+// not be in a line.
+func AffineFromCorners(src [3]Point, dst [3]Point) (Matrix, error) {
+	// Each output row is an independent solution of a 3x3 system:
+	// a*x + b*y + c = dst for each of the three points.
+	system := func() [][]float64 {
+		a := make([][]float64, 3)
+		for i, p := range src {
+			a[i] = []float64{p.X, p.Y, 1}
+		}
+		return a
+	}
+	bx := []float64{dst[0].X, dst[1].X, dst[2].X}
+	by := []float64{dst[0].Y, dst[1].Y, dst[2].Y}
+	rowX, err := solveLinear(system(), bx)
+	if err != nil {
+		return Matrix{}, err
+	}
+	rowY, err := solveLinear(system(), by)
+	if err != nil {
+		return Matrix{}, err
+	}
+	return Matrix{
+		{rowX[0], rowX[1], rowX[2]},
+		{rowY[0], rowY[1], rowY[2]},
+		{0, 0, 1},
+	}, nil
+}
+
+// ProjectiveFromCorners returns the projective transform, or homography, that
+// maps each of four source points onto the matching destination point. This
+// corrects for keystone distortion such as from a projector that is not square
+// to the wall. No three of the source points may be in a line.
+func ProjectiveFromCorners(src [4]Point, dst [4]Point) (Matrix, error) {
+	// The bottom right value is fixed at 1 which leaves eight unknowns. Each
+	// correspondence gives two equations:
+	//
+	//	h0*x + h1*y + h2 - h6*x*X - h7*y*X = X
+	//	h3*x + h4*y + h5 - h6*x*Y - h7*y*Y = Y
+	a := make([][]float64, 0, 8)
+	b := make([]float64, 0, 8)
+	for i := range src {
+		x, y := src[i].X, src[i].Y
+		tx, ty := dst[i].X, dst[i].Y
+		a = append(a, []float64{x, y, 1, 0, 0, 0, -x * tx, -y * tx})
+		b = append(b, tx)
+		a = append(a, []float64{0, 0, 0, x, y, 1, -x * ty, -y * ty})
+		b = append(b, ty)
+	}
+	h, err := solveLinear(a, b)
+	if err != nil {
+		return Matrix{}, err
+	}
+	return Matrix{
+		{h[0], h[1], h[2]},
+		{h[3], h[4], h[5]},
+		{h[6], h[7], 1},
+	}, nil
+}
+
+// solveLinear solves a square system with Gaussian elimination and partial
+// pivoting. The inputs are modified.
+func solveLinear(a [][]float64, b []float64) ([]float64, error) {
+	n := len(b)
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, fmt.Errorf("the corners do not describe a unique transform")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+		for row := col + 1; row < n; row++ {
+			f := a[row][col] / a[col][col]
+			for k := col; k < n; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+			b[row] -= f * b[col]
+		}
+	}
+	x := make([]float64, n)
+	for row := n - 1; row >= 0; row-- {
+		sum := b[row]
+		for k := row + 1; k < n; k++ {
+			sum -= a[row][k] * x[k]
+		}
+		x[row] = sum / a[row][row]
+	}
+	return x, nil
+}
+
+// MatrixPositionScaler converts points using an arbitrary affine or
+// projective transform. This supports setups that the orientation scalers do
+// not, such as mirrored projectors, 270 degree mounting, or skew correction.
+type MatrixPositionScaler struct {
+	Matrix Matrix
+}
+
+// ScalePosition applies the transform.
+func (s *MatrixPositionScaler) ScalePosition(x float64, y float64) (float64, float64) {
+	return s.Matrix.Apply(x, y)
+}
@@ -0,0 +1,116 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatrixPositionScalerMatchesOrientationScalers(t *testing.T) {
+	screens := [][2]int{{1920, 1080}, {2560, 1440}, {1366, 768}, {3840, 2160}}
+	for _, screen := range screens {
+		w, h := DefaultTabletWidth, DefaultTabletHeight
+		tests := []struct {
+			name   string
+			scaler PositionScaler
+			matrix Matrix
+		}{
+			{
+				name:   "right",
+				scaler: &RightPositionScaler{TabletWidth: w, TabletHeight: h, ScreenWidth: screen[0], ScreenHeight: screen[1]},
+				matrix: RightMatrix(w, h, screen[0], screen[1]),
+			},
+			{
+				name:   "left",
+				scaler: &LeftPositionScaler{TabletWidth: w, TabletHeight: h, ScreenWidth: screen[0], ScreenHeight: screen[1]},
+				matrix: LeftMatrix(w, h, screen[0], screen[1]),
+			},
+			{
+				name:   "vertical",
+				scaler: &VerticalPositionScaler{TabletWidth: w, TabletHeight: h, ScreenWidth: screen[0], ScreenHeight: screen[1]},
+				matrix: VerticalMatrix(w, h, screen[0], screen[1]),
+			},
+		}
+		for _, tt := range tests {
+			m := &MatrixPositionScaler{Matrix: tt.matrix}
+			for x := 0; x <= w; x += 7 {
+				for y := 0; y <= h; y += 997 {
+					wantX, wantY := tt.scaler.ScalePosition(float64(x), float64(y))
+					gotX, gotY := m.ScalePosition(float64(x), float64(y))
+					require.Equal(t, wantX, gotX, "%s %v (%d, %d)", tt.name, screen, x, y)
+					require.Equal(t, wantY, gotY, "%s %v (%d, %d)", tt.name, screen, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestAffineFromCorners(t *testing.T) {
+	// A 270 degree rotation of a 100x50 tablet onto a 50x100 screen.
+	src := [3]Point{{0, 0}, {100, 0}, {0, 50}}
+	dst := [3]Point{{0, 100}, {0, 0}, {50, 100}}
+	m, err := AffineFromCorners(src, dst)
+	require.Nil(t, err)
+	for i := range src {
+		x, y := m.Apply(src[i].X, src[i].Y)
+		require.InDelta(t, dst[i].X, x, 1e-9)
+		require.InDelta(t, dst[i].Y, y, 1e-9)
+	}
+	x, y := m.Apply(100, 50)
+	require.InDelta(t, 50, x, 1e-9)
+	require.InDelta(t, 0, y, 1e-9)
+
+	_, err = AffineFromCorners([3]Point{{0, 0}, {1, 1}, {2, 2}}, dst)
+	require.NotNil(t, err)
+}
+
+func TestProjectiveFromCorners(t *testing.T) {
+	// A keystone corrected projection where the top of the screen is
+	// narrower than the bottom.
+	src := [4]Point{{0, 0}, {1000, 0}, {1000, 500}, {0, 500}}
+	dst := [4]Point{{100, 0}, {900, 0}, {1000, 500}, {0, 500}}
+	m, err := ProjectiveFromCorners(src, dst)
+	require.Nil(t, err)
+	for i := range src {
+		x, y := m.Apply(src[i].X, src[i].Y)
+		require.InDelta(t, dst[i].X, x, 1e-6)
+		require.InDelta(t, dst[i].Y, y, 1e-6)
+	}
+	// The centre of the top edge stays centred.
+	x, _ := m.Apply(500, 0)
+	require.InDelta(t, 500, x, 1e-6)
+
+	// A mirror is a projective transform too.
+	mirror, err := ProjectiveFromCorners(src, [4]Point{{1000, 0}, {0, 0}, {0, 500}, {1000, 500}})
+	require.Nil(t, err)
+	s := &MatrixPositionScaler{Matrix: mirror}
+	gotX, gotY := s.ScalePosition(250, 100)
+	require.InDelta(t, 750, gotX, 1e-6)
+	require.InDelta(t, 100, gotY, 1e-6)
+
+	_, err = ProjectiveFromCorners(src, [4]Point{{0, 0}, {0, 0}, {0, 0}, {0, 0}})
+	require.NotNil(t, err)
+}
+
+func TestMatrixMultiply(t *testing.T) {
+	m := LeftMatrix(100, 50, 200, 100)
+	require.Equal(t, m, m.Multiply(IdentityMatrix))
+	require.Equal(t, m, IdentityMatrix.Multiply(m))
+	// Rotating by 180 degrees twice, after undoing the scale, is the identity.
+	twice := LeftMatrix(100, 50, 100, 50).Multiply(LeftMatrix(100, 50, 100, 50))
+	require.Equal(t, IdentityMatrix, twice)
+}
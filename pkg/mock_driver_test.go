@@ -48,7 +48,7 @@ func (mr *MockDriverMockRecorder) Click() *gomock.Call {
 }
 
 // DragMouse mocks base method.
-func (m *MockDriver) DragMouse(arg0, arg1 int) error {
+func (m *MockDriver) DragMouse(arg0, arg1 float64) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "DragMouse", arg0, arg1)
 	ret0, _ := ret[0].(error)
@@ -78,7 +78,7 @@ func (mr *MockDriverMockRecorder) GetSize() *gomock.Call {
 }
 
 // MoveMouse mocks base method.
-func (m *MockDriver) MoveMouse(arg0, arg1 int) error {
+func (m *MockDriver) MoveMouse(arg0, arg1 float64) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "MoveMouse", arg0, arg1)
 	ret0, _ := ret[0].(error)
@@ -14,6 +14,8 @@
 
 package remouseable
 
+import "math"
+
 const (
 	// DefaultTabletHeight is the standard max height value that can be measured
 	// on a remarkable tablet. Height is the measure of the maximum x coordinate
@@ -35,6 +37,13 @@ const (
 	DefaultTabletWidth = 20967
 )
 
+// RoundPosition converts a position to the nearest whole pixel. Halfway values
+// are rounded away from zero so that positions are not biased toward the
+// origin of the screen.
+func RoundPosition(x float64, y float64) (int, int) {
+	return int(math.Round(x)), int(math.Round(y))
+}
+
 // RightPositionScaler converts points from a right-horizontally positioned
 // tablet to a differently sized screen.
 type RightPositionScaler struct {
@@ -45,17 +54,20 @@ type RightPositionScaler struct {
 }
 
 // ScalePosition resolves based on a hoizontal position of the tablet.
-func (s *RightPositionScaler) ScalePosition(x int, y int) (int, int) {
+func (s *RightPositionScaler) ScalePosition(x float64, y float64) (float64, float64) {
 	// A horizontal orientation of the tablet with the top on the right is
 	// actually the natural orientation of the screen on the device. This
 	// orientation places the origin at the upper left corner which matches the
 	// origin of the host screen. Because this orientation is the most "natural"
 	// it has the simplest scaling policy of directly translating x and y values
 	// using the proportional screen size as a scaling factor.
-	scaleX := float64(s.ScreenWidth) / float64(s.TabletWidth)
-	scaleY := float64(s.ScreenHeight) / float64(s.TabletHeight)
-	// Apply the scaling factor to the points.
-	return int(scaleX * float64(x)), int(scaleY * float64(y))
+	//
+	// The scaling is expressed as a matrix so that the MatrixPositionScaler
+	// produces exactly the same results when given RightMatrix.
+	m := &MatrixPositionScaler{
+		Matrix: RightMatrix(s.TabletWidth, s.TabletHeight, s.ScreenWidth, s.ScreenHeight),
+	}
+	return m.ScalePosition(x, y)
 }
 
 // LeftPositionScaler converts points from a left-horizontally positioned
@@ -68,19 +80,19 @@ type LeftPositionScaler struct {
 }
 
 // ScalePosition resolves based on a hoizontal position of the tablet.
-func (s *LeftPositionScaler) ScalePosition(x int, y int) (int, int) {
+func (s *LeftPositionScaler) ScalePosition(x float64, y float64) (float64, float64) {
 	// Because the tablet is oriented opposite a typical screen we need
 	// to adjust the x and y values by translating them. For example, the tablet
 	// coordinate (0,0) is the bottom right corner of the tablet when oriented
 	// left. However, the equivalent screen coordinate would be
 	// (ScreenHeight, ScreenWidth). To resolve this conflice we subtract the
 	// x and y values of the tablet from the maximum values so that (0,0)
-	// becomes (max, max) and (max, max) becomes (0, 0).
-	x, y = s.TabletWidth-x, s.TabletHeight-y
-	scaleX := float64(s.ScreenWidth) / float64(s.TabletWidth)
-	scaleY := float64(s.ScreenHeight) / float64(s.TabletHeight)
-	// Apply the scaling factor to the points.
-	return int(scaleX * float64(x)), int(scaleY * float64(y))
+	// becomes (max, max) and (max, max) becomes (0, 0). LeftMatrix combines
+	// this translation with the scaling factor.
+	m := &MatrixPositionScaler{
+		Matrix: LeftMatrix(s.TabletWidth, s.TabletHeight, s.ScreenWidth, s.ScreenHeight),
+	}
+	return m.ScalePosition(x, y)
 }
 
 // VerticalPositionScaler converts points from a vertically positioned
@@ -93,7 +105,7 @@ type VerticalPositionScaler struct {
 }
 
 // ScalePosition resolves based on a vertical position of the tablet.
-func (s *VerticalPositionScaler) ScalePosition(x int, y int) (int, int) {
+func (s *VerticalPositionScaler) ScalePosition(x float64, y float64) (float64, float64) {
 	// A vertical position of the tablet is the "natural" position of the device
 	// with the buttons on bottom and power button on top. However, this is not
 	// the natural orientation of the tablet screen which is actually oriented
@@ -108,8 +120,10 @@ func (s *VerticalPositionScaler) ScalePosition(x int, y int) (int, int) {
 	// (max, 0) value becomes the screen (0,0) value which represents the upper
 	// left corner. The tablet y values are not adjusted as they are directly equal to
 	// the corresponding screen x values without additional translation.
-	x, y = y, s.TabletWidth-x
-	scaleX := float64(s.ScreenWidth) / float64(s.TabletHeight)
-	scaleY := float64(s.ScreenHeight) / float64(s.TabletWidth)
-	return int(scaleX * float64(x)), int(scaleY * float64(y))
+	// VerticalMatrix combines the swap and translation with the scaling
+	// factor.
+	m := &MatrixPositionScaler{
+		Matrix: VerticalMatrix(s.TabletWidth, s.TabletHeight, s.ScreenWidth, s.ScreenHeight),
+	}
+	return m.ScalePosition(x, y)
 }
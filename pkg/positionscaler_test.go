@@ -28,15 +28,15 @@ func TestRightPositionScaler_ScalePosition(t *testing.T) {
 		ScreenHeight int
 	}
 	type args struct {
-		x int
-		y int
+		x float64
+		y float64
 	}
 	tests := []struct {
 		name   string
 		fields fields
 		args   args
-		wantX  int
-		wantY  int
+		wantX  float64
+		wantY  float64
 	}{
 		{
 			name: "square scale factor 1",
@@ -107,15 +107,15 @@ func TestLeftPositionScaler_ScalePosition(t *testing.T) {
 		ScreenHeight int
 	}
 	type args struct {
-		x int
-		y int
+		x float64
+		y float64
 	}
 	tests := []struct {
 		name   string
 		fields fields
 		args   args
-		wantX  int
-		wantY  int
+		wantX  float64
+		wantY  float64
 	}{
 		{
 			name: "square scale factor 1",
@@ -186,15 +186,15 @@ func TestVerticalPositionScaler_ScalePosition(t *testing.T) {
 		ScreenHeight int
 	}
 	type args struct {
-		x int
-		y int
+		x float64
+		y float64
 	}
 	tests := []struct {
 		name   string
 		fields fields
 		args   args
-		wantX  int
-		wantY  int
+		wantX  float64
+		wantY  float64
 	}{
 		{
 			name: "square scale factor 1",
@@ -256,3 +256,29 @@ func TestVerticalPositionScaler_ScalePosition(t *testing.T) {
 		})
 	}
 }
+
+func TestRoundPosition(t *testing.T) {
+	x, y := RoundPosition(10.5, 10.49)
+	require.Equal(t, 11, x)
+	require.Equal(t, 10, y)
+
+	// Truncation would move both of these toward the origin.
+	x, y = RoundPosition(99.9, 0.6)
+	require.Equal(t, 100, x)
+	require.Equal(t, 1, y)
+}
+
+func TestRightPositionScaler_ScalePositionKeepsPrecision(t *testing.T) {
+	s := &RightPositionScaler{
+		TabletWidth:  DefaultTabletWidth,
+		TabletHeight: DefaultTabletHeight,
+		ScreenWidth:  1920,
+		ScreenHeight: 1080,
+	}
+	// Neighbouring tablet positions land on the same pixel but must still be
+	// distinguishable for drivers that accept sub-pixel input.
+	x1, _ := s.ScalePosition(10000, 0)
+	x2, _ := s.ScalePosition(10001, 0)
+	require.Less(t, x1, x2)
+	require.InDelta(t, 10000*1920.0/DefaultTabletWidth, x1, 1e-9)
+}
@@ -14,14 +14,51 @@
 
 package remouseable
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"sync"
+)
 
 // Runtime binds the various domain elements into an application.
+//
+// By default the driver is called on the same goroutine as Next. When
+// QueueSize is set the driver is instead called from its own goroutine that
+// takes actions from a queue of up to QueueSize entries. This keeps a slow
+// driver from holding up the reading and interpretation of events.
 type Runtime struct {
 	StateMachine   StateMachine
 	PositionScaler PositionScaler
 	Driver         Driver
-	err            error
+	// QueueSize is the number of actions that may wait for the driver. The
+	// driver is called directly from Next when it is 0.
+	QueueSize int
+	// Overflow is one of the Overflow* values. It sets what happens to a hover
+	// move when the driver queue is full. The default is DefaultOverflow.
+	Overflow string
+	queue    *actionQueue
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	driveErr error
+	err      error
+	// clicked is true while the driver holds the button down. It is only
+	// used by the goroutine that calls the driver.
+	clicked bool
+}
+
+// Run calls Next until the events end, an error happens, or ctx is done, and
+// then closes the runtime. The error is the error of ctx if it ended the run.
+// The iterator of the state machine should be a ContextEvdevIterator with the
+// same context so that Run does not wait for the next event from the tablet
+// before it returns.
+func (r *Runtime) Run(ctx context.Context) error {
+	for ctx.Err() == nil && r.Next() {
+	}
+	err := r.Close()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
 }
 
 // Next executes one step of the runtime loop.
@@ -30,46 +67,122 @@ func (r *Runtime) Next() bool {
 		// Attempt to prevent re-entry after an error is encountered.
 		return false
 	}
+	if r.QueueSize > 0 && r.queue == nil {
+		if r.err = r.start(); r.err != nil {
+			return false
+		}
+	}
 	if !r.StateMachine.Next() {
 		// Stop iteration if the state machine has completed all iterations.
 		return false
 	}
-	change := r.StateMachine.Current()
-	switch change.Type() {
-	case ChangeTypeMove:
-		evt := change.(*StateChangeMove)
-		if err := r.Driver.MoveMouse(r.PositionScaler.ScalePosition(evt.X, evt.Y)); err != nil {
+	action, err := r.action(r.StateMachine.Current())
+	if err != nil {
+		r.err = err
+		return false
+	}
+	if r.queue == nil {
+		if err := r.apply(action); err != nil {
 			r.err = err
 			return false
 		}
 		return true
+	}
+	if !r.queue.push(action) {
+		// The queue is only closed early when the driver fails.
+		r.err = r.driverErr()
+		return false
+	}
+	return true
+}
+
+// action scales a state change into an action for the driver.
+func (r *Runtime) action(change StateChange) (queuedAction, error) {
+	switch change.Type() {
+	case ChangeTypeMove:
+		evt := change.(*StateChangeMove)
+		x, y := r.PositionScaler.ScalePosition(evt.X, evt.Y)
+		return queuedAction{kind: ChangeTypeMove, x: x, y: y}, nil
 	case ChangeTypeDrag:
 		evt := change.(*StateChangeDrag)
-		if err := r.Driver.DragMouse(r.PositionScaler.ScalePosition(evt.X, evt.Y)); err != nil {
-			r.err = err
-			return false
+		x, y := r.PositionScaler.ScalePosition(evt.X, evt.Y)
+		return queuedAction{kind: ChangeTypeDrag, x: x, y: y}, nil
+	case ChangeTypeClick, ChangeTypeUnclick:
+		return queuedAction{kind: change.Type()}, nil
+	default:
+		return queuedAction{}, fmt.Errorf("encountered unhandled state machine event %s", change.Type())
+	}
+}
+
+// start creates the driver queue and the goroutine that drains it.
+func (r *Runtime) start() error {
+	q, err := newActionQueue(r.QueueSize, r.Overflow)
+	if err != nil {
+		return err
+	}
+	r.queue = q
+	r.wg.Add(1)
+	go r.drive()
+	return nil
+}
+
+// drive applies queued actions until the queue is closed or the driver
+// returns an error.
+func (r *Runtime) drive() {
+	defer r.wg.Done()
+	for {
+		action, ok := r.queue.pop()
+		if !ok {
+			return
 		}
-		return true
-	case ChangeTypeClick:
-		if err := r.Driver.Click(); err != nil {
-			r.err = err
-			return false
+		if err := r.apply(action); err != nil {
+			r.mu.Lock()
+			r.driveErr = err
+			r.mu.Unlock()
+			r.queue.discard()
+			return
 		}
-		return true
+	}
+}
+
+// apply gives an action to the driver and tracks whether the button is held.
+func (r *Runtime) apply(action queuedAction) error {
+	if err := action.apply(r.Driver); err != nil {
+		return err
+	}
+	switch action.kind {
+	case ChangeTypeClick:
+		r.clicked = true
 	case ChangeTypeUnclick:
-		if err := r.Driver.Unclick(); err != nil {
-			r.err = err
-			return false
-		}
-		return true
-	default:
-		r.err = fmt.Errorf("encountered unhandled state machine event %s", change.Type())
-		return false
+		r.clicked = false
 	}
+	return nil
 }
 
-// Close the runtime and any internal resources.
+func (r *Runtime) driverErr() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.driveErr
+}
+
+// Close the runtime and any internal resources. Actions that are still queued
+// are given to the driver before it returns. A button that is still held, such
+// as when the pen was on the tablet when the connection dropped, is released
+// so that it does not stay down on the host.
 func (r *Runtime) Close() error {
+	if r.queue != nil {
+		r.queue.close()
+		r.wg.Wait()
+		if r.err == nil {
+			r.err = r.driverErr()
+		}
+	}
+	if r.clicked {
+		if err := r.Driver.Unclick(); err != nil && r.err == nil {
+			r.err = err
+		}
+		r.clicked = false
+	}
 	err := r.StateMachine.Close()
 	if r.err != nil {
 		return r.err
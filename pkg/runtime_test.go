@@ -15,6 +15,8 @@
 package remouseable
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
 
@@ -98,12 +100,12 @@ func TestRuntimeHandlesMove(t *testing.T) {
 	evt := &StateChangeMove{X: 1, Y: 2}
 	s.EXPECT().Next().Return(true)
 	s.EXPECT().Current().Return(evt)
-	p.EXPECT().ScalePosition(evt.X, evt.Y).Return(2, 3)
-	d.EXPECT().MoveMouse(2, 3).Return(nil)
+	p.EXPECT().ScalePosition(evt.X, evt.Y).Return(2.0, 3.0)
+	d.EXPECT().MoveMouse(2.0, 3.0).Return(nil)
 	s.EXPECT().Next().Return(true)
 	s.EXPECT().Current().Return(evt)
-	p.EXPECT().ScalePosition(evt.X, evt.Y).Return(2, 3)
-	d.EXPECT().MoveMouse(2, 3).Return(fmt.Errorf("move failed"))
+	p.EXPECT().ScalePosition(evt.X, evt.Y).Return(2.0, 3.0)
+	d.EXPECT().MoveMouse(2.0, 3.0).Return(fmt.Errorf("move failed"))
 	s.EXPECT().Close().Return(nil)
 
 	require.True(t, rt.Next())
@@ -126,12 +128,12 @@ func TestRuntimeHandlesDrag(t *testing.T) {
 	evt := &StateChangeDrag{X: 1, Y: 2}
 	s.EXPECT().Next().Return(true)
 	s.EXPECT().Current().Return(evt)
-	p.EXPECT().ScalePosition(evt.X, evt.Y).Return(2, 3)
-	d.EXPECT().DragMouse(2, 3).Return(nil)
+	p.EXPECT().ScalePosition(evt.X, evt.Y).Return(2.0, 3.0)
+	d.EXPECT().DragMouse(2.0, 3.0).Return(nil)
 	s.EXPECT().Next().Return(true)
 	s.EXPECT().Current().Return(evt)
-	p.EXPECT().ScalePosition(evt.X, evt.Y).Return(2, 3)
-	d.EXPECT().DragMouse(2, 3).Return(fmt.Errorf("drag failed"))
+	p.EXPECT().ScalePosition(evt.X, evt.Y).Return(2.0, 3.0)
+	d.EXPECT().DragMouse(2.0, 3.0).Return(fmt.Errorf("drag failed"))
 	s.EXPECT().Close().Return(nil)
 
 	require.True(t, rt.Next())
@@ -158,6 +160,8 @@ func TestRuntimeHandlesClick(t *testing.T) {
 	s.EXPECT().Next().Return(true)
 	s.EXPECT().Current().Return(evt)
 	d.EXPECT().Click().Return(fmt.Errorf("click failed"))
+	// The button from the first click is released on close.
+	d.EXPECT().Unclick().Return(nil)
 	s.EXPECT().Close().Return(nil)
 
 	require.True(t, rt.Next())
@@ -190,3 +194,131 @@ func TestRuntimeHandlesUnclick(t *testing.T) {
 	require.False(t, rt.Next())
 	require.NotNil(t, rt.Close())
 }
+
+func TestRuntimeQueued(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	d := NewMockDriver(ctrl)
+	p := NewMockPositionScaler(ctrl)
+	s := NewMockStateMachine(ctrl)
+	rt := &Runtime{
+		Driver:         d,
+		PositionScaler: p,
+		StateMachine:   s,
+		QueueSize:      DefaultQueueSize,
+	}
+	for _, change := range []StateChange{
+		&StateChangeMove{X: 1, Y: 2},
+		&StateChangeClick{},
+		&StateChangeDrag{X: 3, Y: 4},
+		&StateChangeUnclick{},
+	} {
+		s.EXPECT().Next().Return(true)
+		s.EXPECT().Current().Return(change)
+	}
+	s.EXPECT().Next().Return(false)
+	p.EXPECT().ScalePosition(1.0, 2.0).Return(2.0, 3.0)
+	p.EXPECT().ScalePosition(3.0, 4.0).Return(4.0, 5.0)
+	gomock.InOrder(
+		d.EXPECT().MoveMouse(2.0, 3.0).Return(nil),
+		d.EXPECT().Click().Return(nil),
+		d.EXPECT().DragMouse(4.0, 5.0).Return(nil),
+		d.EXPECT().Unclick().Return(nil),
+	)
+	s.EXPECT().Close().Return(nil)
+
+	for rt.Next() {
+	}
+	// The driver goroutine has handled every action once Close returns.
+	require.Nil(t, rt.Close())
+}
+
+func TestRuntimeQueuedDriverError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	d := NewMockDriver(ctrl)
+	p := NewMockPositionScaler(ctrl)
+	s := NewMockStateMachine(ctrl)
+	rt := &Runtime{
+		Driver:         d,
+		PositionScaler: p,
+		StateMachine:   s,
+		QueueSize:      1,
+	}
+	s.EXPECT().Next().Return(true).AnyTimes()
+	s.EXPECT().Current().Return(&StateChangeClick{}).AnyTimes()
+	d.EXPECT().Click().Return(fmt.Errorf("click failed"))
+	s.EXPECT().Close().Return(nil)
+
+	for rt.Next() {
+	}
+	require.EqualError(t, rt.Close(), "click failed")
+}
+
+func TestRuntimeQueuedUnknownOverflow(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := NewMockStateMachine(ctrl)
+	rt := &Runtime{
+		Driver:         NewMockDriver(ctrl),
+		PositionScaler: NewMockPositionScaler(ctrl),
+		StateMachine:   s,
+		QueueSize:      1,
+		Overflow:       "skip",
+	}
+	s.EXPECT().Close().Return(nil)
+	require.False(t, rt.Next())
+	require.True(t, errors.Is(rt.Close(), ErrUnknownOverflow))
+}
+
+func TestRuntimeRunReleasesButton(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := NewMockDriver(ctrl)
+	s := NewMockStateMachine(ctrl)
+	rt := &Runtime{
+		Driver:         d,
+		PositionScaler: NewMockPositionScaler(ctrl),
+		StateMachine:   s,
+	}
+	s.EXPECT().Next().Return(true)
+	s.EXPECT().Current().Return(&StateChangeClick{})
+	d.EXPECT().Click().DoAndReturn(func() error {
+		// Interrupted while the pen is down.
+		cancel()
+		return nil
+	})
+	gomock.InOrder(
+		d.EXPECT().Unclick().Return(nil),
+		s.EXPECT().Close().Return(nil),
+	)
+	require.Equal(t, context.Canceled, rt.Run(ctx))
+}
+
+func TestRuntimeRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	d := NewMockDriver(ctrl)
+	s := NewMockStateMachine(ctrl)
+	rt := &Runtime{
+		Driver:         d,
+		PositionScaler: NewMockPositionScaler(ctrl),
+		StateMachine:   s,
+		QueueSize:      DefaultQueueSize,
+	}
+	s.EXPECT().Next().Return(true)
+	s.EXPECT().Current().Return(&StateChangeClick{})
+	s.EXPECT().Next().Return(false)
+	d.EXPECT().Click().Return(nil)
+	// The stream ended while the pen was down.
+	d.EXPECT().Unclick().Return(nil)
+	s.EXPECT().Close().Return(fmt.Errorf("disconnected"))
+	require.EqualError(t, rt.Run(context.Background()), "disconnected")
+}
@@ -0,0 +1,195 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import "iter"
+
+// Events returns the events of an iterator as a sequence for use with range.
+// The iterator is closed when the loop ends, including when it ends early. If
+// closing returns an error then it is yielded last with an empty event. When
+// the loop ends early there is no way to yield the error so it is yielded the
+// next time the sequence is ranged over instead. SeqEvdevIterator uses this to
+// report it from Close.
+//
+//	for evt, err := range Events(it) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func Events(it EvdevIterator) iter.Seq2[EvdevEvent, error] {
+	var closed bool
+	var closeErr error
+	return func(yield func(EvdevEvent, error) bool) {
+		if closed {
+			if err := closeErr; err != nil {
+				closeErr = nil
+				yield(EvdevEvent{}, err)
+			}
+			return
+		}
+		for it.Next() {
+			if !yield(it.Current(), nil) {
+				closed, closeErr = true, it.Close()
+				return
+			}
+		}
+		closed = true
+		if err := it.Close(); err != nil {
+			yield(EvdevEvent{}, err)
+		}
+	}
+}
+
+// StateChanges returns the changes of a state machine as a sequence. It
+// closes the state machine and reports errors in the same way as Events.
+func StateChanges(sm StateMachine) iter.Seq2[StateChange, error] {
+	var closed bool
+	var closeErr error
+	return func(yield func(StateChange, error) bool) {
+		if closed {
+			if err := closeErr; err != nil {
+				closeErr = nil
+				yield(nil, err)
+			}
+			return
+		}
+		for sm.Next() {
+			if !yield(sm.Current(), nil) {
+				closed, closeErr = true, sm.Close()
+				return
+			}
+		}
+		closed = true
+		if err := sm.Close(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// FilterEvents returns the events of seq for which keep returns true. Errors
+// are always passed through.
+func FilterEvents(seq iter.Seq2[EvdevEvent, error], keep func(EvdevEvent) bool) iter.Seq2[EvdevEvent, error] {
+	return func(yield func(EvdevEvent, error) bool) {
+		for evt, err := range seq {
+			if err != nil || keep(evt) {
+				if !yield(evt, err) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// SeqEvdevIterator implements the EvdevIterator interface with a sequence so
+// that events made by a range loop can be used anywhere an iterator is
+// expected. Iteration stops at the first error, which is returned by Close.
+// Close must not be called while Next is running.
+type SeqEvdevIterator struct {
+	Seq    iter.Seq2[EvdevEvent, error]
+	puller seqPuller[EvdevEvent]
+}
+
+// Next pulls the next event from the sequence.
+func (it *SeqEvdevIterator) Next() bool {
+	return it.puller.next(it.Seq)
+}
+
+// Current returns the active element.
+func (it *SeqEvdevIterator) Current() EvdevEvent {
+	return it.puller.current
+}
+
+// Close stops the sequence and returns the error that it yielded, if any.
+func (it *SeqEvdevIterator) Close() error {
+	return it.puller.close(it.Seq)
+}
+
+// SeqStateMachine implements the StateMachine interface with a sequence. It
+// behaves like SeqEvdevIterator.
+type SeqStateMachine struct {
+	Seq    iter.Seq2[StateChange, error]
+	puller seqPuller[StateChange]
+}
+
+// Next pulls the next change from the sequence.
+func (it *SeqStateMachine) Next() bool {
+	return it.puller.next(it.Seq)
+}
+
+// Current returns the active element.
+func (it *SeqStateMachine) Current() StateChange {
+	return it.puller.current
+}
+
+// Close stops the sequence and returns the error that it yielded, if any.
+func (it *SeqStateMachine) Close() error {
+	return it.puller.close(it.Seq)
+}
+
+// seqPuller holds the state of SeqEvdevIterator and SeqStateMachine.
+type seqPuller[T any] struct {
+	pull    func() (T, error, bool)
+	stop    func()
+	done    bool
+	err     error
+	current T
+}
+
+func (p *seqPuller[T]) next(seq iter.Seq2[T, error]) bool {
+	if p.done {
+		return false
+	}
+	if p.pull == nil {
+		p.pull, p.stop = iter.Pull2(seq)
+	}
+	v, err, ok := p.pull()
+	if !ok {
+		p.done = true
+		return false
+	}
+	if err != nil {
+		p.err = err
+		p.done = true
+		p.stop()
+		return false
+	}
+	p.current = v
+	return true
+}
+
+func (p *seqPuller[T]) close(seq iter.Seq2[T, error]) error {
+	if p.done {
+		return p.err
+	}
+	p.done = true
+	// A sequence only releases what it holds once it runs so one that was
+	// never pulled is started and stopped at its first element. A sequence
+	// that is stopped early can not yield the error from its cleanup until it
+	// is ranged over again, which is how Events and StateChanges report it.
+	keepErr := func(_ T, err error) bool {
+		if err != nil && p.err == nil {
+			p.err = err
+		}
+		return false
+	}
+	if p.pull == nil {
+		seq(keepErr)
+	} else {
+		p.stop()
+	}
+	seq(keepErr)
+	return p.err
+}
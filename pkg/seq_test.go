@@ -0,0 +1,209 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"errors"
+	"iter"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	it := NewMockEvdevIterator(ctrl)
+	gomock.InOrder(
+		it.EXPECT().Next().Return(true),
+		it.EXPECT().Current().Return(EvdevEvent{Code: 1}),
+		it.EXPECT().Next().Return(true),
+		it.EXPECT().Current().Return(EvdevEvent{Code: 2}),
+		it.EXPECT().Next().Return(false),
+		it.EXPECT().Close().Return(errors.New("closed")),
+	)
+	var codes []uint16
+	var err error
+	for evt, evtErr := range Events(it) {
+		if evtErr != nil {
+			err = evtErr
+			break
+		}
+		codes = append(codes, evt.Code)
+	}
+	require.Equal(t, []uint16{1, 2}, codes)
+	require.EqualError(t, err, "closed")
+
+	// Leaving the loop early closes the iterator.
+	gomock.InOrder(
+		it.EXPECT().Next().Return(true),
+		it.EXPECT().Current().Return(EvdevEvent{Code: 1}),
+		it.EXPECT().Close().Return(nil),
+	)
+	for range Events(it) {
+		break
+	}
+}
+
+func TestSeqEvdevIterator(t *testing.T) {
+	seq := func(yield func(EvdevEvent, error) bool) {
+		for i := 0; i < 3; i++ {
+			if !yield(EvdevEvent{Type: EV_ABS, Code: uint16(i)}, nil) {
+				return
+			}
+		}
+		yield(EvdevEvent{}, errors.New("done"))
+	}
+	it := &SeqEvdevIterator{Seq: seq}
+	for i := 0; i < 3; i++ {
+		require.True(t, it.Next())
+		require.Equal(t, uint16(i), it.Current().Code)
+	}
+	require.False(t, it.Next())
+	require.False(t, it.Next())
+	require.EqualError(t, it.Close(), "done")
+
+	// An iterator made from a sequence can be turned back into one.
+	even := FilterEvents(Events(&SeqEvdevIterator{Seq: seq}), func(evt EvdevEvent) bool {
+		return evt.Code%2 == 0
+	})
+	var codes []uint16
+	for evt, err := range even {
+		if err != nil {
+			require.EqualError(t, err, "done")
+			continue
+		}
+		codes = append(codes, evt.Code)
+	}
+	require.Equal(t, []uint16{0, 2}, codes)
+}
+
+func TestSeqEvdevIteratorCloseEarly(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Closing an adapter that was never pulled still closes the iterator.
+	it := NewMockEvdevIterator(ctrl)
+	gomock.InOrder(
+		it.EXPECT().Next().Return(true),
+		it.EXPECT().Current().Return(EvdevEvent{Code: 1}),
+		it.EXPECT().Close().Return(errors.New("closed")),
+	)
+	adapter := &SeqEvdevIterator{Seq: Events(it)}
+	require.EqualError(t, adapter.Close(), "closed")
+	require.EqualError(t, adapter.Close(), "closed")
+
+	// The error from closing the iterator when the loop is stopped early is
+	// returned by Close, including through a filter.
+	gomock.InOrder(
+		it.EXPECT().Next().Return(true),
+		it.EXPECT().Current().Return(EvdevEvent{Code: 1}),
+		it.EXPECT().Close().Return(errors.New("closed")),
+	)
+	adapter = &SeqEvdevIterator{Seq: FilterEvents(Events(it), func(EvdevEvent) bool { return true })}
+	require.True(t, adapter.Next())
+	require.Equal(t, uint16(1), adapter.Current().Code)
+	require.EqualError(t, adapter.Close(), "closed")
+	require.False(t, adapter.Next())
+
+	// The error is only reported once.
+	gomock.InOrder(
+		it.EXPECT().Next().Return(true),
+		it.EXPECT().Current().Return(EvdevEvent{Code: 1}),
+		it.EXPECT().Close().Return(errors.New("closed")),
+	)
+	seq := Events(it)
+	for range seq {
+		break
+	}
+	var errs []error
+	for _, err := range seq {
+		errs = append(errs, err)
+	}
+	for _, err := range seq {
+		errs = append(errs, err)
+	}
+	require.Len(t, errs, 1)
+	require.EqualError(t, errs[0], "closed")
+}
+
+func TestSeqStateMachineCloseEarly(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := NewMockStateMachine(ctrl)
+	gomock.InOrder(
+		sm.EXPECT().Next().Return(true),
+		sm.EXPECT().Current().Return(&StateChangeMove{X: 1, Y: 2}),
+		sm.EXPECT().Close().Return(errors.New("closed")),
+	)
+	adapter := &SeqStateMachine{Seq: StateChanges(sm)}
+	require.EqualError(t, adapter.Close(), "closed")
+
+	gomock.InOrder(
+		sm.EXPECT().Next().Return(true),
+		sm.EXPECT().Current().Return(&StateChangeMove{X: 1, Y: 2}),
+		sm.EXPECT().Close().Return(errors.New("closed")),
+	)
+	adapter = &SeqStateMachine{Seq: StateChanges(sm)}
+	require.True(t, adapter.Next())
+	require.EqualError(t, adapter.Close(), "closed")
+}
+
+func TestStateChanges(t *testing.T) {
+	raw := []EvdevEvent{
+		{Type: EV_ABS, Code: ABS_X, Value: 1},
+		{Type: EV_ABS, Code: ABS_Y, Value: 2},
+		{Type: EV_ABS, Code: ABS_PRESSURE, Value: 2000},
+		{Type: EV_ABS, Code: ABS_PRESSURE, Value: 0},
+	}
+	var seq iter.Seq2[EvdevEvent, error] = func(yield func(EvdevEvent, error) bool) {
+		for _, evt := range raw {
+			if !yield(evt, nil) {
+				return
+			}
+		}
+	}
+	sm := &EvdevStateMachine{Iterator: &SeqEvdevIterator{Seq: seq}, PressureThreshold: 1000}
+	var types []string
+	for change, err := range StateChanges(sm) {
+		require.Nil(t, err)
+		types = append(types, change.Type())
+	}
+	require.Equal(t, []string{ChangeTypeMove, ChangeTypeClick, ChangeTypeUnclick}, types)
+
+	// The changes can drive the runtime through SeqStateMachine.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	d := NewMockDriver(ctrl)
+	p := NewMockPositionScaler(ctrl)
+	sm = &EvdevStateMachine{Iterator: &SeqEvdevIterator{Seq: seq}, PressureThreshold: 1000}
+	rt := &Runtime{
+		Driver:         d,
+		PositionScaler: p,
+		StateMachine:   &SeqStateMachine{Seq: StateChanges(sm)},
+	}
+	p.EXPECT().ScalePosition(1.0, 2.0).Return(1.0, 2.0)
+	gomock.InOrder(
+		d.EXPECT().MoveMouse(1.0, 2.0).Return(nil),
+		d.EXPECT().Click().Return(nil),
+		d.EXPECT().Unclick().Return(nil),
+	)
+	for rt.Next() {
+	}
+	require.Nil(t, rt.Close())
+}
@@ -0,0 +1,135 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// ErrNoAuthMethods is returned when none of the configured SSH authentication
+// methods could be set up.
+var ErrNoAuthMethods = errors.New("no usable ssh authentication methods")
+
+// SSHAuth collects the ways remouseable can authenticate with a tablet.
+//
+// Methods are offered to the tablet in the order agent, key files, password
+// so that the most convenient option is attempted first. A method that cannot
+// be set up, such as an agent socket that does not exist or a key file that
+// cannot be read, is reported through Warn and skipped rather than failing
+// the connection.
+type SSHAuth struct {
+	// AgentSocket is the path to an SSH agent socket. Agent authentication is
+	// skipped if this is empty.
+	AgentSocket string
+	// KeyFiles are paths to private keys.
+	KeyFiles []string
+	// Password is offered last if it is not empty.
+	Password string
+	// Passphrase is called to decrypt a key file that is protected by a
+	// passphrase. Encrypted keys are skipped if this is nil.
+	Passphrase func(file string) ([]byte, error)
+	// Warn receives problems with individual methods. It may be nil.
+	Warn  func(error)
+	agent net.Conn
+}
+
+// Methods returns the authentication methods for an ssh.ClientConfig. It
+// returns ErrNoAuthMethods if there is nothing to try.
+func (a *SSHAuth) Methods() ([]ssh.AuthMethod, error) {
+	methods := make([]ssh.AuthMethod, 0, 2)
+	if a.AgentSocket != "" {
+		conn, err := net.Dial("unix", a.AgentSocket)
+		if err != nil {
+			a.warn(fmt.Errorf("skipping ssh agent: %w", err))
+		} else {
+			a.agent = conn
+		}
+	}
+	if a.agent != nil || len(a.KeyFiles) > 0 {
+		// The ssh package only attempts each kind of method once so agent
+		// and file keys must be offered through the same method.
+		methods = append(methods, ssh.PublicKeysCallback(a.Signers))
+	}
+	if a.Password != "" {
+		methods = append(methods, ssh.Password(a.Password))
+	}
+	if len(methods) == 0 {
+		return nil, ErrNoAuthMethods
+	}
+	return methods, nil
+}
+
+// Signers returns all keys from the agent followed by all keys that could be
+// loaded from KeyFiles. Key files are only read when the tablet asks for
+// public key authentication so that a passphrase is only requested if needed.
+func (a *SSHAuth) Signers() ([]ssh.Signer, error) {
+	signers := make([]ssh.Signer, 0, len(a.KeyFiles))
+	if a.agent != nil {
+		agentSigners, err := agent.NewClient(a.agent).Signers()
+		if err != nil {
+			a.warn(fmt.Errorf("skipping ssh agent: %w", err))
+		}
+		signers = append(signers, agentSigners...)
+	}
+	for _, file := range a.KeyFiles {
+		signer, err := a.loadKey(file)
+		if err != nil {
+			a.warn(fmt.Errorf("skipping ssh key %s: %w", file, err))
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	return signers, nil
+}
+
+// Close releases the connection to the agent, if any.
+func (a *SSHAuth) Close() error {
+	if a.agent == nil {
+		return nil
+	}
+	return a.agent.Close()
+}
+
+func (a *SSHAuth) loadKey(file string) (ssh.Signer, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(b)
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return signer, err
+	}
+	if a.Passphrase == nil {
+		return nil, err
+	}
+	passphrase, err := a.Passphrase(file)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKeyWithPassphrase(b, passphrase)
+}
+
+func (a *SSHAuth) warn(err error) {
+	if a.Warn != nil {
+		a.Warn(err)
+	}
+}
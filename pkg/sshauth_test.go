@@ -0,0 +1,180 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// writeTestPrivateKey generates a key and writes it to a file in dir. The key
+// is encrypted if a passphrase is given.
+func writeTestPrivateKey(t *testing.T, dir string, name string, passphrase string) (string, ssh.PublicKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(priv, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte(passphrase))
+	}
+	require.Nil(t, err)
+	path := filepath.Join(dir, name)
+	require.Nil(t, os.WriteFile(path, pem.EncodeToMemory(block), 0o600))
+	key, err := ssh.NewPublicKey(pub)
+	require.Nil(t, err)
+	return path, key
+}
+
+// newTestSSHServer starts an SSH server on the loopback interface that runs
+// the given configuration for every connection. It returns the address.
+func newTestSSHServer(t *testing.T, config *ssh.ServerConfig) string {
+	t.Helper()
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	require.Nil(t, err)
+	config.AddHostKey(hostSigner)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					_ = conn.Close()
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for ch := range chans {
+					if ch.ChannelType() != "direct-tcpip" {
+						_ = ch.Reject(ssh.Prohibited, "test server")
+						continue
+					}
+					go forwardTestChannel(ch)
+				}
+				_ = sconn.Close()
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestSSHAuthNoMethods(t *testing.T) {
+	var warnings []error
+	a := &SSHAuth{
+		AgentSocket: filepath.Join(t.TempDir(), "missing.sock"),
+		Warn:        func(err error) { warnings = append(warnings, err) },
+	}
+	_, err := a.Methods()
+	require.True(t, errors.Is(err, ErrNoAuthMethods))
+	require.Len(t, warnings, 1)
+	require.Nil(t, a.Close())
+}
+
+func TestSSHAuthSigners(t *testing.T) {
+	dir := t.TempDir()
+	plain, plainKey := writeTestPrivateKey(t, dir, "plain", "")
+	encrypted, encryptedKey := writeTestPrivateKey(t, dir, "encrypted", "secret")
+	var warnings []error
+	var asked []string
+	a := &SSHAuth{
+		KeyFiles: []string{filepath.Join(dir, "missing"), plain, encrypted},
+		Passphrase: func(file string) ([]byte, error) {
+			asked = append(asked, file)
+			return []byte("secret"), nil
+		},
+		Warn: func(err error) { warnings = append(warnings, err) },
+	}
+	signers, err := a.Signers()
+	require.Nil(t, err)
+	require.Len(t, signers, 2)
+	require.Equal(t, plainKey.Marshal(), signers[0].PublicKey().Marshal())
+	require.Equal(t, encryptedKey.Marshal(), signers[1].PublicKey().Marshal())
+	require.Equal(t, []string{encrypted}, asked)
+	require.Len(t, warnings, 1)
+
+	a.Passphrase = func(string) ([]byte, error) { return []byte("wrong"), nil }
+	warnings = nil
+	signers, err = a.Signers()
+	require.Nil(t, err)
+	require.Len(t, signers, 1)
+	require.Len(t, warnings, 2)
+}
+
+func TestSSHAuthFallsBackToPassword(t *testing.T) {
+	dir := t.TempDir()
+	keyFile, _ := writeTestPrivateKey(t, dir, "key", "")
+	_, acceptedKey := writeTestPrivateKey(t, dir, "accepted", "")
+	tests := []struct {
+		name     string
+		acceptPK bool
+		password string
+		wantErr  bool
+	}{
+		{name: "key accepted", acceptPK: true},
+		{name: "key rejected password accepted", password: "pass"},
+		{name: "everything rejected", password: "wrong", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := newTestSSHServer(t, &ssh.ServerConfig{
+				PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+					if tt.acceptPK || bytes.Equal(key.Marshal(), acceptedKey.Marshal()) {
+						return nil, nil
+					}
+					return nil, fmt.Errorf("rejected")
+				},
+				PasswordCallback: func(_ ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+					if string(password) == "pass" {
+						return nil, nil
+					}
+					return nil, fmt.Errorf("rejected")
+				},
+			})
+			a := &SSHAuth{KeyFiles: []string{keyFile}, Password: tt.password}
+			methods, err := a.Methods()
+			require.Nil(t, err)
+			client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+				User:            "root",
+				Auth:            methods,
+				HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+			})
+			if tt.wantErr {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Nil(t, client.Close())
+		})
+	}
+}
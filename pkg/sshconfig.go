@@ -0,0 +1,339 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// maxProxyJumpDepth limits how many jump hosts may be chained. It guards
+	// against configurations where hosts jump through each other in a loop.
+	maxProxyJumpDepth = 8
+	// maxIncludeDepth limits how deeply configuration files may include each
+	// other.
+	maxIncludeDepth = 16
+)
+
+// SSHTarget is a fully resolved SSH destination.
+type SSHTarget struct {
+	// Alias is the name that was resolved. It is the same as HostName if no
+	// configuration matched.
+	Alias string
+	// HostName is the real host name or IP address to connect to.
+	HostName string
+	// Port is the TCP port to connect to.
+	Port string
+	// User is the login name. It is empty if the configuration did not set
+	// one.
+	User string
+	// IdentityFiles are private keys configured for the host.
+	IdentityFiles []string
+	// ProxyJump are the hosts that must be connected through, in order, to
+	// reach this one.
+	ProxyJump []SSHTarget
+}
+
+// Address returns the host and port to dial.
+func (t SSHTarget) Address() string {
+	return net.JoinHostPort(t.HostName, t.Port)
+}
+
+// SSHConfig is a parsed OpenSSH client configuration file such as
+// ~/.ssh/config. Only the Host, HostName, Port, User, IdentityFile, ProxyJump,
+// and Include keywords are interpreted. Match blocks are not supported and
+// are never applied.
+type SSHConfig struct {
+	blocks []sshConfigBlock
+}
+
+type sshConfigBlock struct {
+	patterns []string
+	match    bool
+	settings [][2]string
+}
+
+// LoadSSHConfig parses the configuration file at the given path. A missing
+// file results in an empty configuration.
+func LoadSSHConfig(file string) (*SSHConfig, error) {
+	c := &SSHConfig{blocks: []sshConfigBlock{{patterns: []string{"*"}}}}
+	if err := c.include(file, 0); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ParseSSHConfig parses a configuration from a reader. Include directives
+// are resolved relative to the directory ~/.ssh as OpenSSH does.
+func ParseSSHConfig(r io.Reader) (*SSHConfig, error) {
+	c := &SSHConfig{blocks: []sshConfigBlock{{patterns: []string{"*"}}}}
+	if err := c.parse(r, 0); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *SSHConfig) include(file string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("ssh config includes are nested too deeply at %s", file)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	if err = c.parse(f, depth); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return nil
+}
+
+func (c *SSHConfig) parse(r io.Reader, depth int) error {
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		keyword, args, err := splitSSHConfigLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		if keyword == "" {
+			continue
+		}
+		switch keyword {
+		case "host":
+			c.blocks = append(c.blocks, sshConfigBlock{patterns: args})
+		case "match":
+			c.blocks = append(c.blocks, sshConfigBlock{match: true})
+		case "include":
+			for _, arg := range args {
+				if err := c.includeGlob(arg, depth+1); err != nil {
+					return err
+				}
+			}
+		default:
+			if len(args) < 1 {
+				return fmt.Errorf("line %d: missing value for %s", lineNum, keyword)
+			}
+			last := &c.blocks[len(c.blocks)-1]
+			last.settings = append(last.settings, [2]string{keyword, strings.Join(args, " ")})
+		}
+	}
+	return scanner.Err()
+}
+
+func (c *SSHConfig) includeGlob(pattern string, depth int) error {
+	pattern = expandHome(pattern)
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(expandHome("~/.ssh"), pattern)
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	for _, m := range matches {
+		if err := c.include(m, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitSSHConfigLine returns the lower case keyword and the arguments of a
+// configuration line. Both "Keyword value" and "Keyword=value" are accepted
+// and arguments may be double quoted.
+func splitSSHConfigLine(line string) (string, []string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil, nil
+	}
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return strings.ToLower(line), nil, nil
+	}
+	keyword := strings.ToLower(line[:end])
+	rest := strings.TrimLeft(line[end:], " \t")
+	rest = strings.TrimPrefix(rest, "=")
+	args := make([]string, 0, 1)
+	var current strings.Builder
+	inQuote := false
+	hasArg := false
+	for _, r := range rest {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			hasArg = true
+		case (r == ' ' || r == '\t') && !inQuote:
+			if hasArg {
+				args = append(args, current.String())
+				current.Reset()
+				hasArg = false
+			}
+		default:
+			current.WriteRune(r)
+			hasArg = true
+		}
+	}
+	if inQuote {
+		return "", nil, fmt.Errorf("unterminated quote")
+	}
+	if hasArg {
+		args = append(args, current.String())
+	}
+	return keyword, args, nil
+}
+
+// values returns all values for a keyword that apply to the host in the order
+// they appear.
+func (c *SSHConfig) values(host string, keyword string) []string {
+	values := make([]string, 0)
+	for _, b := range c.blocks {
+		if b.match || !matchSSHHostPatterns(b.patterns, host) {
+			continue
+		}
+		for _, s := range b.settings {
+			if s[0] == keyword {
+				values = append(values, s[1])
+			}
+		}
+	}
+	return values
+}
+
+// Get returns the value that applies to the host for the given keyword. As
+// with OpenSSH, the first value found wins.
+func (c *SSHConfig) Get(host string, keyword string) string {
+	values := c.values(host, strings.ToLower(keyword))
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// Resolve converts a host name or alias into a target using the matching
+// configuration. The host may include a user and port in the form
+// [user@]host[:port] which take precedence over the configuration.
+func (c *SSHConfig) Resolve(host string) (SSHTarget, error) {
+	return c.resolve(host, 0)
+}
+
+func (c *SSHConfig) resolve(host string, depth int) (SSHTarget, error) {
+	if depth > maxProxyJumpDepth {
+		return SSHTarget{}, fmt.Errorf("too many ProxyJump hops while resolving %s", host)
+	}
+	user, alias, port := splitSSHDestination(host)
+	t := SSHTarget{Alias: alias, HostName: alias, Port: "22"}
+	if v := c.Get(alias, "hostname"); v != "" {
+		t.HostName = strings.ReplaceAll(v, "%h", alias)
+	}
+	if v := c.Get(alias, "port"); v != "" {
+		t.Port = v
+	}
+	if port != "" {
+		t.Port = port
+	}
+	t.User = c.Get(alias, "user")
+	if user != "" {
+		t.User = user
+	}
+	for _, v := range c.values(alias, "identityfile") {
+		t.IdentityFiles = append(t.IdentityFiles, expandSSHTokens(v, t))
+	}
+	jump := c.Get(alias, "proxyjump")
+	if jump == "" || strings.EqualFold(jump, "none") {
+		return t, nil
+	}
+	for _, hop := range strings.Split(jump, ",") {
+		hopTarget, err := c.resolve(strings.TrimSpace(hop), depth+1)
+		if err != nil {
+			return SSHTarget{}, err
+		}
+		// A jump host that itself needs jump hosts is reached through them
+		// first.
+		t.ProxyJump = append(t.ProxyJump, hopTarget.ProxyJump...)
+		hopTarget.ProxyJump = nil
+		t.ProxyJump = append(t.ProxyJump, hopTarget)
+	}
+	return t, nil
+}
+
+// splitSSHDestination splits [user@]host[:port]. Bracketed IPv6 addresses
+// are supported.
+func splitSSHDestination(dest string) (string, string, string) {
+	user := ""
+	if i := strings.LastIndex(dest, "@"); i >= 0 {
+		user, dest = dest[:i], dest[i+1:]
+	}
+	if h, p, err := net.SplitHostPort(dest); err == nil {
+		return user, h, p
+	}
+	return user, dest, ""
+}
+
+// matchSSHHostPatterns applies OpenSSH pattern list rules. The host matches if
+// any pattern matches and no negated pattern matches.
+func matchSSHHostPatterns(patterns []string, host string) bool {
+	matched := false
+	for _, p := range patterns {
+		negated := strings.HasPrefix(p, "!")
+		p = strings.TrimPrefix(p, "!")
+		ok, err := path.Match(strings.ToLower(p), strings.ToLower(host))
+		if err != nil || !ok {
+			continue
+		}
+		if negated {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+// expandSSHTokens expands the ~ prefix and the subset of OpenSSH % tokens that
+// make sense for remouseable.
+func expandSSHTokens(value string, t SSHTarget) string {
+	home, _ := os.UserHomeDir()
+	replacer := strings.NewReplacer(
+		"%%", "%",
+		"%d", home,
+		"%h", t.HostName,
+		"%n", t.Alias,
+		"%p", t.Port,
+		"%r", t.User,
+	)
+	return expandHome(replacer.Replace(value))
+}
+
+func expandHome(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(home, strings.TrimPrefix(p, "~"))
+}
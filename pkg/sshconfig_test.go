@@ -0,0 +1,119 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSSHConfig = `
+# Global settings apply to every host.
+IdentityFile /keys/global
+
+Host rm2-desk
+    HostName 192.168.1.50
+    User root
+    IdentityFile /keys/desk
+    ProxyJump bastion
+
+Host rm1-*  !rm1-skip
+    Port 2222
+
+Host bastion
+    HostName=bastion.example.com
+    User "jump user"
+    ProxyJump outer
+
+Host outer
+    HostName outer.example.com
+    Port 2200
+
+Match host rm2-desk
+    User ignored
+
+Host *
+    User fallback
+    Port 22
+`
+
+func TestSSHConfigResolve(t *testing.T) {
+	c, err := ParseSSHConfig(strings.NewReader(testSSHConfig))
+	require.Nil(t, err)
+
+	desk, err := c.Resolve("rm2-desk")
+	require.Nil(t, err)
+	require.Equal(t, "rm2-desk", desk.Alias)
+	require.Equal(t, "192.168.1.50:22", desk.Address())
+	require.Equal(t, "root", desk.User)
+	require.Equal(t, []string{"/keys/global", "/keys/desk"}, desk.IdentityFiles)
+	require.Len(t, desk.ProxyJump, 2)
+	require.Equal(t, "outer.example.com:2200", desk.ProxyJump[0].Address())
+	require.Equal(t, "fallback", desk.ProxyJump[0].User)
+	require.Equal(t, "bastion.example.com:22", desk.ProxyJump[1].Address())
+	require.Equal(t, "jump user", desk.ProxyJump[1].User)
+	require.Empty(t, desk.ProxyJump[1].ProxyJump)
+
+	rm1, err := c.Resolve("rm1-kitchen")
+	require.Nil(t, err)
+	require.Equal(t, "rm1-kitchen:2222", rm1.Address())
+
+	skipped, err := c.Resolve("rm1-skip")
+	require.Nil(t, err)
+	require.Equal(t, "rm1-skip:22", skipped.Address())
+
+	explicit, err := c.Resolve("admin@rm2-desk:2022")
+	require.Nil(t, err)
+	require.Equal(t, "admin", explicit.User)
+	require.Equal(t, "192.168.1.50:2022", explicit.Address())
+}
+
+func TestSSHConfigProxyJumpLoop(t *testing.T) {
+	c, err := ParseSSHConfig(strings.NewReader("Host a\n ProxyJump b\nHost b\n ProxyJump a\n"))
+	require.Nil(t, err)
+	_, err = c.Resolve("a")
+	require.NotNil(t, err)
+}
+
+func TestSSHConfigParseErrors(t *testing.T) {
+	_, err := ParseSSHConfig(strings.NewReader("Host a\n HostName \"unterminated\n"))
+	require.NotNil(t, err)
+	_, err = ParseSSHConfig(strings.NewReader("Host a\n HostName\n"))
+	require.NotNil(t, err)
+}
+
+func TestLoadSSHConfig(t *testing.T) {
+	dir := t.TempDir()
+	included := filepath.Join(dir, "tablets.conf")
+	require.Nil(t, os.WriteFile(included, []byte("Host rm\n HostName 10.11.99.1\n"), 0o600))
+	main := filepath.Join(dir, "config")
+	require.Nil(t, os.WriteFile(main, []byte("Include "+filepath.Join(dir, "*.conf")+"\n"), 0o600))
+
+	c, err := LoadSSHConfig(main)
+	require.Nil(t, err)
+	target, err := c.Resolve("rm")
+	require.Nil(t, err)
+	require.Equal(t, "10.11.99.1:22", target.Address())
+
+	c, err = LoadSSHConfig(filepath.Join(dir, "missing"))
+	require.Nil(t, err)
+	target, err = c.Resolve("rm")
+	require.Nil(t, err)
+	require.Equal(t, "rm:22", target.Address())
+}
@@ -0,0 +1,87 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHClientConfigFunc produces the client configuration used to connect to a
+// single hop of an SSH connection.
+type SSHClientConfigFunc func(target SSHTarget) (*ssh.ClientConfig, error)
+
+// SSHConnection is a connection to a tablet that may have been established
+// through one or more jump hosts. Closing it closes every hop.
+type SSHConnection struct {
+	*ssh.Client
+	hops []*ssh.Client
+}
+
+// Close the connection to the tablet and to every jump host.
+func (c *SSHConnection) Close() error {
+	err := c.Client.Close()
+	for i := len(c.hops) - 1; i >= 0; i = i - 1 {
+		if hopErr := c.hops[i].Close(); err == nil {
+			err = hopErr
+		}
+	}
+	return err
+}
+
+// DialSSH connects to the target. If the target has jump hosts then each is
+// connected to in order and the next hop is dialed through the previous one.
+func DialSSH(target SSHTarget, configure SSHClientConfigFunc) (*SSHConnection, error) {
+	hops := make([]*ssh.Client, 0, len(target.ProxyJump))
+	closeHops := func() {
+		for i := len(hops) - 1; i >= 0; i = i - 1 {
+			_ = hops[i].Close()
+		}
+	}
+	path := append(append([]SSHTarget{}, target.ProxyJump...), target)
+	var client *ssh.Client
+	for _, hop := range path {
+		config, err := configure(hop)
+		if err != nil {
+			closeHops()
+			return nil, err
+		}
+		if client == nil {
+			client, err = ssh.Dial("tcp", hop.Address(), config)
+		} else {
+			client, err = dialThrough(client, hop.Address(), config)
+		}
+		if err != nil {
+			closeHops()
+			return nil, fmt.Errorf("ssh connection to %s failed: %w", hop.Alias, err)
+		}
+		hops = append(hops, client)
+	}
+	return &SSHConnection{Client: client, hops: hops[:len(hops)-1]}, nil
+}
+
+func dialThrough(via *ssh.Client, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := via.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
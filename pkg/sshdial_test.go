@@ -0,0 +1,109 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestPasswordServer(t *testing.T, user string, password string) SSHTarget {
+	t.Helper()
+	addr := newTestSSHServer(t, &ssh.ServerConfig{
+		PasswordCallback: func(meta ssh.ConnMetadata, p []byte) (*ssh.Permissions, error) {
+			if meta.User() == user && string(p) == password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("rejected")
+		},
+	})
+	host, port, err := net.SplitHostPort(addr)
+	require.Nil(t, err)
+	return SSHTarget{Alias: user, HostName: host, Port: port, User: user}
+}
+
+// forwardTestChannel implements the server side of a direct-tcpip channel so
+// that the test server can act as a jump host.
+func forwardTestChannel(newCh ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newCh.ExtraData(), &payload); err != nil {
+		_ = newCh.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort(payload.Host, fmt.Sprint(payload.Port)))
+	if err != nil {
+		_ = newCh.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	ch, reqs, err := newCh.Accept()
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		_, _ = io.Copy(ch, conn)
+		_ = ch.CloseWrite()
+	}()
+	_, _ = io.Copy(conn, ch)
+	_ = conn.Close()
+}
+
+func TestDialSSHThroughJumpHosts(t *testing.T) {
+	outer := newTestPasswordServer(t, "outer", "outer-pass")
+	inner := newTestPasswordServer(t, "inner", "inner-pass")
+	tablet := newTestPasswordServer(t, "tablet", "tablet-pass")
+	tablet.ProxyJump = []SSHTarget{outer, inner}
+
+	dialed := make([]string, 0, 3)
+	conn, err := DialSSH(tablet, func(hop SSHTarget) (*ssh.ClientConfig, error) {
+		dialed = append(dialed, hop.User)
+		return &ssh.ClientConfig{
+			User:            hop.User,
+			Auth:            []ssh.AuthMethod{ssh.Password(hop.User + "-pass")},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		}, nil
+	})
+	require.Nil(t, err)
+	require.Equal(t, []string{"outer", "inner", "tablet"}, dialed)
+	require.Equal(t, "tablet", conn.User())
+	require.Nil(t, conn.Close())
+}
+
+func TestDialSSHFailedHop(t *testing.T) {
+	outer := newTestPasswordServer(t, "outer", "outer-pass")
+	tablet := newTestPasswordServer(t, "tablet", "tablet-pass")
+	tablet.ProxyJump = []SSHTarget{outer}
+
+	_, err := DialSSH(tablet, func(hop SSHTarget) (*ssh.ClientConfig, error) {
+		return &ssh.ClientConfig{
+			User:            hop.User,
+			Auth:            []ssh.AuthMethod{ssh.Password("wrong")},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		}, nil
+	})
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "outer")
+}
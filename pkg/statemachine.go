@@ -18,9 +18,9 @@ package remouseable
 type EvdevStateMachine struct {
 	Iterator          EvdevIterator
 	PressureThreshold int
-	x                 int
+	x                 float64
 	xChanged          bool
-	y                 int
+	y                 float64
 	yChanged          bool
 	clicked           bool
 	current           StateChange
@@ -34,10 +34,10 @@ func (it *EvdevStateMachine) next(raw EvdevEvent) bool {
 	}
 	switch raw.Code {
 	case ABS_X:
-		it.x = int(raw.Value)
+		it.x = float64(raw.Value)
 		it.xChanged = true
 	case ABS_Y:
-		it.y = int(raw.Value)
+		it.y = float64(raw.Value)
 		it.yChanged = true
 	case ABS_PRESSURE:
 		if int(raw.Value) > it.PressureThreshold && !it.clicked {
@@ -42,9 +42,9 @@ func TestEvdevStateMachine_next(t *testing.T) {
 	type fields struct {
 		Iterator          EvdevIterator
 		PressureThreshold int
-		x                 int
+		x                 float64
 		xChanged          bool
-		y                 int
+		y                 float64
 		yChanged          bool
 		clicked           bool
 		current           StateChange
@@ -357,9 +357,9 @@ func TestDraggingEvdevStateMachine_next(t *testing.T) {
 	type fields struct {
 		Iterator          EvdevIterator
 		PressureThreshold int
-		x                 int
+		x                 float64
 		xChanged          bool
-		y                 int
+		y                 float64
 		yChanged          bool
 		clicked           bool
 		current           StateChange
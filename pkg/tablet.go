@@ -0,0 +1,263 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ErrUnknownTablet is returned when a tablet does not match any profile.
+var ErrUnknownTablet = errors.New("tablet model is not recognized")
+
+const (
+	// EventLayoutTimeval32 is the 16 byte input_event structure written by
+	// 32bit kernels.
+	EventLayoutTimeval32 = "timeval32"
+	// EventLayoutTimeval64 is the 24 byte input_event structure written by
+	// 64bit kernels.
+	EventLayoutTimeval64 = "timeval64"
+)
+
+//go:embed tabletprofiles.json
+var defaultTabletProfiles []byte
+
+// TabletProfile describes how to read from one tablet model.
+type TabletProfile struct {
+	// Name is shown to the user when the profile is selected.
+	Name string `json:"name"`
+	// Models are regular expressions that are matched against the machine
+	// name reported by the tablet.
+	Models []string `json:"models"`
+	// Digitizers are the input device names of the pen digitizer. The event
+	// file of the first one found is used.
+	Digitizers []string `json:"digitizers"`
+	// EventFile is used if none of the Digitizers are found.
+	EventFile string `json:"eventFile"`
+	// TabletWidth and TabletHeight are the maximum coordinate values that the
+	// digitizer reports. See DefaultTabletWidth and DefaultTabletHeight.
+	TabletWidth  int `json:"tabletWidth"`
+	TabletHeight int `json:"tabletHeight"`
+	// EventLayout is one of the EventLayout* values.
+	EventLayout string `json:"eventLayout"`
+	// Axes are the ranges of the absolute axes of the digitizer keyed by
+	// their ABS_* names. They describe the device when a capture is
+	// exported. The ranges of ABS_X and ABS_Y come from TabletWidth and
+	// TabletHeight when they are not listed.
+	Axes map[string]AxisInfo `json:"axes,omitempty"`
+}
+
+// AxisInfo is the range of an absolute axis as in struct input_absinfo.
+type AxisInfo struct {
+	Min        int32 `json:"min"`
+	Max        int32 `json:"max"`
+	Fuzz       int32 `json:"fuzz,omitempty"`
+	Flat       int32 `json:"flat,omitempty"`
+	Resolution int32 `json:"resolution,omitempty"`
+}
+
+// DefaultTabletProfiles returns the built in profiles.
+func DefaultTabletProfiles() []TabletProfile {
+	profiles, err := ParseTabletProfiles(defaultTabletProfiles)
+	if err != nil {
+		panic(err)
+	}
+	return profiles
+}
+
+// ParseTabletProfiles decodes a JSON list of profiles and checks that each is
+// usable.
+func ParseTabletProfiles(b []byte) ([]TabletProfile, error) {
+	var profiles []TabletProfile
+	if err := json.Unmarshal(b, &profiles); err != nil {
+		return nil, err
+	}
+	for _, p := range profiles {
+		if p.Name == "" {
+			return nil, fmt.Errorf("tablet profile is missing a name")
+		}
+		for _, m := range p.Models {
+			if _, err := regexp.Compile(m); err != nil {
+				return nil, fmt.Errorf("tablet profile %s: %w", p.Name, err)
+			}
+		}
+		switch p.EventLayout {
+		case "", EventLayoutTimeval32, EventLayoutTimeval64:
+		default:
+			return nil, fmt.Errorf("tablet profile %s: unknown event layout %s", p.Name, p.EventLayout)
+		}
+		for name, axis := range p.Axes {
+			if _, err := ParseCode("EV_ABS", name); err != nil {
+				return nil, fmt.Errorf("tablet profile %s: %w", p.Name, err)
+			}
+			if axis.Max <= axis.Min {
+				return nil, fmt.Errorf("tablet profile %s: the range of %s is empty", p.Name, name)
+			}
+		}
+	}
+	return profiles, nil
+}
+
+// LoadTabletProfiles returns the profiles from the given file followed by the
+// built in profiles. Profiles in the file are matched first so they may be
+// used to add new models or to override the built in ones. A missing file
+// results in only the built in profiles.
+func LoadTabletProfiles(file string) ([]TabletProfile, error) {
+	defaults := DefaultTabletProfiles()
+	if file == "" {
+		return defaults, nil
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return defaults, nil
+		}
+		return nil, err
+	}
+	profiles, err := ParseTabletProfiles(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return append(profiles, defaults...), nil
+}
+
+// InputDevice is an entry from /proc/bus/input/devices.
+type InputDevice struct {
+	// Name is the device name.
+	Name string
+	// Handlers are the kernel handlers bound to the device, such as event1.
+	Handlers []string
+}
+
+// EventFile returns the /dev/input path for the device or an empty string if
+// it has no event handler.
+func (d InputDevice) EventFile() string {
+	for _, h := range d.Handlers {
+		if strings.HasPrefix(h, "event") {
+			return "/dev/input/" + h
+		}
+	}
+	return ""
+}
+
+// ParseInputDevices reads the contents of /proc/bus/input/devices.
+func ParseInputDevices(b []byte) []InputDevice {
+	devices := make([]InputDevice, 0)
+	var current *InputDevice
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			current = nil
+			continue
+		}
+		if current == nil {
+			devices = append(devices, InputDevice{})
+			current = &devices[len(devices)-1]
+		}
+		switch {
+		case strings.HasPrefix(line, "N: Name="):
+			current.Name = strings.Trim(strings.TrimPrefix(line, "N: Name="), `"`)
+		case strings.HasPrefix(line, "H: Handlers="):
+			current.Handlers = strings.Fields(strings.TrimPrefix(line, "H: Handlers="))
+		}
+	}
+	return devices
+}
+
+// TabletInfo is the result of detecting a tablet.
+type TabletInfo struct {
+	// Model is the machine name reported by the tablet.
+	Model string
+	// Profile is the profile that matched the model.
+	Profile TabletProfile
+	// EventFile is the event file of the pen digitizer.
+	EventFile string
+	// Devices are all input devices on the tablet.
+	Devices []InputDevice
+}
+
+// TabletCommandRunner runs a shell command on the tablet and returns the
+// output.
+type TabletCommandRunner func(cmd string) ([]byte, error)
+
+// modelFiles are checked in order for the tablet machine name.
+var modelFiles = []string{"/sys/devices/soc0/machine", "/proc/device-tree/model"}
+
+// DetectTablet identifies the model of a tablet and finds the event file for
+// its pen digitizer.
+func DetectTablet(run TabletCommandRunner, profiles []TabletProfile) (TabletInfo, error) {
+	info := TabletInfo{}
+	for _, file := range modelFiles {
+		out, err := run("cat " + file)
+		if err != nil {
+			continue
+		}
+		// The device tree value is NUL terminated.
+		info.Model = strings.TrimSpace(strings.TrimRight(string(out), "\x00"))
+		if info.Model != "" {
+			break
+		}
+	}
+	if info.Model == "" {
+		return info, fmt.Errorf("%w: could not read the machine name", ErrUnknownTablet)
+	}
+	devices, err := run("cat /proc/bus/input/devices")
+	if err != nil {
+		return info, fmt.Errorf("failed to list tablet input devices: %w", err)
+	}
+	info.Devices = ParseInputDevices(devices)
+
+	found := false
+	for _, p := range profiles {
+		if matchTabletModel(p.Models, info.Model) {
+			info.Profile = p
+			found = true
+			break
+		}
+	}
+	if !found {
+		return info, fmt.Errorf("%w: %s", ErrUnknownTablet, info.Model)
+	}
+	info.EventFile = info.Profile.EventFile
+	for _, name := range info.Profile.Digitizers {
+		for _, d := range info.Devices {
+			if d.Name == name && d.EventFile() != "" {
+				info.EventFile = d.EventFile()
+				return info, nil
+			}
+		}
+	}
+	if info.EventFile == "" {
+		return info, fmt.Errorf("could not find the pen digitizer of the %s", info.Profile.Name)
+	}
+	return info, nil
+}
+
+func matchTabletModel(patterns []string, model string) bool {
+	for _, p := range patterns {
+		if ok, err := regexp.MatchString(p, model); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testInputDevices = `I: Bus=0018 Vendor=056a Product=0000 Version=0036
+N: Name="Wacom I2C Digitizer"
+P: Phys=
+S: Sysfs=/devices/platform/30a20000.i2c/i2c-0/0-0009/input/input1
+U: Uniq=
+H: Handlers=mouse0 event1 
+B: PROP=0
+B: EV=b
+B: KEY=1c03 0 0 0 0 0 0 0 0 0 0
+B: ABS=f000003
+
+I: Bus=0018 Vendor=0000 Product=0000 Version=0000
+N: Name="pt_mt"
+P: Phys=
+S: Sysfs=/devices/platform/30a40000.i2c/i2c-2/2-0024/input/input2
+U: Uniq=
+H: Handlers=event2 
+B: PROP=2
+B: EV=b
+`
+
+func testTabletRunner(outputs map[string]string) TabletCommandRunner {
+	return func(cmd string) ([]byte, error) {
+		out, ok := outputs[cmd]
+		if !ok {
+			return nil, fmt.Errorf("no such file")
+		}
+		return []byte(out), nil
+	}
+}
+
+func TestParseInputDevices(t *testing.T) {
+	devices := ParseInputDevices([]byte(testInputDevices))
+	require.Len(t, devices, 2)
+	require.Equal(t, "Wacom I2C Digitizer", devices[0].Name)
+	require.Equal(t, "/dev/input/event1", devices[0].EventFile())
+	require.Equal(t, "pt_mt", devices[1].Name)
+	require.Equal(t, "/dev/input/event2", devices[1].EventFile())
+}
+
+func TestDetectTablet(t *testing.T) {
+	profiles := DefaultTabletProfiles()
+	tests := []struct {
+		name          string
+		outputs       map[string]string
+		wantName      string
+		wantEventFile string
+		wantErr       error
+	}{
+		{
+			name: "rm2 from soc machine",
+			outputs: map[string]string{
+				"cat /sys/devices/soc0/machine": "reMarkable 2.0\n",
+				"cat /proc/bus/input/devices":   testInputDevices,
+			},
+			wantName:      "reMarkable 2",
+			wantEventFile: "/dev/input/event1",
+		},
+		{
+			name: "rm1 from device tree without digitizer",
+			outputs: map[string]string{
+				"cat /proc/device-tree/model": "reMarkable 1.0\x00",
+				"cat /proc/bus/input/devices": "",
+			},
+			wantName:      "reMarkable 1",
+			wantEventFile: "/dev/input/event0",
+		},
+		{
+			name: "unknown model",
+			outputs: map[string]string{
+				"cat /sys/devices/soc0/machine": "Something Else\n",
+				"cat /proc/bus/input/devices":   testInputDevices,
+			},
+			wantErr: ErrUnknownTablet,
+		},
+		{
+			name:    "no model",
+			outputs: map[string]string{},
+			wantErr: ErrUnknownTablet,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := DetectTablet(testTabletRunner(tt.outputs), profiles)
+			if tt.wantErr != nil {
+				require.True(t, errors.Is(err, tt.wantErr))
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.wantName, info.Profile.Name)
+			require.Equal(t, tt.wantEventFile, info.EventFile)
+		})
+	}
+}
+
+func TestLoadTabletProfiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "tablets.json")
+	require.Nil(t, os.WriteFile(file, []byte(`[{
+		"name": "Custom",
+		"models": ["^reMarkable 2\\.0$"],
+		"digitizers": ["Custom Pen"],
+		"eventFile": "/dev/input/event5",
+		"tabletWidth": 100,
+		"tabletHeight": 50,
+		"eventLayout": "timeval64"
+	}]`), 0o600))
+
+	profiles, err := LoadTabletProfiles(file)
+	require.Nil(t, err)
+	require.Len(t, profiles, len(DefaultTabletProfiles())+1)
+	info, err := DetectTablet(testTabletRunner(map[string]string{
+		"cat /sys/devices/soc0/machine": "reMarkable 2.0",
+		"cat /proc/bus/input/devices":   testInputDevices,
+	}), profiles)
+	require.Nil(t, err)
+	require.Equal(t, "Custom", info.Profile.Name)
+	require.Equal(t, "/dev/input/event5", info.EventFile)
+	require.Equal(t, EventLayoutTimeval64, info.Profile.EventLayout)
+
+	profiles, err = LoadTabletProfiles(filepath.Join(dir, "missing.json"))
+	require.Nil(t, err)
+	require.Equal(t, DefaultTabletProfiles(), profiles)
+
+	require.Nil(t, os.WriteFile(file, []byte(`[{"name": "Bad", "eventLayout": "timeval16"}]`), 0o600))
+	_, err = LoadTabletProfiles(file)
+	require.NotNil(t, err)
+
+	require.Nil(t, os.WriteFile(file, []byte(`[{"name": "Bad", "axes": {"ABS_PRESURE": {"max": 1}}}]`), 0o600))
+	_, err = LoadTabletProfiles(file)
+	require.True(t, errors.Is(err, ErrUnknownEventCode))
+
+	require.Nil(t, os.WriteFile(file, []byte(`[{"name": "Bad", "axes": {"ABS_PRESSURE": {"min": 5, "max": 5}}}]`), 0o600))
+	_, err = LoadTabletProfiles(file)
+	require.NotNil(t, err)
+}
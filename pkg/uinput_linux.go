@@ -0,0 +1,198 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// The uinput ioctl requests from linux/uinput.h.
+const (
+	uiDevCreate  = 0x5501
+	uiDevDestroy = 0x5502
+	uiSetEvBit   = 0x40045564
+	uiSetKeyBit  = 0x40045565
+	uiSetAbsBit  = 0x40045567
+	busVirtual   = 0x06
+	absCount     = 0x40
+	uinputNameSz = 80
+)
+
+// uinputAxisScale is the number of device units per screen pixel. Positions
+// keep this much of their fractional part when sent to the device.
+const uinputAxisScale = 16
+
+// UinputDevice is the default path of the uinput device.
+const UinputDevice = "/dev/uinput"
+
+func init() {
+	RegisterDriver(DriverUinput, func(opts DriverOptions) (Driver, error) {
+		return NewUinputDriver(UinputDevice, opts.ScreenWidth, opts.ScreenHeight)
+	})
+}
+
+// uinputUserDev is struct uinput_user_dev from linux/uinput.h.
+type uinputUserDev struct {
+	Name         [uinputNameSz]byte
+	Bustype      uint16
+	Vendor       uint16
+	Product      uint16
+	Version      uint16
+	FFEffectsMax uint32
+	Absmax       [absCount]int32
+	Absmin       [absCount]int32
+	Absfuzz      [absCount]int32
+	Absflat      [absCount]int32
+}
+
+// inputEvent is struct input_event from linux/input.h.
+type inputEvent struct {
+	Time  unix.Timeval
+	Type  EventType
+	Code  uint16
+	Value int32
+}
+
+// UinputDriver implements Driver by creating a virtual absolute pointer with
+// the Linux uinput module. The pointer is handled by the kernel input stack so
+// it works with any display server, including Wayland compositors, and does
+// not need a display connection. The user must be able to write to
+// /dev/uinput.
+//
+// The device axes span the entire desktop. The compositor, rather than the
+// driver, maps them onto the screen so Width and Height only choose the units
+// of a position. Positions are sent with sub-pixel precision.
+type UinputDriver struct {
+	// Width and Height are the size reported by GetSize. The tablet
+	// dimensions are used if they are zero so that the full range of the
+	// tablet reaches the device.
+	Width  int
+	Height int
+	file   *os.File
+	device io.Writer
+}
+
+// NewUinputDriver creates a virtual pointer using the given uinput device.
+func NewUinputDriver(path string, width int, height int) (*UinputDriver, error) {
+	if width <= 0 {
+		width = DefaultTabletWidth
+	}
+	if height <= 0 {
+		height = DefaultTabletHeight
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("uinput: %w", err)
+	}
+	d := &UinputDriver{Width: width, Height: height, file: f, device: f}
+	if err = d.create(); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("uinput: %w", err)
+	}
+	return d, nil
+}
+
+func (d *UinputDriver) create() error {
+	fd := int(d.file.Fd())
+	for _, bit := range []struct {
+		req   uint
+		value int
+	}{
+		{uiSetEvBit, int(EV_SYN)},
+		{uiSetEvBit, int(EV_KEY)},
+		{uiSetEvBit, int(EV_ABS)},
+		{uiSetKeyBit, BTN_LEFT},
+		{uiSetAbsBit, ABS_X},
+		{uiSetAbsBit, ABS_Y},
+	} {
+		if err := unix.IoctlSetInt(fd, bit.req, bit.value); err != nil {
+			return err
+		}
+	}
+	dev := uinputUserDev{Bustype: busVirtual, Version: 1}
+	copy(dev.Name[:], "remouseable virtual pointer")
+	dev.Absmax[ABS_X] = int32(d.Width * uinputAxisScale)
+	dev.Absmax[ABS_Y] = int32(d.Height * uinputAxisScale)
+	buf := &bytes.Buffer{}
+	if err := binary.Write(buf, binary.NativeEndian, &dev); err != nil {
+		return err
+	}
+	if _, err := d.file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return unix.IoctlSetInt(fd, uiDevCreate, 0)
+}
+
+// GetSize returns the size of the device axes in pixels.
+func (d *UinputDriver) GetSize() (int, int, error) {
+	return d.Width, d.Height, nil
+}
+
+// Click and hold the mouse button down.
+func (d *UinputDriver) Click() error {
+	return d.write(inputEvent{Type: EV_KEY, Code: BTN_LEFT, Value: 1})
+}
+
+// Unclick and release the mouse button.
+func (d *UinputDriver) Unclick() error {
+	return d.write(inputEvent{Type: EV_KEY, Code: BTN_LEFT, Value: 0})
+}
+
+// MoveMouse sets the mouse to a specified location.
+func (d *UinputDriver) MoveMouse(x float64, y float64) error {
+	return d.write(
+		inputEvent{Type: EV_ABS, Code: ABS_X, Value: uinputAxisValue(x)},
+		inputEvent{Type: EV_ABS, Code: ABS_Y, Value: uinputAxisValue(y)},
+	)
+}
+
+// DragMouse sets the mouse to a specified location. The button is already
+// held so this is the same as a move.
+func (d *UinputDriver) DragMouse(x float64, y float64) error {
+	return d.MoveMouse(x, y)
+}
+
+// Close removes the virtual pointer.
+func (d *UinputDriver) Close() error {
+	if d.file == nil {
+		return nil
+	}
+	_ = unix.IoctlSetInt(int(d.file.Fd()), uiDevDestroy, 0)
+	return d.file.Close()
+}
+
+// write sends a group of events followed by a SYN_REPORT so that they are
+// applied together.
+func (d *UinputDriver) write(events ...inputEvent) error {
+	buf := &bytes.Buffer{}
+	for _, evt := range append(events, inputEvent{Type: EV_SYN, Code: SYN_REPORT}) {
+		if err := binary.Write(buf, binary.NativeEndian, &evt); err != nil {
+			return err
+		}
+	}
+	_, err := d.device.Write(buf.Bytes())
+	return err
+}
+
+func uinputAxisValue(v float64) int32 {
+	return int32(math.Round(v * uinputAxisScale))
+}
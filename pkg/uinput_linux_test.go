@@ -0,0 +1,48 @@
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUinputDriver_Events(t *testing.T) {
+	out := &bytes.Buffer{}
+	d := &UinputDriver{Width: 100, Height: 50, device: out}
+	require.Nil(t, d.MoveMouse(10.5, 20.01))
+	require.Nil(t, d.Click())
+
+	var events []inputEvent
+	for out.Len() > 0 {
+		var evt inputEvent
+		require.Nil(t, binary.Read(out, binary.NativeEndian, &evt))
+		events = append(events, evt)
+	}
+	got := make([]EvdevEvent, 0, len(events))
+	for _, evt := range events {
+		got = append(got, EvdevEvent{Type: evt.Type, Code: evt.Code, Value: evt.Value})
+	}
+	require.Equal(t, []EvdevEvent{
+		{Type: EV_ABS, Code: ABS_X, Value: 168},
+		{Type: EV_ABS, Code: ABS_Y, Value: 320},
+		{Type: EV_SYN, Code: SYN_REPORT, Value: 0},
+		{Type: EV_KEY, Code: BTN_LEFT, Value: 1},
+		{Type: EV_SYN, Code: SYN_REPORT, Value: 0},
+	}, got)
+}
@@ -0,0 +1,379 @@
+//go:build linux || freebsd || openbsd || netbsd
+
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The parts of the X11 core protocol and the XTEST extension that are needed
+// to move the pointer. See https://www.x.org/releases/current/doc/xproto/x11protocol.html
+// and https://www.x.org/releases/current/doc/xextproto/xtest.html for the
+// full protocol.
+const (
+	x11OpQueryExtension  = 98
+	x11XTestFakeInput    = 2
+	x11ButtonPress       = 4
+	x11ButtonRelease     = 5
+	x11MotionNotify      = 6
+	x11GenericEvent      = 35
+	x11FamilyLocal       = 256
+	x11FamilyWild        = 65535
+	x11AuthMagicCookie   = "MIT-MAGIC-COOKIE-1"
+	x11UnixSocketPattern = "/tmp/.X11-unix/X%d"
+	x11TCPPortBase       = 6000
+)
+
+// x11Display is a parsed DISPLAY value such as :0, :1.0, or host:10.0.
+type x11Display struct {
+	Host   string
+	Number int
+	Screen int
+}
+
+// parseX11Display parses a DISPLAY value. A host of unix, or no host, means
+// the local Unix socket.
+func parseX11Display(display string) (x11Display, error) {
+	d := x11Display{}
+	colon := strings.LastIndex(display, ":")
+	if colon < 0 {
+		return d, fmt.Errorf("invalid DISPLAY %q", display)
+	}
+	d.Host = display[:colon]
+	rest := display[colon+1:]
+	if dot := strings.Index(rest, "."); dot >= 0 {
+		screen, err := strconv.Atoi(rest[dot+1:])
+		if err != nil {
+			return d, fmt.Errorf("invalid DISPLAY %q", display)
+		}
+		d.Screen = screen
+		rest = rest[:dot]
+	}
+	number, err := strconv.Atoi(rest)
+	if err != nil {
+		return d, fmt.Errorf("invalid DISPLAY %q", display)
+	}
+	d.Number = number
+	return d, nil
+}
+
+// dial connects to the display server.
+func (d x11Display) dial() (net.Conn, error) {
+	if d.Host == "" || d.Host == "unix" {
+		return net.Dial("unix", fmt.Sprintf(x11UnixSocketPattern, d.Number))
+	}
+	return net.Dial("tcp", net.JoinHostPort(d.Host, strconv.Itoa(x11TCPPortBase+d.Number)))
+}
+
+// x11Auth is an entry of an Xauthority file.
+type x11Auth struct {
+	Family  uint16
+	Address string
+	Number  string
+	Name    string
+	Data    []byte
+}
+
+// readX11Auth parses the entries of an Xauthority file.
+func readX11Auth(r io.Reader) ([]x11Auth, error) {
+	br := bufio.NewReader(r)
+	readField := func() ([]byte, error) {
+		var n uint16
+		if err := binary.Read(br, binary.BigEndian, &n); err != nil {
+			return nil, err
+		}
+		b := make([]byte, n)
+		_, err := io.ReadFull(br, b)
+		return b, err
+	}
+	var entries []x11Auth
+	for {
+		var family uint16
+		if err := binary.Read(br, binary.BigEndian, &family); err != nil {
+			if errors.Is(err, io.EOF) {
+				return entries, nil
+			}
+			return nil, err
+		}
+		var fields [4][]byte
+		for i := range fields {
+			b, err := readField()
+			if err != nil {
+				return nil, fmt.Errorf("truncated Xauthority entry: %w", err)
+			}
+			fields[i] = b
+		}
+		entries = append(entries, x11Auth{
+			Family:  family,
+			Address: string(fields[0]),
+			Number:  string(fields[1]),
+			Name:    string(fields[2]),
+			Data:    fields[3],
+		})
+	}
+}
+
+// findX11Auth returns the cookie for a display from the Xauthority file, or
+// an empty name and data if there is none. Servers that use other forms of
+// access control accept connections without a cookie.
+func findX11Auth(d x11Display, getenv func(string) string) (string, []byte) {
+	file := getenv("XAUTHORITY")
+	if file == "" {
+		file = filepath.Join(getenv("HOME"), ".Xauthority")
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return "", nil
+	}
+	defer f.Close()
+	entries, err := readX11Auth(f)
+	if err != nil {
+		return "", nil
+	}
+	return matchX11Auth(entries, d)
+}
+
+func matchX11Auth(entries []x11Auth, d x11Display) (string, []byte) {
+	hostname, _ := os.Hostname()
+	number := strconv.Itoa(d.Number)
+	for _, e := range entries {
+		if e.Name != x11AuthMagicCookie || (e.Number != "" && e.Number != number) {
+			continue
+		}
+		switch {
+		case e.Family == x11FamilyWild:
+		case e.Family == x11FamilyLocal && (d.Host == "" || d.Host == "unix") && e.Address == hostname:
+		case e.Address == d.Host:
+		default:
+			continue
+		}
+		return e.Name, e.Data
+	}
+	return "", nil
+}
+
+// x11Conn is a connection to a display server that can send XTEST input.
+type x11Conn struct {
+	conn   net.Conn
+	order  binary.ByteOrder
+	root   uint32
+	width  int
+	height int
+	xtest  byte
+	lock   sync.Mutex
+	err    error
+	closed chan struct{}
+}
+
+// dialX11 connects to a display and prepares the XTEST extension.
+func dialX11(display string, getenv func(string) string) (*x11Conn, error) {
+	d, err := parseX11Display(display)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := d.dial()
+	if err != nil {
+		return nil, err
+	}
+	authName, authData := findX11Auth(d, getenv)
+	c, err := newX11Conn(conn, d.Screen, authName, authData)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// newX11Conn performs the connection setup on an established connection and
+// then looks up the XTEST extension.
+func newX11Conn(conn net.Conn, screen int, authName string, authData []byte) (*x11Conn, error) {
+	c := &x11Conn{conn: conn, order: binary.LittleEndian, closed: make(chan struct{})}
+	if err := c.setup(screen, authName, authData); err != nil {
+		return nil, err
+	}
+	opcode, err := c.queryExtension("XTEST")
+	if err != nil {
+		return nil, err
+	}
+	c.xtest = opcode
+	go c.drain()
+	return c, nil
+}
+
+func (c *x11Conn) setup(screen int, authName string, authData []byte) error {
+	req := make([]byte, 12, 12+x11Pad(len(authName))+x11Pad(len(authData)))
+	req[0] = 'l'
+	c.order.PutUint16(req[2:], 11)
+	c.order.PutUint16(req[4:], 0)
+	c.order.PutUint16(req[6:], uint16(len(authName)))
+	c.order.PutUint16(req[8:], uint16(len(authData)))
+	req = append(req, x11Padded([]byte(authName))...)
+	req = append(req, x11Padded(authData)...)
+	if _, err := c.conn.Write(req); err != nil {
+		return err
+	}
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return err
+	}
+	body := make([]byte, int(c.order.Uint16(header[6:]))*4)
+	if _, err := io.ReadFull(c.conn, body); err != nil {
+		return err
+	}
+	switch header[0] {
+	case 0:
+		reason := body[:min(int(header[1]), len(body))]
+		return fmt.Errorf("the X server refused the connection: %s", strings.TrimSpace(string(reason)))
+	case 2:
+		return fmt.Errorf("the X server requires further authentication: %s", strings.TrimRight(string(body), "\x00"))
+	}
+	if len(body) < 32 {
+		return errors.New("the X server sent a short setup reply")
+	}
+	vendorLen := int(c.order.Uint16(body[16:]))
+	screens := int(body[20])
+	formats := int(body[21])
+	if screen >= screens {
+		return fmt.Errorf("the X server has no screen %d", screen)
+	}
+	offset := 32 + x11Pad(vendorLen) + formats*8
+	for i := 0; ; i++ {
+		if offset+40 > len(body) {
+			return errors.New("the X server sent a short setup reply")
+		}
+		if i == screen {
+			c.root = c.order.Uint32(body[offset:])
+			c.width = int(c.order.Uint16(body[offset+20:]))
+			c.height = int(c.order.Uint16(body[offset+22:]))
+			return nil
+		}
+		depths := int(body[offset+39])
+		offset += 40
+		for j := 0; j < depths; j++ {
+			if offset+8 > len(body) {
+				return errors.New("the X server sent a short setup reply")
+			}
+			visuals := int(c.order.Uint16(body[offset+2:]))
+			offset += 8 + visuals*24
+		}
+	}
+}
+
+func (c *x11Conn) queryExtension(name string) (byte, error) {
+	req := make([]byte, 8, 8+x11Pad(len(name)))
+	req[0] = x11OpQueryExtension
+	c.order.PutUint16(req[2:], uint16(2+x11Pad(len(name))/4))
+	c.order.PutUint16(req[4:], uint16(len(name)))
+	req = append(req, x11Padded([]byte(name))...)
+	if _, err := c.conn.Write(req); err != nil {
+		return 0, err
+	}
+	reply := make([]byte, 32)
+	if _, err := io.ReadFull(c.conn, reply); err != nil {
+		return 0, err
+	}
+	if reply[0] == 0 {
+		return 0, fmt.Errorf("the X server returned error %d for QueryExtension", reply[1])
+	}
+	if reply[8] == 0 {
+		return 0, fmt.Errorf("the X server does not support the %s extension", name)
+	}
+	return reply[9], nil
+}
+
+// drain reads errors, replies, and events so that the server never blocks
+// on a full socket. The first error is kept and returned by later requests.
+func (c *x11Conn) drain() {
+	packet := make([]byte, 32)
+	for {
+		if _, err := io.ReadFull(c.conn, packet); err != nil {
+			select {
+			case <-c.closed:
+			default:
+				c.fail(err)
+			}
+			return
+		}
+		switch {
+		case packet[0] == 0:
+			c.fail(fmt.Errorf("the X server returned error %d for request %d.%d", packet[1], packet[10], c.order.Uint16(packet[8:])))
+		case packet[0] == 1 || packet[0]&0x7f == x11GenericEvent:
+			extra := int64(c.order.Uint32(packet[4:])) * 4
+			if _, err := io.CopyN(io.Discard, c.conn, extra); err != nil {
+				c.fail(err)
+				return
+			}
+		}
+	}
+}
+
+func (c *x11Conn) fail(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// fakeInput sends an XTEST FakeInput request.
+func (c *x11Conn) fakeInput(eventType byte, detail byte, x int, y int) error {
+	req := make([]byte, 36)
+	req[0] = c.xtest
+	req[1] = x11XTestFakeInput
+	c.order.PutUint16(req[2:], 9)
+	req[4] = eventType
+	req[5] = detail
+	c.order.PutUint32(req[12:], c.root)
+	c.order.PutUint16(req[24:], uint16(int16(x)))
+	c.order.PutUint16(req[26:], uint16(int16(y)))
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	_, err := c.conn.Write(req)
+	return err
+}
+
+func (c *x11Conn) Close() error {
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+	return c.conn.Close()
+}
+
+// x11Pad rounds a length up to the four byte alignment of the protocol.
+func x11Pad(n int) int {
+	return (n + 3) &^ 3
+}
+
+func x11Padded(b []byte) []byte {
+	return append(b[:len(b):len(b)], make([]byte, x11Pad(len(b))-len(b))...)
+}
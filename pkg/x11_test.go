@@ -0,0 +1,174 @@
+//go:build linux || freebsd || openbsd || netbsd
+
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseX11Display(t *testing.T) {
+	tests := []struct {
+		display string
+		want    x11Display
+		wantErr bool
+	}{
+		{display: ":0", want: x11Display{Number: 0}},
+		{display: ":1.2", want: x11Display{Number: 1, Screen: 2}},
+		{display: "unix:3", want: x11Display{Host: "unix", Number: 3}},
+		{display: "remote.example:10.0", want: x11Display{Host: "remote.example", Number: 10}},
+		{display: "wayland-0", wantErr: true},
+		{display: ":x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.display, func(t *testing.T) {
+			got, err := parseX11Display(tt.display)
+			if tt.wantErr {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMatchX11Auth(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writeEntry := func(family uint16, fields ...string) {
+		_ = binary.Write(buf, binary.BigEndian, family)
+		for _, f := range fields {
+			_ = binary.Write(buf, binary.BigEndian, uint16(len(f)))
+			buf.WriteString(f)
+		}
+	}
+	writeEntry(0, "\x0a\x00\x00\x01", "0", x11AuthMagicCookie, "ip")
+	writeEntry(x11FamilyWild, "", "1", x11AuthMagicCookie, "wild")
+	writeEntry(x11FamilyWild, "", "0", "XDM-AUTHORIZATION-1", "other")
+	writeEntry(x11FamilyWild, "", "0", x11AuthMagicCookie, "cookie")
+	entries, err := readX11Auth(buf)
+	require.Nil(t, err)
+	require.Len(t, entries, 4)
+
+	name, data := matchX11Auth(entries, x11Display{Number: 0})
+	require.Equal(t, x11AuthMagicCookie, name)
+	require.Equal(t, []byte("cookie"), data)
+	_, data = matchX11Auth(entries, x11Display{Number: 1})
+	require.Equal(t, []byte("wild"), data)
+	name, _ = matchX11Auth(entries, x11Display{Number: 2})
+	require.Equal(t, "", name)
+
+	_, err = readX11Auth(bytes.NewReader([]byte{0, 0, 0, 5, 'a'}))
+	require.NotNil(t, err)
+}
+
+// fakeX11Server answers the connection setup and the XTEST query and then
+// sends every later request to the requests channel.
+func fakeX11Server(conn net.Conn, requests chan<- []byte) {
+	le := binary.LittleEndian
+	setup := make([]byte, 12)
+	if _, err := io.ReadFull(conn, setup); err != nil {
+		return
+	}
+	auth := make([]byte, x11Pad(int(le.Uint16(setup[6:])))+x11Pad(int(le.Uint16(setup[8:]))))
+	if _, err := io.ReadFull(conn, auth); err != nil {
+		return
+	}
+
+	vendor := x11Padded([]byte("fake"))
+	body := make([]byte, 32)
+	le.PutUint16(body[16:], 4)
+	body[20] = 1 // screens
+	body[21] = 1 // formats
+	body = append(body, vendor...)
+	body = append(body, make([]byte, 8)...) // one pixmap format
+	screen := make([]byte, 40)
+	le.PutUint32(screen, 0x2a)
+	le.PutUint16(screen[20:], 2560)
+	le.PutUint16(screen[22:], 1440)
+	screen[39] = 1 // depths
+	body = append(body, screen...)
+	depth := make([]byte, 8)
+	le.PutUint16(depth[2:], 1)
+	body = append(body, depth...)
+	body = append(body, make([]byte, 24)...) // one visual
+	header := make([]byte, 8)
+	header[0] = 1
+	le.PutUint16(header[2:], 11)
+	le.PutUint16(header[6:], uint16(len(body)/4))
+	_, _ = conn.Write(append(header, body...))
+
+	query := make([]byte, 8)
+	if _, err := io.ReadFull(conn, query); err != nil {
+		return
+	}
+	name := make([]byte, int(le.Uint16(query[2:]))*4-8)
+	if _, err := io.ReadFull(conn, name); err != nil {
+		return
+	}
+	reply := make([]byte, 32)
+	reply[0] = 1
+	if string(name[:le.Uint16(query[4:])]) == "XTEST" {
+		reply[8] = 1
+	}
+	reply[9] = 140
+	_, _ = conn.Write(reply)
+
+	for {
+		req := make([]byte, 36)
+		if _, err := io.ReadFull(conn, req); err != nil {
+			close(requests)
+			return
+		}
+		requests <- req
+	}
+}
+
+func TestXTestDriver(t *testing.T) {
+	client, server := net.Pipe()
+	requests := make(chan []byte, 8)
+	go fakeX11Server(server, requests)
+
+	conn, err := newX11Conn(client, 0, x11AuthMagicCookie, []byte("0123456789abcdef"))
+	require.Nil(t, err)
+	d := &XTestDriver{conn: conn}
+	w, h, err := d.GetSize()
+	require.Nil(t, err)
+	require.Equal(t, 2560, w)
+	require.Equal(t, 1440, h)
+
+	require.Nil(t, d.MoveMouse(100.6, 20.2))
+	require.Nil(t, d.Click())
+	require.Nil(t, d.Unclick())
+	require.Nil(t, d.Close())
+
+	le := binary.LittleEndian
+	move := <-requests
+	require.Equal(t, []byte{140, x11XTestFakeInput, 9, 0, x11MotionNotify, 0}, move[:6])
+	require.Equal(t, uint32(0x2a), le.Uint32(move[12:]))
+	require.Equal(t, uint16(101), le.Uint16(move[24:]))
+	require.Equal(t, uint16(20), le.Uint16(move[26:]))
+	press := <-requests
+	require.Equal(t, []byte{x11ButtonPress, x11LeftButton}, press[4:6])
+	release := <-requests
+	require.Equal(t, []byte{x11ButtonRelease, x11LeftButton}, release[4:6])
+}
@@ -0,0 +1,83 @@
+//go:build linux || freebsd || openbsd || netbsd
+
+// This file is part of remouseable.
+//
+// remouseable is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as published
+// by the Free Software Foundation.
+//
+// remouseable is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with remouseable.  If not, see <https://www.gnu.org/licenses/>.
+
+package remouseable
+
+import (
+	"errors"
+	"os"
+)
+
+// x11LeftButton is the core protocol number of the left mouse button.
+const x11LeftButton = 1
+
+func init() {
+	RegisterDriver(DriverXTest, func(DriverOptions) (Driver, error) {
+		return NewXTestDriver(os.Getenv("DISPLAY"))
+	})
+}
+
+// XTestDriver implements Driver by speaking the X11 protocol directly and
+// sending input with the XTEST extension. Unlike RobotgoDriver it is written
+// entirely in Go so it is available in builds made with CGO_ENABLED=0.
+type XTestDriver struct {
+	conn *x11Conn
+}
+
+// NewXTestDriver connects to an X display such as :0. Authentication cookies
+// are read from the file named by XAUTHORITY or from ~/.Xauthority.
+func NewXTestDriver(display string) (*XTestDriver, error) {
+	if display == "" {
+		return nil, errors.New("DISPLAY is not set so there is no X server to connect to")
+	}
+	conn, err := dialX11(display, os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	return &XTestDriver{conn: conn}, nil
+}
+
+// GetSize returns the width and height of the X screen.
+func (d *XTestDriver) GetSize() (int, int, error) {
+	return d.conn.width, d.conn.height, nil
+}
+
+// Click and hold the mouse button down.
+func (d *XTestDriver) Click() error {
+	return d.conn.fakeInput(x11ButtonPress, x11LeftButton, 0, 0)
+}
+
+// Unclick and release the mouse button.
+func (d *XTestDriver) Unclick() error {
+	return d.conn.fakeInput(x11ButtonRelease, x11LeftButton, 0, 0)
+}
+
+// MoveMouse sets the mouse to a specified location.
+func (d *XTestDriver) MoveMouse(x float64, y float64) error {
+	px, py := RoundPosition(x, y)
+	return d.conn.fakeInput(x11MotionNotify, 0, px, py)
+}
+
+// DragMouse sets the mouse to a specified location. The button is already
+// held so this is the same as a move.
+func (d *XTestDriver) DragMouse(x float64, y float64) error {
+	return d.MoveMouse(x, y)
+}
+
+// Close the connection to the X server.
+func (d *XTestDriver) Close() error {
+	return d.conn.Close()
+}